@@ -1,15 +1,21 @@
 package api
 
 import (
+	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
 	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/scheduler"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
@@ -18,7 +24,9 @@ type AuctionVehicleResponse struct {
 	VehicleID *uint           `json:"vehicle_id"`
 	Details   *string         `json:"details"`
 	Images    []ImageResponse `json:"images"`
-	// Include other fields from gorm.Model if needed
+	EndsAt    *time.Time      `json:"ends_at"`
+	WinnerID  *uint           `json:"winner_id"`
+	ClosedAt  *time.Time      `json:"closed_at"`
 }
 
 type ImageResponse struct {
@@ -26,6 +34,37 @@ type ImageResponse struct {
 	Url *string `json:"url"`
 }
 
+// newAuctionVehicleResponse returns the auction with its images as URLs below /static
+func newAuctionVehicleResponse(auction models.AuctionVehicle) AuctionVehicleResponse {
+	response := AuctionVehicleResponse{
+		ID:        auction.ID,
+		VehicleID: auction.VehicleID,
+		Details:   auction.Details,
+		Images:    make([]ImageResponse, len(auction.Images)),
+		EndsAt:    auction.EndsAt,
+		WinnerID:  auction.WinnerID,
+		ClosedAt:  auction.ClosedAt,
+	}
+	for i, image := range auction.Images {
+		url := convertFilePathToURL(image.Url)
+		response.Images[i] = ImageResponse{ID: image.ID, Url: &url}
+	}
+	return response
+}
+
+type createAuctionRequest struct {
+	VehicleID *uint  `form:"vehicle_id" binding:"required"`
+	Details   string `form:"details" binding:"max=2000"`
+	EndsAt    string `form:"ends_at" binding:"omitempty,date"`
+}
+
+func (r createAuctionRequest) validate() []apperr.FieldError {
+	if endsAt, _ := parseDate(r.EndsAt); endsAt != nil && !endsAt.After(time.Now()) {
+		return []apperr.FieldError{{Field: "ends_at", Message: "ends_at must be in the future"}}
+	}
+	return nil
+}
+
 // CreateAuction godoc
 // @Summary Create an auction
 // @Description Admins can create auctions with vehicle details and images
@@ -34,6 +73,7 @@ type ImageResponse struct {
 // @Produce json
 // @Param vehicle_id formData uint true "Vehicle ID"
 // @Param details formData string false "Details of the auction"
+// @Param ends_at formData string false "When the auction closes unless an admin closed it before (YYYY-MM-DD or RFC3339)"
 // @Param images formData file false "Images for the auction"
 // @Success 200 {object} AuctionVehicleResponse "Successful response with auction details"
 // @Router /auction [post]
@@ -41,27 +81,29 @@ type ImageResponse struct {
 func (s *Server) CreateAuction(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(http.StatusBadRequest, errorResponse(errors.New("only admins can create auctions")))
+		c.Error(apperr.Forbidden("only admins can create auctions"))
 		return
 	}
 
-	// Parse multipart form
-	if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32 MB max memory
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	if err := c.Request.ParseMultipartForm(s.config.Uploads.MaxRequestSize); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Manually extract and set fields for AuctionVehicle
-	var auction models.AuctionVehicle
-	auction.VehicleID = parseUint(c.PostForm("vehicle_id"))
-	auction.Details = parseString(c.PostForm("details"))
+	var req createAuctionRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	endsAt, _ := parseDate(req.EndsAt)
+	auction := models.AuctionVehicle{VehicleID: req.VehicleID, Details: parseString(req.Details), EndsAt: endsAt}
 
 	// Process image uploads
 	files := c.Request.MultipartForm.File["images"] // "images" is the name attribute in the form
 	for _, file := range files {
-		imagePath, err := saveFile(file, c)
+		imagePath, err := s.saveFile(file, c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			c.Error(err)
 			return
 		}
 
@@ -70,25 +112,23 @@ func (s *Server) CreateAuction(c *gin.Context) {
 	}
 
 	// Create the auction record with images in the database
-	if err := s.DB.Create(&auction).Error; err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	ctx := c.Request.Context()
+	err := s.storeTransaction(ctx, func(tx store.Store) error {
+		if err := tx.Auctions().Create(ctx, &auction); err != nil {
+			return err
+		}
+		return tx.WriteEvent(ctx, events.Event{
+			Type:      events.AuctionCreated,
+			VehicleID: auction.VehicleID,
+			Data:      gin.H{"auction_id": auction.ID, "vehicle_id": auction.VehicleID, "details": auction.Details},
+		})
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, auction)
-}
-
-// Helper function to parse uint from form value
-func parseUint(s string) *uint {
-	if s == "" {
-		return nil
-	}
-	v, err := strconv.ParseUint(s, 10, 64)
-	if err != nil {
-		return nil
-	}
-	u := uint(v)
-	return &u
+	c.JSON(http.StatusOK, newAuctionVehicleResponse(auction))
 }
 
 // Helper function to parse string pointer from form value
@@ -106,24 +146,20 @@ func parseString(s string) *string {
 // @Description Get all auctions
 // @Tags auction
 // @Produce json
-// @Success 200 {object} []AuctionVehicleResponse "Successful response with auction details"
+// @Success 200 {array} AuctionVehicleResponse "Successful response with auction details"
 // @Router /auction [get]
 func (s *Server) GetAuctions(c *gin.Context) {
-	var auctions []models.AuctionVehicle
-	if err := s.DB.Preload("Images").Find(&auctions).Error; err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	auctions, err := s.store.Auctions().List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Convert file paths to URLs
-	for i := range auctions {
-		for j := range auctions[i].Images {
-			temp := convertFilePathToURL(auctions[i].Images[j].Url)
-			auctions[i].Images[j].Url = &temp
-		}
+	response := make([]AuctionVehicleResponse, len(auctions))
+	for i, auction := range auctions {
+		response[i] = newAuctionVehicleResponse(auction)
 	}
-
-	c.JSON(http.StatusOK, auctions)
+	c.JSON(http.StatusOK, response)
 }
 
 // GetAuction godoc
@@ -135,19 +171,13 @@ func (s *Server) GetAuctions(c *gin.Context) {
 // @Success 200 {object} AuctionVehicleResponse "Successful response with auction details"
 // @Router /auction/{id} [get]
 func (s *Server) GetAuction(c *gin.Context) {
-	var auction models.AuctionVehicle
-	if err := s.DB.Preload("Images").First(&auction, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	auction, err := s.store.Auctions().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Convert file paths to URLs
-	for i := range auction.Images {
-		temp := convertFilePathToURL(auction.Images[i].Url)
-		auction.Images[i].Url = &temp
-	}
-
-	c.JSON(http.StatusOK, auction)
+	c.JSON(http.StatusOK, newAuctionVehicleResponse(auction))
 }
 
 // DeleteAuction godoc
@@ -162,34 +192,121 @@ func (s *Server) GetAuction(c *gin.Context) {
 func (s *Server) DeleteAuction(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(http.StatusBadRequest, errorResponse(errors.New("only admins can delete auctions")))
+		c.Error(apperr.Forbidden("only admins can delete auctions"))
 		return
 	}
 
 	// Find the auction with its images
-	var auction models.AuctionVehicle
-	if err := s.DB.Preload("Images").First(&auction, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	auction, err := s.store.Auctions().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Delete image files from the server
 	for _, image := range auction.Images {
 		if image.Url != nil {
-			filePath := filepath.Join("uploads", *image.Url) // Construct the full file path
+			filePath := filepath.Join(s.config.Storage.Dir, *image.Url) // Construct the full file path
 			err := os.Remove(filePath)
 			if err != nil {
 				// Handle the error but don't stop the process
-				log.Printf("Error deleting file: %v", err)
+				slog.WarnContext(c.Request.Context(), "cannot delete auction image", slog.Any("error", err))
 			}
 		}
 	}
 
 	// Delete the auction record from the database
-	if err := s.DB.Delete(&auction).Error; err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	if err := s.store.Auctions().Delete(c.Request.Context(), auction.ID); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Auction deleted successfully"})
 }
+
+type closeAuctionRequest struct {
+	WinnerID uint `json:"winner_id" binding:"required"`
+}
+
+var errAuctionClosed = apperr.Conflict("auction is already closed")
+
+// closeAuction records the winner of the auction, if any, and closes it
+func (s *Server) closeAuction(ctx context.Context, auction *models.AuctionVehicle, winnerID *uint) error {
+	return s.storeTransaction(ctx, func(tx store.Store) error {
+		closed, err := tx.Auctions().Close(ctx, auction, winnerID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !closed {
+			return errAuctionClosed
+		}
+		return tx.WriteEvent(ctx, events.Event{
+			Type:      events.AuctionClosed,
+			VehicleID: auction.VehicleID,
+			Data:      gin.H{"auction_id": auction.ID, "vehicle_id": auction.VehicleID, "winner_id": winnerID},
+		})
+	})
+}
+
+// closeEndedAuctions closes the open auctions whose end time passed, without a winner. Admins
+// record the winner by closing the auction before it ends.
+func (s *Server) closeEndedAuctions(ctx context.Context) error {
+	auctions, err := s.store.Auctions().ListEnded(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(auctions) == 0 {
+		return scheduler.ErrIdle
+	}
+	var failures []error
+	for i := range auctions {
+		// an admin may have closed it in the meantime
+		if err := s.closeAuction(ctx, &auctions[i], nil); err != nil && !errors.Is(err, errAuctionClosed) {
+			failures = append(failures, fmt.Errorf("auction %d: %w", auctions[i].ID, err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// CloseAuction godoc
+// @Summary Close an auction
+// @Description Admins close an auction before it ends and record the winning user, who is notified
+// @Tags auction
+// @Accept json
+// @Produce json
+// @Param id path int true "Auction ID"
+// @Param auction body closeAuctionRequest true "Winner"
+// @Success 200 {object} AuctionVehicleResponse "Successful response with auction details"
+// @Router /auction/{id}/close [post]
+// @Security ApiKeyAuth
+func (s *Server) CloseAuction(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can close auctions"))
+		return
+	}
+	var req closeAuctionRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	auction, err := s.store.Auctions().Get(ctx, idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if auction.ClosedAt != nil {
+		c.Error(errAuctionClosed)
+		return
+	}
+	if _, err := s.store.Users().Get(ctx, req.WinnerID); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.closeAuction(ctx, &auction, &req.WinnerID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, newAuctionVehicleResponse(auction))
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+)
+
+func TestCloseAuctionsJobClosesEndedAuctions(t *testing.T) {
+	// the scheduler keeps its locks and runs in the database, Start adds the rows of the jobs
+	h := apitest.New(t)
+	if err := h.Server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Server.Shutdown(context.Background()) })
+	admin := h.As(apitest.RoleAdmin)
+	vehicle := addVehicle(t, h, "Active", nil)
+	auctions := map[string]*models.AuctionVehicle{}
+	for name, endsAt := range map[string]*time.Time{
+		"ended":     ptr(time.Now().Add(-time.Minute)),
+		"running":   ptr(time.Now().Add(time.Hour)),
+		"never end": nil,
+	} {
+		auction := models.AuctionVehicle{VehicleID: &vehicle.ID, EndsAt: endsAt}
+		if err := h.Store.Auctions().Create(context.Background(), &auction); err != nil {
+			t.Fatal(err)
+		}
+		auctions[name] = &auction
+	}
+
+	if code := admin.Post("/jobs/close_auctions/run", nil, nil); code != http.StatusAccepted {
+		t.Fatalf("run close_auctions: status = %d, want 202", code)
+	}
+	// the run goes on in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var runs []struct {
+			Status string `json:"status"`
+		}
+		if code := admin.Get("/jobs/close_auctions/runs", &runs); code != http.StatusOK {
+			t.Fatalf("job runs: status = %d", code)
+		}
+		if len(runs) == 1 && runs[0].Status == "succeeded" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runs = %+v, want one that succeeded", runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for name, auction := range auctions {
+		stored, err := h.Store.Auctions().Get(context.Background(), auction.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if closed := stored.ClosedAt != nil; closed != (name == "ended") {
+			t.Errorf("%s auction closed = %v", name, closed)
+		}
+		if stored.WinnerID != nil {
+			t.Errorf("%s auction winner = %d, want none", name, *stored.WinnerID)
+		}
+	}
+	if got, want := h.EventTypes(), []string{events.AuctionClosed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestAuctionMustEndInTheFuture(t *testing.T) {
+	h := apitest.NewMemory(t)
+	vehicle := addVehicle(t, h, "Active", nil)
+	fields := map[string]string{"vehicle_id": fmt.Sprint(vehicle.ID), "ends_at": "2020-01-01"}
+	if recorder := h.As(apitest.RoleAdmin).Form(http.MethodPost, "/auction", fields, nil); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("auction ending in 2020: status = %d %s, want 422", recorder.Code, recorder.Body)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
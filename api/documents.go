@@ -0,0 +1,465 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"github.com/rassulmagauin/VMS_SWE/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	alertDocumentExpiring = "document_expiring"
+	alertDocumentExpired  = "document_expired"
+	alertMaintenanceDue   = "maintenance_due"
+	alertSeverityWarning  = "warning"
+	alertSeverityCritical = "critical"
+)
+
+type vehicleDocumentResponse struct {
+	ID        uint       `json:"ID"`
+	VehicleID *uint      `json:"vehicle_id"`
+	Type      *string    `json:"type"`
+	Number    *string    `json:"number"`
+	Issuer    *string    `json:"issuer"`
+	ValidFrom *time.Time `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to"`
+	File      *string    `json:"file"`
+	Expired   bool       `json:"expired"`
+}
+
+type alertResponse struct {
+	ID         uint       `json:"ID"`
+	Kind       string     `json:"kind"`
+	Severity   string     `json:"severity"`
+	Message    string     `json:"message"`
+	VehicleID  *uint      `json:"vehicle_id"`
+	DocumentID *uint      `json:"document_id"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"CreatedAt"`
+}
+
+func newAlertResponse(alert models.Alert) alertResponse {
+	return alertResponse{
+		ID:         alert.ID,
+		Kind:       alert.Kind,
+		Severity:   alert.Severity,
+		Message:    alert.Message,
+		VehicleID:  alert.VehicleID,
+		DocumentID: alert.DocumentID,
+		ResolvedAt: alert.ResolvedAt,
+		CreatedAt:  alert.CreatedAt,
+	}
+}
+
+type updateVehicleDocumentRequest struct {
+	Number    *string    `json:"number" binding:"omitempty,min=1,max=50"`
+	Issuer    *string    `json:"issuer" binding:"omitempty,max=100"`
+	ValidFrom *time.Time `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to"`
+}
+
+func newVehicleDocumentResponse(document models.VehicleDocument) vehicleDocumentResponse {
+	response := vehicleDocumentResponse{
+		ID:        document.ID,
+		VehicleID: document.VehicleID,
+		Type:      document.Type,
+		Number:    document.Number,
+		Issuer:    document.Issuer,
+		ValidFrom: document.ValidFrom,
+		ValidTo:   document.ValidTo,
+		Expired:   document.ValidTo != nil && document.ValidTo.Before(time.Now()),
+	}
+	if document.File != nil {
+		url := convertFilePathToURL(document.File)
+		response.File = &url
+	}
+	return response
+}
+
+// parseDate accepts either a plain date or an RFC3339 time
+func parseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+type createVehicleDocumentRequest struct {
+	Type      string `form:"type" binding:"required,oneof=insurance registration technical_inspection"`
+	Number    string `form:"number" binding:"required,max=100"`
+	Issuer    string `form:"issuer" binding:"max=200"`
+	ValidFrom string `form:"valid_from" binding:"omitempty,date"`
+	ValidTo   string `form:"valid_to" binding:"required,date"`
+}
+
+func (r createVehicleDocumentRequest) validate() []apperr.FieldError {
+	validFrom, _ := parseDate(r.ValidFrom)
+	validTo, _ := parseDate(r.ValidTo)
+	return timeOrder("valid_from", validFrom, "valid_to", validTo)
+}
+
+// toModel builds the document without its file, the date fields are already validated
+func (r createVehicleDocumentRequest) toModel(vehicleID uint) models.VehicleDocument {
+	validFrom, _ := parseDate(r.ValidFrom)
+	validTo, _ := parseDate(r.ValidTo)
+	return models.VehicleDocument{
+		VehicleID: &vehicleID,
+		Type:      parseString(r.Type),
+		Number:    parseString(r.Number),
+		Issuer:    parseString(r.Issuer),
+		ValidFrom: validFrom,
+		ValidTo:   validTo,
+	}
+}
+
+// CreateVehicleDocument godoc
+// @Summary Add a vehicle document
+// @Description Admins attach insurance policies, registration and technical inspection certificates to a vehicle
+// @Tags document
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Vehicle ID"
+// @Param type formData string true "insurance, registration or technical_inspection"
+// @Param number formData string true "Document number"
+// @Param issuer formData string false "Issuer"
+// @Param valid_from formData string false "Start of validity (YYYY-MM-DD)"
+// @Param valid_to formData string true "End of validity (YYYY-MM-DD)"
+// @Param file formData file false "Scanned document"
+// @Success 200 {object} vehicleDocumentResponse{}
+// @Router /vehicle/{id}/documents [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateVehicleDocument(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can add vehicle documents"))
+		return
+	}
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req createVehicleDocumentRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	document := req.toModel(vehicle.ID)
+
+	if file, err := c.FormFile("file"); err == nil {
+		filePath, err := s.saveFile(file, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		document.File = &filePath
+	}
+
+	if err := s.DB.WithContext(c.Request.Context()).Create(&document).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, newVehicleDocumentResponse(document))
+}
+
+// GetVehicleDocuments godoc
+// @Summary Get documents of a vehicle
+// @Description Admins and the assigned driver get the documents of a vehicle
+// @Tags document
+// @Produce json
+// @Param id path int true "Vehicle ID"
+// @Success 200 {array} []vehicleDocumentResponse{}
+// @Router /vehicle/{id}/documents [get]
+// @Security ApiKeyAuth
+func (s *Server) GetVehicleDocuments(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if authPayload.Role != "Admin" {
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if vehicle.AssignedDriver == nil || *vehicle.AssignedDriver != user.ID {
+			c.Error(apperr.Forbidden("only admins and the assigned driver can get vehicle documents"))
+			return
+		}
+	}
+	var documents []models.VehicleDocument
+	if err := s.DB.WithContext(c.Request.Context()).Where("vehicle_id = ?", vehicle.ID).Order("valid_to").Find(&documents).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]vehicleDocumentResponse, len(documents))
+	for i, document := range documents {
+		response[i] = newVehicleDocumentResponse(document)
+	}
+	c.JSON(200, response)
+}
+
+// GetExpiringDocuments godoc
+// @Summary Get expiring documents
+// @Description Returns documents that expire within the given period, including already expired ones
+// @Tags document
+// @Produce json
+// @Param within query string false "Period such as 30d, 2w or 72h, defaults to 30d"
+// @Success 200 {array} []vehicleDocumentResponse{}
+// @Router /documents/expiring [get]
+// @Security ApiKeyAuth
+func (s *Server) GetExpiringDocuments(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get expiring documents"))
+		return
+	}
+	within := s.config.DocumentExpiryWarning
+	if value := c.Query("within"); value != "" {
+		parsed, err := utils.ParsePeriod(value)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		within = parsed
+	}
+	var documents []models.VehicleDocument
+	if err := s.DB.WithContext(c.Request.Context()).Where("valid_to <= ?", time.Now().Add(within)).Order("valid_to").Find(&documents).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]vehicleDocumentResponse, len(documents))
+	for i, document := range documents {
+		response[i] = newVehicleDocumentResponse(document)
+	}
+	c.JSON(200, response)
+}
+
+// UpdateVehicleDocument godoc
+// @Summary Update a vehicle document
+// @Description Update the number, issuer or validity of a document, e.g. after renewal. Alerts of a renewed document are resolved.
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param id path int true "Document ID"
+// @Param document body updateVehicleDocumentRequest true "Document"
+// @Success 200 {object} vehicleDocumentResponse{}
+// @Router /documents/{id} [put]
+// @Security ApiKeyAuth
+func (s *Server) UpdateVehicleDocument(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can update vehicle documents"))
+		return
+	}
+	var document models.VehicleDocument
+	if err := s.DB.WithContext(c.Request.Context()).First(&document, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	var req updateVehicleDocumentRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Number != nil {
+		document.Number = req.Number
+	}
+	if req.Issuer != nil {
+		document.Issuer = req.Issuer
+	}
+	if req.ValidFrom != nil {
+		document.ValidFrom = req.ValidFrom
+	}
+	if req.ValidTo != nil {
+		document.ValidTo = req.ValidTo
+	}
+	if document.ValidFrom != nil && document.ValidTo.Before(*document.ValidFrom) {
+		c.Error(apperr.Invalid("valid_to", "valid_to must be after valid_from"))
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Save(&document).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if document.ValidTo.After(time.Now().Add(s.config.DocumentExpiryWarning)) {
+		if err := s.DB.WithContext(c.Request.Context()).Model(&models.Alert{}).
+			Where("document_id = ? AND resolved_at IS NULL", document.ID).
+			Update("resolved_at", time.Now()).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	c.JSON(200, newVehicleDocumentResponse(document))
+}
+
+// DeleteVehicleDocument godoc
+// @Summary Delete a vehicle document
+// @Description Delete a vehicle document and its file
+// @Tags document
+// @Produce json
+// @Param id path int true "Document ID"
+// @Success 200
+// @Router /documents/{id} [delete]
+// @Security ApiKeyAuth
+func (s *Server) DeleteVehicleDocument(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can delete vehicle documents"))
+		return
+	}
+	var document models.VehicleDocument
+	if err := s.DB.WithContext(c.Request.Context()).First(&document, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Delete(&document).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	s.deleteFileIfExists(document.File)
+	c.JSON(200, gin.H{})
+}
+
+// GetAlerts godoc
+// @Summary Get alerts
+// @Description Returns alerts raised by background checks, unresolved ones unless resolved=true
+// @Tags alert
+// @Produce json
+// @Param resolved query bool false "Return resolved alerts instead"
+// @Success 200 {array} []alertResponse{}
+// @Router /alerts [get]
+// @Security ApiKeyAuth
+func (s *Server) GetAlerts(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get alerts"))
+		return
+	}
+	query := s.DB.WithContext(c.Request.Context()).Order("created_at DESC")
+	if c.Query("resolved") == "true" {
+		query = query.Where("resolved_at IS NOT NULL")
+	} else {
+		query = query.Where("resolved_at IS NULL")
+	}
+	var alerts []models.Alert
+	if err := query.Find(&alerts).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]alertResponse, len(alerts))
+	for i, alert := range alerts {
+		response[i] = newAlertResponse(alert)
+	}
+	c.JSON(200, response)
+}
+
+// ResolveAlert godoc
+// @Summary Resolve an alert
+// @Description Marks an alert as resolved
+// @Tags alert
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Success 200 {object} alertResponse{}
+// @Router /alerts/{id}/resolve [post]
+// @Security ApiKeyAuth
+func (s *Server) ResolveAlert(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can resolve alerts"))
+		return
+	}
+	var alert models.Alert
+	if err := s.DB.WithContext(c.Request.Context()).First(&alert, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if alert.ResolvedAt == nil {
+		now := time.Now()
+		alert.ResolvedAt = &now
+		if err := s.DB.WithContext(c.Request.Context()).Save(&alert).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	c.JSON(200, newAlertResponse(alert))
+}
+
+// raiseAlert stores the alert unless an open alert of the same kind exists for the document, or for the
+// vehicle when the alert is not about a document, and reports whether it was created
+func (s *Server) raiseAlert(tx *gorm.DB, alert *models.Alert) (bool, error) {
+	query := tx.Model(&models.Alert{}).Where("kind = ? AND resolved_at IS NULL", alert.Kind)
+	if alert.DocumentID != nil {
+		query = query.Where("document_id = ?", *alert.DocumentID)
+	} else {
+		query = query.Where("vehicle_id = ? AND document_id IS NULL", alert.VehicleID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := tx.Create(alert).Error; err != nil {
+		return false, err
+	}
+	err := s.emit(tx, events.Event{
+		Type:      events.AlertRaised,
+		VehicleID: alert.VehicleID,
+		Data:      newAlertResponse(*alert),
+	})
+	return err == nil, err
+}
+
+// checkDocumentExpiry raises alerts for documents about to expire or already expired
+func (s *Server) checkDocumentExpiry(ctx context.Context) error {
+	now := time.Now()
+	var documents []models.VehicleDocument
+	if err := s.DB.WithContext(ctx).Where("valid_to <= ?", now.Add(s.config.DocumentExpiryWarning)).Find(&documents).Error; err != nil {
+		return err
+	}
+	var failures []error
+	for _, document := range documents {
+		documentID := document.ID
+		alert := models.Alert{
+			Kind:       alertDocumentExpiring,
+			Severity:   alertSeverityWarning,
+			Message:    fmt.Sprintf("%s %s of vehicle %d expires on %s", *document.Type, *document.Number, *document.VehicleID, document.ValidTo.Format("2006-01-02")),
+			VehicleID:  document.VehicleID,
+			DocumentID: &documentID,
+		}
+		if document.ValidTo.Before(now) {
+			alert.Kind = alertDocumentExpired
+			alert.Severity = alertSeverityCritical
+			alert.Message = fmt.Sprintf("%s %s of vehicle %d expired on %s", *document.Type, *document.Number, *document.VehicleID, document.ValidTo.Format("2006-01-02"))
+		}
+		err := s.transaction(ctx, func(tx *gorm.DB) error {
+			_, err := s.raiseAlert(tx, &alert)
+			return err
+		})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("document %d: %w", document.ID, err))
+		}
+	}
+	return errors.Join(failures...)
+}
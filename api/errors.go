@@ -0,0 +1,41 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+)
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code is one of bad_request, unauthorized, forbidden, not_found, conflict, payload_too_large,
+	// too_many_requests, validation_failed, internal or bad_gateway
+	Code   apperr.Code         `json:"code"`
+	Fields []apperr.FieldError `json:"fields,omitempty"`
+}
+
+// errorMiddleware renders the error a handler recorded with c.Error. Handlers record the error
+// and return; known errors are mapped to their status code and internal ones are logged and hidden.
+func errorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := apperr.From(c.Errors.Last().Err)
+		switch err.Code {
+		case apperr.CodeInternal:
+			slog.ErrorContext(c.Request.Context(), "internal error", slog.Any("error", err.Err))
+		case apperr.CodeBadGateway:
+			slog.ErrorContext(c.Request.Context(), err.Message, slog.Any("error", err.Err))
+		}
+		if err.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+		}
+		c.AbortWithStatusJSON(err.Status(), ErrorResponse{Error: err.Message, Code: err.Code, Fields: err.Fields})
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/outbox"
+	"github.com/rassulmagauin/VMS_SWE/store"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"gorm.io/gorm"
+)
+
+const eventStreamHeartbeat = 25 * time.Second
+
+// queryTokenMiddleware lets EventSource clients, which cannot set headers, pass the token as ?access_token=
+func queryTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(authorizationHeaderKey) == "" {
+			if accessToken := c.Query("access_token"); accessToken != "" {
+				c.Request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+accessToken)
+			}
+		}
+		c.Next()
+	}
+}
+
+// eventFilter decides which events a user of the given role may receive
+func eventFilter(role string, userID uint) func(events.Event) bool {
+	switch role {
+	case "Admin":
+		return nil
+	case "Driver":
+		return func(evt events.Event) bool {
+			return evt.DriverID != nil && *evt.DriverID == userID
+		}
+	case "Fueling_person":
+		return func(evt events.Event) bool {
+			return evt.Category() == "fueling" || evt.Category() == "vehicle"
+		}
+	case "Maintenance_person":
+		return func(evt events.Event) bool {
+			return evt.Category() == "maintenance" || evt.Category() == "vehicle"
+		}
+	}
+	return func(events.Event) bool { return false }
+}
+
+// StreamEvents godoc
+// @Summary Stream fleet events
+// @Description Server-Sent Events stream of task, vehicle, fueling and tracking events. Drivers only receive their own events. The token may be passed as access_token query parameter.
+// @Tags events
+// @Produce text/event-stream
+// @Param access_token query string false "Access token for clients that cannot set headers"
+// @Success 200 {object} events.Event{}
+// @Router /events [get]
+// @Security ApiKeyAuth
+func (s *Server) StreamEvents(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	sub := s.events.Subscribe(eventFilter(authPayload.Role, user.ID))
+	defer s.events.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// the stream stays open far longer than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(c.Request.Context(), "cannot lift the write deadline of an event stream", slog.Any("error", err))
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	// the stream must not outlive the token that opened it
+	expiry := time.NewTimer(time.Until(authPayload.ExpiredAt))
+	defer expiry.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-expiry.C:
+			c.SSEvent("expired", gin.H{"error": "token is expired"})
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"time": time.Now()})
+			return true
+		case evt, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent(evt.Type, evt)
+			return true
+		}
+	})
+}
+
+// transaction runs fn in a database transaction and wakes the outbox dispatcher once it commits,
+// events written with emit inside fn are therefore published exactly when the changes are stored
+func (s *Server) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	if err := s.DB.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+	s.outbox.Wake()
+	return nil
+}
+
+// storeTransaction is transaction for handlers using the store, events are written with tx.WriteEvent
+func (s *Server) storeTransaction(ctx context.Context, fn func(tx store.Store) error) error {
+	if err := s.store.Transaction(ctx, fn); err != nil {
+		return err
+	}
+	s.outbox.Wake()
+	return nil
+}
+
+// emit writes the event to the outbox within tx
+func (s *Server) emit(tx *gorm.DB, evt events.Event) error {
+	return outbox.Write(tx, evt)
+}
+
+// publishLive sends an event straight to stream subscribers, bypassing the outbox.
+// It is meant for high volume events that are only useful in real time, like location pings.
+func (s *Server) publishLive(evt events.Event) {
+	s.events.Publish(evt)
+}
+
+func (s *Server) publishVehicleStatus(ctx context.Context, tx store.Store, vehicle models.Vehicle, previousStatus *string) error {
+	if previousStatus != nil && vehicle.Status != nil && *previousStatus == *vehicle.Status {
+		return nil
+	}
+	return tx.WriteEvent(ctx, events.Event{
+		Type:      events.VehicleStatusChanged,
+		VehicleID: &vehicle.ID,
+		DriverID:  vehicle.AssignedDriver,
+		Data: gin.H{
+			"vehicle_id":      vehicle.ID,
+			"status":          vehicle.Status,
+			"previous_status": previousStatus,
+		},
+	})
+}
+
+func (s *Server) publishTaskStatus(ctx context.Context, tx store.Store, task models.Task, previousStatus *string) error {
+	if previousStatus != nil && task.Status != nil && *previousStatus == *task.Status {
+		return nil
+	}
+	return tx.WriteEvent(ctx, events.Event{
+		Type:      events.TaskStatusChanged,
+		VehicleID: task.VehicleID,
+		DriverID:  task.DriverID,
+		Data: gin.H{
+			"task_id":         task.ID,
+			"status":          task.Status,
+			"previous_status": previousStatus,
+		},
+	})
+}
+
+// publishTaskAssigned announces that the task was handed to its driver
+func (s *Server) publishTaskAssigned(ctx context.Context, tx store.Store, task models.Task) error {
+	if task.DriverID == nil {
+		return nil
+	}
+	var startTime string
+	if task.StartTime != nil {
+		startTime = task.StartTime.Format("2006-01-02 15:04")
+	}
+	return tx.WriteEvent(ctx, events.Event{
+		Type:      events.TaskAssigned,
+		VehicleID: task.VehicleID,
+		DriverID:  task.DriverID,
+		Data: gin.H{
+			"task_id":    task.ID,
+			"driver_id":  *task.DriverID,
+			"start_time": startTime,
+		},
+	})
+}
+
+// streamEvent is the outbox subscriber feeding the in-process hub behind /events
+func (s *Server) streamEvent(evt events.Event) error {
+	s.events.Publish(evt)
+	return nil
+}
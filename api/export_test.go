@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+
+	"github.com/rassulmagauin/VMS_SWE/notify"
+)
+
+// The tests of package api_test run on the apitest harness, which imports this package. They
+// reach the request and response types, the mailer and the pending emails through these.
+
+type (
+	LoginRequest           = loginRequest
+	LoginResponse          = loginResponse
+	CreateUserRequest      = createUserRequest
+	UpdateUserRequest      = updateUserRequest
+	ChangePasswordRequest  = changePasswordRequest
+	ChangePasswordResponse = changePasswordResponse
+	ForgotPasswordRequest  = forgotPasswordRequest
+	ResetPasswordRequest   = resetPasswordRequest
+	AssignVehicleRequest   = assignVehicleRequest
+	VehicleResponse        = vehicleResponse
+	TaskResponse           = taskResponse
+	HealthResponse         = healthResponse
+)
+
+const (
+	RequestIDHeader          = requestIDHeader
+	RateLimitRemainingHeader = rateLimitRemainingHeader
+)
+
+// SetMailer makes the server send its emails to mailer
+func SetMailer(s *Server, mailer notify.Mailer) {
+	s.mailer = mailer
+}
+
+// WaitForEmails waits for the emails being sent, like Shutdown does
+func WaitForEmails(s *Server, ctx context.Context) error {
+	return s.waitForEmails(ctx)
+}
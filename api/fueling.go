@@ -1,18 +1,19 @@
 package api
 
 import (
-	"errors"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
 	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
@@ -42,13 +43,59 @@ type FuelingRecordResponse struct {
 	AfterFuelingImage  *string   `json:"after_fueling_image"`
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
-	// Include other fields from gorm.Model if needed
-	// If Vehicle is a detailed object and you want to include it in the response, define a corresponding struct
-	// Vehicle            *VehicleResponse `json:"vehicle,omitempty"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// newFuelingRecordResponse returns the record with its images as URLs below /static
+func newFuelingRecordResponse(fueling models.FuelingRecord) FuelingRecordResponse {
+	response := FuelingRecordResponse{
+		ID:              fueling.ID,
+		VehicleID:       fueling.VehicleID,
+		FuelingPersonID: fueling.FuelingPersonID,
+		Amount:          fueling.Amount,
+		TotalCost:       fueling.TotalCost,
+		GasStation:      fueling.GasStation,
+		Notes:           fueling.Notes,
+		CreatedAt:       fueling.CreatedAt,
+		UpdatedAt:       fueling.UpdatedAt,
+	}
+	if fueling.BeforeFuelingImage != nil {
+		url := convertFilePathToURL(fueling.BeforeFuelingImage)
+		response.BeforeFuelingImage = &url
+	}
+	if fueling.AfterFuelingImage != nil {
+		url := convertFilePathToURL(fueling.AfterFuelingImage)
+		response.AfterFuelingImage = &url
+	}
+	return response
+}
+
+func newFuelingRecordResponses(fuelings []models.FuelingRecord) []FuelingRecordResponse {
+	response := make([]FuelingRecordResponse, len(fuelings))
+	for i, fueling := range fuelings {
+		response[i] = newFuelingRecordResponse(fueling)
+	}
+	return response
+}
+
+type createFuelingRecordRequest struct {
+	VehicleID       *uint    `form:"vehicle_id" binding:"required"`
+	FuelingPersonID *uint    `form:"fueling_person_id" binding:"required"`
+	Amount          *float64 `form:"amount" binding:"required,gt=0"`
+	TotalCost       *float64 `form:"total_cost" binding:"required,gte=0"`
+	GasStation      string   `form:"gas_station" binding:"max=100"`
+	Notes           string   `form:"notes" binding:"max=1000"`
+}
+
+// toModel builds the record without its images, they are saved separately
+func (r createFuelingRecordRequest) toModel() models.FuelingRecord {
+	return models.FuelingRecord{
+		VehicleID:       r.VehicleID,
+		FuelingPersonID: r.FuelingPersonID,
+		Amount:          r.Amount,
+		TotalCost:       r.TotalCost,
+		GasStation:      parseString(r.GasStation),
+		Notes:           parseString(r.Notes),
+	}
 }
 
 // CreateFuelingRecord godoc
@@ -73,124 +120,81 @@ type ErrorResponse struct {
 func (s *Server) CreateFuelingRecord(c *gin.Context) {
 
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Fueling" {
-		c.JSON(400, errorResponse(errors.New("only admins and fueling can create fueling records")))
-		return
-	}
-	// Initialize your FuelingRecord struct
-	var fueling models.FuelingRecord
-
-	// Manually handle non-file fields
-	// Handling vehicle_id
-	vehicleIDStr := c.PostForm("vehicle_id")
-	if vehicleIDStr != "" {
-		vehicleID, err := strconv.ParseUint(vehicleIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle_id must be a number"})
-			return
-		}
-		vehicleIDUint := uint(vehicleID)
-		fueling.VehicleID = &vehicleIDUint
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle_id is required"})
-		return
-	}
-
-	// Handling fueling_person_id
-	fuelingPersonIDStr := c.PostForm("fueling_person_id")
-	if fuelingPersonIDStr != "" {
-		fuelingPersonID, err := strconv.ParseUint(fuelingPersonIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "fueling_person_id must be a number"})
-			return
-		}
-		fuelingPersonIDUint := uint(fuelingPersonID)
-		fueling.FuelingPersonID = &fuelingPersonIDUint
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fueling_person_id is required"})
+	if authPayload.Role != "Admin" && authPayload.Role != "Fueling_person" {
+		c.Error(apperr.Forbidden("only admins and fueling can create fueling records"))
 		return
 	}
-
-	// Handling amount
-	amountStr := c.PostForm("amount")
-	if amountStr != "" {
-		amount, err := strconv.ParseFloat(amountStr, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a valid number"})
-			return
-		}
-		fueling.Amount = &amount
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "amount is required"})
+	var req createFuelingRecordRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-
-	// Handling total_cost
-	totalCostStr := c.PostForm("total_cost")
-	if totalCostStr != "" {
-		totalCost, err := strconv.ParseFloat(totalCostStr, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "total_cost must be a valid number"})
-			return
-		}
-		fueling.TotalCost = &totalCost
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "total_cost is required"})
-		return
-	}
-
-	// Handling optional string fields: GasStation and Notes
-	gasStation := c.PostForm("gas_station")
-	if gasStation != "" {
-		fueling.GasStation = &gasStation
-	}
-
-	notes := c.PostForm("notes")
-	if notes != "" {
-		fueling.Notes = &notes
-	}
+	fueling := req.toModel()
 
 	// Handle file upload for BeforeFuelingImage
 	beforeFuelingImage, err := c.FormFile("before_fueling_image")
 	if err == nil { // If there's a file
-		beforeImagePath, err := saveFile(beforeFuelingImage, c)
+		beforeImagePath, err := s.saveFile(beforeFuelingImage, c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		fueling.BeforeFuelingImage = &beforeImagePath
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "before_fueling_image is required"})
+		c.Error(apperr.Invalid("before_fueling_image", "before_fueling_image is required"))
 		return
 	}
 
 	// Handle file upload for AfterFuelingImage
 	afterFuelingImage, err := c.FormFile("after_fueling_image")
 	if err == nil { // If there's a file
-		afterImagePath, err := saveFile(afterFuelingImage, c)
+		afterImagePath, err := s.saveFile(afterFuelingImage, c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		fueling.AfterFuelingImage = &afterImagePath
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "after_fueling_image is required"})
+		c.Error(apperr.Invalid("after_fueling_image", "after_fueling_image is required"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	vehicle, err := s.store.Vehicles().Get(ctx, *fueling.VehicleID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Save the record in the database
-	if err := s.DB.Create(&fueling).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	err = s.storeTransaction(ctx, func(tx store.Store) error {
+		if err := tx.Fuelings().Create(ctx, &fueling); err != nil {
+			return err
+		}
+		return tx.WriteEvent(ctx, events.Event{
+			Type:      events.FuelingCreated,
+			VehicleID: fueling.VehicleID,
+			DriverID:  vehicle.AssignedDriver,
+			Data:      newFuelingRecordResponse(fueling),
+		})
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, fueling)
+	c.JSON(http.StatusOK, newFuelingRecordResponse(fueling))
 }
 
-func saveFile(file *multipart.FileHeader, c *gin.Context) (string, error) {
+// saveFile stores an uploaded file and returns the name it is served under in /static
+func (s *Server) saveFile(file *multipart.FileHeader, c *gin.Context) (string, error) {
+	if file.Size > s.config.Uploads.MaxFileSize {
+		return "", apperr.TooLarge("file %s is too large, the limit is %d bytes", file.Filename, s.config.Uploads.MaxFileSize)
+	}
+	s.metrics.uploadSize.Observe(float64(file.Size))
 	// Create a unique filename to avoid conflicts
 	newFileName := uuid.New().String() + filepath.Ext(file.Filename)
-	filePath := filepath.Join("./uploads", newFileName)
+	filePath := filepath.Join(s.config.Storage.Dir, newFileName)
 
 	// Save the file
 	if err := c.SaveUploadedFile(file, filePath); err != nil {
@@ -213,27 +217,13 @@ func saveFile(file *multipart.FileHeader, c *gin.Context) (string, error) {
 // @Router /fueling/{id} [get]
 // @Security ApiKeyAuth
 func (s *Server) GetFuelingRecord(c *gin.Context) {
-	// Get ID from URL parameter
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+	fueling, err := s.store.Fuelings().Get(c.Request.Context(), idParam(c, "id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		c.Error(err)
 		return
 	}
 
-	var fueling models.FuelingRecord
-	if err := s.DB.First(&fueling, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
-		return
-	}
-
-	// Assuming you have a function to convert file paths to URLs
-	temp1 := convertFilePathToURL(fueling.BeforeFuelingImage)
-	temp2 := convertFilePathToURL(fueling.AfterFuelingImage)
-	fueling.BeforeFuelingImage = &temp1
-	fueling.AfterFuelingImage = &temp2
-
-	c.JSON(http.StatusOK, fueling)
+	c.JSON(http.StatusOK, newFuelingRecordResponse(fueling))
 }
 func convertFilePathToURL(filePath *string) string {
 	if filePath == nil {
@@ -249,36 +239,24 @@ func convertFilePathToURL(filePath *string) string {
 // @Description Get all fueling records
 // @Tags fueling
 // @Produce  json
-// @Success 200 {object} []FuelingRecordResponse "Successful response with fueling record details"
+// @Success 200 {array} FuelingRecordResponse "Successful response with fueling record details"
 // @Failure 400 {object} ErrorResponse "Bad Request with error message"
 // @Router /fueling [get]
 // @Security ApiKeyAuth
 func (s *Server) GetFuelingRecords(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" && authPayload.Role != "Fueling" {
-		c.JSON(http.StatusBadRequest, errorResponse(errors.New("only admins and fueling can get fueling records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Fueling_person" {
+		c.Error(apperr.Forbidden("only admins and fueling can get fueling records"))
 		return
 	}
 
-	var fuelings []models.FuelingRecord
-	if err := s.DB.Find(&fuelings).Error; err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+	fuelings, err := s.store.Fuelings().List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Convert file paths to URLs for each record
-	for i := range fuelings {
-		if fuelings[i].BeforeFuelingImage != nil {
-			temo1 := convertFilePathToURL(fuelings[i].BeforeFuelingImage)
-			fuelings[i].BeforeFuelingImage = &temo1
-		}
-		if fuelings[i].AfterFuelingImage != nil {
-			temp2 := convertFilePathToURL(fuelings[i].AfterFuelingImage)
-			fuelings[i].AfterFuelingImage = &temp2
-		}
-	}
-
-	c.JSON(http.StatusOK, fuelings)
+	c.JSON(http.StatusOK, newFuelingRecordResponses(fuelings))
 }
 
 // Include your saveFile function here
@@ -295,37 +273,29 @@ func (s *Server) GetFuelingRecords(c *gin.Context) {
 // @Router /fueling/{id} [delete]
 // @Security ApiKeyAuth
 func (s *Server) DeleteFuelingRecord(c *gin.Context) {
-	// Get ID from URL parameter
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
-		return
-	}
-
 	// Retrieve the record from the database
-	var fueling models.FuelingRecord
-	if err := s.DB.First(&fueling, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+	fueling, err := s.store.Fuelings().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Delete the image files if they exist
-	deleteFileIfExists(fueling.BeforeFuelingImage)
-	deleteFileIfExists(fueling.AfterFuelingImage)
+	s.deleteFileIfExists(fueling.BeforeFuelingImage)
+	s.deleteFileIfExists(fueling.AfterFuelingImage)
 
 	// Delete the record from the database
-	if err := s.DB.Delete(&fueling).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete record"})
+	if err := s.store.Fuelings().Delete(c.Request.Context(), fueling.ID); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "record deleted"})
 }
 
-func deleteFileIfExists(filePath *string) {
+func (s *Server) deleteFileIfExists(filePath *string) {
 	if filePath != nil {
-		fullPath := "./uploads/" + *filePath // Adjust the path according to your directory structure
+		fullPath := filepath.Join(s.config.Storage.Dir, *filePath)
 		if _, err := os.Stat(fullPath); err == nil {
 			os.Remove(fullPath)
 		}
@@ -334,28 +304,28 @@ func deleteFileIfExists(filePath *string) {
 
 func (s *Server) GetFuelingRecordsOfVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Fueling" {
-		c.JSON(400, errorResponse(errors.New("only admins and fueling can get fueling records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Fueling_person" {
+		c.Error(apperr.Forbidden("only admins and fueling can get fueling records"))
 		return
 	}
-	var fueling []models.FuelingRecord
-	if err := s.DB.Where("vehicle_id = ?", c.Param("vehicle_id")).Find(&fueling).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	fueling, err := s.store.Fuelings().ListByVehicle(c.Request.Context(), idParam(c, "vehicle_id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, fueling)
+	c.JSON(200, newFuelingRecordResponses(fueling))
 }
 
 func (s *Server) GetFuelingRecordsOfUser(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Fueling" {
-		c.JSON(400, errorResponse(errors.New("only admins and fueling can get fueling records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Fueling_person" {
+		c.Error(apperr.Forbidden("only admins and fueling can get fueling records"))
 		return
 	}
-	var fueling []models.FuelingRecord
-	if err := s.DB.Where("user_id = ?", c.Param("user_id")).Find(&fueling).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	fueling, err := s.store.Fuelings().ListByPerson(c.Request.Context(), idParam(c, "user_id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, fueling)
+	c.JSON(200, newFuelingRecordResponses(fueling))
 }
@@ -0,0 +1,609 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/geo"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"gorm.io/gorm"
+)
+
+const (
+	geofenceCategoryDepot         = "depot"
+	geofenceCategoryCustomerSite  = "customer_site"
+	geofenceCategoryRestricted    = "restricted"
+	geofenceCategoryOperatingArea = "operating_area"
+
+	geofenceShapeCircle  = "circle"
+	geofenceShapePolygon = "polygon"
+
+	geofenceEventEnter     = "enter"
+	geofenceEventExit      = "exit"
+	geofenceEventViolation = "violation"
+
+	violationRestrictedZone     = "restricted_zone"
+	violationOutsideAllowedArea = "outside_allowed_area"
+	violationOutsideHours       = "outside_permitted_hours"
+
+	// a waypoint counts as reached once a ping is this close, in meters
+	waypointArrivalRadius = 150.0
+	maxGeofenceEvents     = 500
+	permittedHoursLayout  = "15:04"
+)
+
+// geofenceRequest creates a geofence, or on update changes only the fields that are present.
+// Rules depending on the shape are checked on the resulting geofence by validateGeofence.
+type geofenceRequest struct {
+	Name            *string     `json:"name" binding:"omitempty,min=1,max=100"`
+	Category        *string     `json:"category" enums:"depot,customer_site,restricted,operating_area" binding:"omitempty,oneof=depot customer_site restricted operating_area"`
+	Shape           *string     `json:"shape" enums:"circle,polygon" binding:"omitempty,oneof=circle polygon"`
+	CenterLatitude  *float64    `json:"center_latitude" binding:"omitempty,gte=-90,lte=90"`
+	CenterLongitude *float64    `json:"center_longitude" binding:"omitempty,gte=-180,lte=180"`
+	RadiusMeters    *float64    `json:"radius_meters" binding:"omitempty,gt=0"`
+	Polygon         []geo.Point `json:"polygon" binding:"max=500"`
+	PermittedFrom   *string     `json:"permitted_from" example:"06:00" binding:"omitempty,hhmm"`
+	PermittedTo     *string     `json:"permitted_to" example:"22:00" binding:"omitempty,hhmm"`
+	// TimeZone is the IANA time zone of the permitted hours, UTC when unset
+	TimeZone *string `json:"time_zone" example:"Asia/Almaty" binding:"omitempty,timezone"`
+	Active   *bool   `json:"active"`
+}
+
+func (r geofenceRequest) validate() []apperr.FieldError {
+	var fields []apperr.FieldError
+	for i, point := range r.Polygon {
+		if point.Latitude < -90 || point.Latitude > 90 || point.Longitude < -180 || point.Longitude > 180 {
+			fields = append(fields, apperr.FieldError{
+				Field:   fmt.Sprintf("polygon[%d]", i),
+				Message: fmt.Sprintf("point %d: coordinates are out of range", i),
+			})
+		}
+	}
+	return fields
+}
+
+func (r geofenceRequest) apply(fence *models.Geofence) {
+	setIfPresent(&fence.Name, r.Name)
+	setIfPresent(&fence.Category, r.Category)
+	setIfPresent(&fence.Shape, r.Shape)
+	setIfPresent(&fence.CenterLatitude, r.CenterLatitude)
+	setIfPresent(&fence.CenterLongitude, r.CenterLongitude)
+	setIfPresent(&fence.RadiusMeters, r.RadiusMeters)
+	if r.Polygon != nil {
+		fence.Polygon = r.Polygon
+	}
+	setIfPresent(&fence.PermittedFrom, r.PermittedFrom)
+	setIfPresent(&fence.PermittedTo, r.PermittedTo)
+	setIfPresent(&fence.TimeZone, r.TimeZone)
+	setIfPresent(&fence.Active, r.Active)
+}
+
+type geofenceResponse struct {
+	ID uint `json:"ID"`
+	geofenceRequest
+}
+
+func newGeofenceResponse(fence models.Geofence) geofenceResponse {
+	return geofenceResponse{
+		ID: fence.ID,
+		geofenceRequest: geofenceRequest{
+			Name:            fence.Name,
+			Category:        fence.Category,
+			Shape:           fence.Shape,
+			CenterLatitude:  fence.CenterLatitude,
+			CenterLongitude: fence.CenterLongitude,
+			RadiusMeters:    fence.RadiusMeters,
+			Polygon:         fence.Polygon,
+			PermittedFrom:   fence.PermittedFrom,
+			PermittedTo:     fence.PermittedTo,
+			TimeZone:        fence.TimeZone,
+			Active:          fence.Active,
+		},
+	}
+}
+
+type geofenceEventResponse struct {
+	ID         uint      `json:"ID"`
+	GeofenceID *uint     `json:"geofence_id"`
+	VehicleID  *uint     `json:"vehicle_id"`
+	DriverID   *uint     `json:"driver_id"`
+	TaskID     *uint     `json:"task_id"`
+	Type       string    `json:"type" enums:"enter,exit,violation"`
+	Reason     *string   `json:"reason"`
+	Latitude   *float64  `json:"latitude"`
+	Longitude  *float64  `json:"longitude"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func newGeofenceEventResponse(event models.GeofenceEvent) geofenceEventResponse {
+	return geofenceEventResponse{
+		ID:         event.ID,
+		GeofenceID: event.GeofenceID,
+		VehicleID:  event.VehicleID,
+		DriverID:   event.DriverID,
+		TaskID:     event.TaskID,
+		Type:       event.Type,
+		Reason:     event.Reason,
+		Latitude:   event.Latitude,
+		Longitude:  event.Longitude,
+		OccurredAt: event.OccurredAt,
+	}
+}
+
+func validateGeofence(fence *models.Geofence) error {
+	if fence.Name == nil || *fence.Name == "" {
+		return apperr.Invalid("name", "name is required")
+	}
+	if fence.Category == nil {
+		return apperr.Invalid("category", "category is required")
+	}
+	switch *fence.Category {
+	case geofenceCategoryDepot, geofenceCategoryCustomerSite, geofenceCategoryRestricted, geofenceCategoryOperatingArea:
+	default:
+		return apperr.Invalid("category", "unknown category %q", *fence.Category)
+	}
+	if fence.Shape == nil {
+		return apperr.Invalid("shape", "shape is required")
+	}
+	switch *fence.Shape {
+	case geofenceShapeCircle:
+		if fence.CenterLatitude == nil || fence.CenterLongitude == nil || fence.RadiusMeters == nil || *fence.RadiusMeters <= 0 {
+			return apperr.Invalid("radius", "circle geofences need a center and a positive radius")
+		}
+	case geofenceShapePolygon:
+		if len(fence.Polygon) < 3 {
+			return apperr.Invalid("points", "polygon geofences need at least 3 points")
+		}
+	default:
+		return apperr.Invalid("shape", "unknown shape %q", *fence.Shape)
+	}
+	if (fence.PermittedFrom == nil) != (fence.PermittedTo == nil) {
+		return apperr.Invalid("permitted_to", "permitted_from and permitted_to must be set together")
+	}
+	if fence.PermittedFrom != nil {
+		if _, err := time.Parse(permittedHoursLayout, *fence.PermittedFrom); err != nil {
+			return apperr.Invalid("permitted_from", "permitted_from must be formatted as HH:MM")
+		}
+		if _, err := time.Parse(permittedHoursLayout, *fence.PermittedTo); err != nil {
+			return apperr.Invalid("permitted_to", "permitted_to must be formatted as HH:MM")
+		}
+	}
+	if fence.TimeZone != nil {
+		// Local would be the zone of whichever replica evaluates the ping
+		if _, err := geofenceLocation(*fence.TimeZone); err != nil || *fence.TimeZone == "Local" {
+			return apperr.Invalid("time_zone", "unknown time zone %q", *fence.TimeZone)
+		}
+	}
+	return nil
+}
+
+// CreateGeofence godoc
+// @Summary Create a geofence
+// @Description Admins define circle or polygon geofences such as depots, customer sites, restricted zones and operating areas
+// @Tags geofence
+// @Accept  json
+// @Produce  json
+// @Param geofence body geofenceRequest true "Geofence"
+// @Success 200 {object} geofenceResponse{}
+// @Router /geofence [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateGeofence(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can create geofences"))
+		return
+	}
+	var req geofenceRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	var fence models.Geofence
+	req.apply(&fence)
+	if err := validateGeofence(&fence); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Create(&fence).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newGeofenceResponse(fence))
+}
+
+// GetGeofences godoc
+// @Summary Get all geofences
+// @Description Get all geofences
+// @Tags geofence
+// @Produce  json
+// @Success 200 {array} geofenceResponse{}
+// @Router /geofence [get]
+// @Security ApiKeyAuth
+func (s *Server) GetGeofences(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get geofences"))
+		return
+	}
+	var fences []models.Geofence
+	if err := s.DB.WithContext(c.Request.Context()).Find(&fences).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]geofenceResponse, len(fences))
+	for i, fence := range fences {
+		response[i] = newGeofenceResponse(fence)
+	}
+	c.JSON(200, response)
+}
+
+// GetGeofence godoc
+// @Summary Get a geofence
+// @Description Get a geofence
+// @Tags geofence
+// @Produce  json
+// @Param id path int true "Geofence ID"
+// @Success 200 {object} geofenceResponse{}
+// @Router /geofence/{id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetGeofence(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get geofences"))
+		return
+	}
+	var fence models.Geofence
+	if err := s.DB.WithContext(c.Request.Context()).First(&fence, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newGeofenceResponse(fence))
+}
+
+// UpdateGeofence godoc
+// @Summary Update a geofence
+// @Description Update a geofence
+// @Tags geofence
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Geofence ID"
+// @Param geofence body geofenceRequest true "Geofence"
+// @Success 200 {object} geofenceResponse{}
+// @Router /geofence/{id} [put]
+// @Security ApiKeyAuth
+func (s *Server) UpdateGeofence(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can update geofences"))
+		return
+	}
+	var fence models.Geofence
+	if err := s.DB.WithContext(c.Request.Context()).First(&fence, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	var req geofenceRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	req.apply(&fence)
+	if err := validateGeofence(&fence); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Save(&fence).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newGeofenceResponse(fence))
+}
+
+// DeleteGeofence godoc
+// @Summary Delete a geofence
+// @Description Delete a geofence
+// @Tags geofence
+// @Produce  json
+// @Param id path int true "Geofence ID"
+// @Success 200
+// @Router /geofence/{id} [delete]
+// @Security ApiKeyAuth
+func (s *Server) DeleteGeofence(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can delete geofences"))
+		return
+	}
+	var fence models.Geofence
+	if err := s.DB.WithContext(c.Request.Context()).First(&fence, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Delete(&fence).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+// GetGeofenceEvents godoc
+// @Summary Get geofence events
+// @Description Returns the newest enter, exit and violation events, optionally filtered
+// @Tags geofence
+// @Produce  json
+// @Param vehicle_id query int false "Vehicle ID"
+// @Param geofence_id query int false "Geofence ID"
+// @Param type query string false "enter, exit or violation"
+// @Param from query string false "RFC3339 start time"
+// @Param to query string false "RFC3339 end time"
+// @Success 200 {array} geofenceEventResponse{}
+// @Router /geofence/events [get]
+// @Security ApiKeyAuth
+func (s *Server) GetGeofenceEvents(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get geofence events"))
+		return
+	}
+	query := s.DB.WithContext(c.Request.Context()).Order("occurred_at DESC").Limit(maxGeofenceEvents)
+	if vehicleID := c.Query("vehicle_id"); vehicleID != "" {
+		query = query.Where("vehicle_id = ?", vehicleID)
+	}
+	if geofenceID := c.Query("geofence_id"); geofenceID != "" {
+		query = query.Where("geofence_id = ?", geofenceID)
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	for _, bound := range []struct{ param, condition string }{
+		{"from", "occurred_at >= ?"},
+		{"to", "occurred_at <= ?"},
+	} {
+		if value := c.Query(bound.param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.Error(apperr.Invalid(bound.param, "%s must be an RFC3339 time", bound.param))
+				return
+			}
+			query = query.Where(bound.condition, parsed)
+		}
+	}
+	var geofenceEvents []models.GeofenceEvent
+	if err := query.Find(&geofenceEvents).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]geofenceEventResponse, len(geofenceEvents))
+	for i, geofenceEvent := range geofenceEvents {
+		response[i] = newGeofenceEventResponse(geofenceEvent)
+	}
+	c.JSON(200, response)
+}
+
+// geofenceState describes where a single position lies relative to all geofences
+type geofenceState struct {
+	inside            map[uint]bool
+	hasOperatingAreas bool
+	inOperatingArea   bool
+	// hoursViolation is the geofence whose permitted hours exclude the position time, if any
+	hoursViolation *uint
+}
+
+func (st *geofenceState) permitted() bool {
+	return st.hoursViolation == nil
+}
+
+func pingPoint(ping models.LocationPing) geo.Point {
+	return geo.Point{Latitude: *ping.Latitude, Longitude: *ping.Longitude}
+}
+
+func geofenceContains(fence models.Geofence, p geo.Point) bool {
+	if *fence.Shape == geofenceShapeCircle {
+		center := geo.Point{Latitude: *fence.CenterLatitude, Longitude: *fence.CenterLongitude}
+		return geo.WithinRadius(center, p, *fence.RadiusMeters)
+	}
+	return geo.InPolygon(fence.Polygon, p)
+}
+
+// geofenceLocations caches the time zones of geofences by name, pings are checked against them in bulk
+var geofenceLocations sync.Map
+
+// geofenceLocation loads the time zone of permitted hours
+func geofenceLocation(name string) (*time.Location, error) {
+	if location, ok := geofenceLocations.Load(name); ok {
+		return location.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	geofenceLocations.Store(name, location)
+	return location, nil
+}
+
+// permittedHoursTime returns t on the clock of the geofence's time zone, UTC when it has none
+func permittedHoursTime(fence models.Geofence, t time.Time) time.Time {
+	if fence.TimeZone == nil {
+		return t.UTC()
+	}
+	location, err := geofenceLocation(*fence.TimeZone)
+	if err != nil {
+		return t.UTC()
+	}
+	return t.In(location)
+}
+
+// withinPermittedHours reports whether t falls in the window, windows like 22:00-06:00 wrap midnight
+func withinPermittedHours(from, to string, t time.Time) bool {
+	start, err := time.Parse(permittedHoursLayout, from)
+	if err != nil {
+		return true
+	}
+	end, err := time.Parse(permittedHoursLayout, to)
+	if err != nil {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
+
+func evaluateGeofenceState(fences []models.Geofence, ping models.LocationPing) geofenceState {
+	state := geofenceState{inside: make(map[uint]bool)}
+	p := pingPoint(ping)
+	for _, fence := range fences {
+		inside := geofenceContains(fence, p)
+		state.inside[fence.ID] = inside
+		if *fence.Category == geofenceCategoryOperatingArea {
+			state.hasOperatingAreas = true
+			state.inOperatingArea = state.inOperatingArea || inside
+		}
+		if inside && state.hoursViolation == nil && fence.PermittedFrom != nil && fence.PermittedTo != nil &&
+			!withinPermittedHours(*fence.PermittedFrom, *fence.PermittedTo, permittedHoursTime(fence, ping.RecordedAt)) {
+			id := fence.ID
+			state.hoursViolation = &id
+		}
+	}
+	return state
+}
+
+// evaluatePositions emits geofence events and marks task waypoints as arrived for a batch of pings of one vehicle.
+// previous is the last position known before the batch and is used to detect transitions.
+// Everything is written through tx together with the pings.
+func (s *Server) evaluatePositions(tx *gorm.DB, previous *models.LocationPing, pings []models.LocationPing) error {
+	if len(pings) == 0 {
+		return nil
+	}
+	var fences []models.Geofence
+	if err := tx.Where("active = ?", true).Find(&fences).Error; err != nil {
+		return err
+	}
+	var waypoints []models.TaskWaypoint
+	if taskID := pings[0].TaskID; taskID != nil {
+		if err := tx.Where("task_id = ? AND arrived_at IS NULL", *taskID).Order("sequence").Find(&waypoints).Error; err != nil {
+			return err
+		}
+	}
+	if len(fences) == 0 && len(waypoints) == 0 {
+		return nil
+	}
+
+	ordered := make([]models.LocationPing, len(pings))
+	copy(ordered, pings)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].RecordedAt.Before(ordered[j].RecordedAt)
+	})
+
+	var prevState *geofenceState
+	if previous != nil {
+		state := evaluateGeofenceState(fences, *previous)
+		prevState = &state
+	}
+	var geofenceEvents []models.GeofenceEvent
+	record := func(ping models.LocationPing, geofenceID *uint, eventType string, reason *string) {
+		geofenceEvents = append(geofenceEvents, models.GeofenceEvent{
+			GeofenceID: geofenceID,
+			VehicleID:  ping.VehicleID,
+			DriverID:   ping.DriverID,
+			TaskID:     ping.TaskID,
+			Type:       eventType,
+			Reason:     reason,
+			Latitude:   ping.Latitude,
+			Longitude:  ping.Longitude,
+			OccurredAt: ping.RecordedAt,
+		})
+	}
+
+	for _, ping := range ordered {
+		state := evaluateGeofenceState(fences, ping)
+		for _, fence := range fences {
+			fenceID := fence.ID
+			wasInside := prevState != nil && prevState.inside[fence.ID]
+			if state.inside[fence.ID] && !wasInside {
+				record(ping, &fenceID, geofenceEventEnter, nil)
+				if *fence.Category == geofenceCategoryRestricted {
+					reason := violationRestrictedZone
+					record(ping, &fenceID, geofenceEventViolation, &reason)
+				}
+			}
+			if !state.inside[fence.ID] && wasInside {
+				record(ping, &fenceID, geofenceEventExit, nil)
+			}
+		}
+		if state.hasOperatingAreas && !state.inOperatingArea && (prevState == nil || prevState.inOperatingArea) {
+			reason := violationOutsideAllowedArea
+			record(ping, nil, geofenceEventViolation, &reason)
+		}
+		if !state.permitted() && (prevState == nil || prevState.permitted()) {
+			reason := violationOutsideHours
+			record(ping, state.hoursViolation, geofenceEventViolation, &reason)
+		}
+		if err := s.markWaypointsArrived(tx, ping, waypoints); err != nil {
+			return err
+		}
+		prevState = &state
+	}
+
+	if len(geofenceEvents) == 0 {
+		return nil
+	}
+	if err := tx.Create(&geofenceEvents).Error; err != nil {
+		return err
+	}
+	for _, geofenceEvent := range geofenceEvents {
+		if err := s.emit(tx, events.Event{
+			Type:       "geofence." + geofenceEvent.Type,
+			VehicleID:  geofenceEvent.VehicleID,
+			DriverID:   geofenceEvent.DriverID,
+			Data:       newGeofenceEventResponse(geofenceEvent),
+			OccurredAt: geofenceEvent.OccurredAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markWaypointsArrived sets ArrivedAt on the waypoints the ping is close enough to
+func (s *Server) markWaypointsArrived(tx *gorm.DB, ping models.LocationPing, waypoints []models.TaskWaypoint) error {
+	p := pingPoint(ping)
+	for i := range waypoints {
+		waypoint := &waypoints[i]
+		if waypoint.ArrivedAt != nil || waypoint.Latitude == nil || waypoint.Longitude == nil {
+			continue
+		}
+		if !geo.WithinRadius(geo.Point{Latitude: *waypoint.Latitude, Longitude: *waypoint.Longitude}, p, waypointArrivalRadius) {
+			continue
+		}
+		arrivedAt := ping.RecordedAt
+		if err := tx.Model(waypoint).Update("arrived_at", arrivedAt).Error; err != nil {
+			return err
+		}
+		waypoint.ArrivedAt = &arrivedAt
+		if err := s.emit(tx, events.Event{
+			Type:      events.WaypointArrived,
+			VehicleID: ping.VehicleID,
+			DriverID:  ping.DriverID,
+			Data: gin.H{
+				"task_id":     waypoint.TaskID,
+				"waypoint_id": waypoint.ID,
+				"sequence":    waypoint.Sequence,
+				"arrived_at":  arrivedAt,
+			},
+			OccurredAt: arrivedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/models"
+)
+
+func TestPermittedHoursUseTheGeofenceTimeZone(t *testing.T) {
+	// the process time zone must not matter
+	local := time.Local
+	time.Local = time.FixedZone("UTC+9", 9*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	name, category, shape := "Yard", geofenceCategoryDepot, geofenceShapeCircle
+	lat, lon, radius := 40.7128, -74.0060, 1000.0
+	from, to := "08:00", "12:00"
+	fence := func(timeZone *string) models.Geofence {
+		fence := models.Geofence{
+			Name: &name, Category: &category, Shape: &shape,
+			CenterLatitude: &lat, CenterLongitude: &lon, RadiusMeters: &radius,
+			PermittedFrom: &from, PermittedTo: &to, TimeZone: timeZone,
+		}
+		fence.ID = 1
+		return fence
+	}
+	// 10:00 in New York, 14:00 UTC
+	ping := models.LocationPing{Latitude: &lat, Longitude: &lon, RecordedAt: time.Date(2024, 6, 3, 14, 0, 0, 0, time.UTC)}
+
+	newYork := "America/New_York"
+	if state := evaluateGeofenceState([]models.Geofence{fence(&newYork)}, ping); !state.permitted() {
+		t.Error("10:00 in New York is outside 08:00-12:00 New York time")
+	}
+	if state := evaluateGeofenceState([]models.Geofence{fence(nil)}, ping); state.permitted() {
+		t.Error("14:00 UTC is inside 08:00-12:00 of a geofence without a time zone")
+	}
+
+	for zone, valid := range map[string]bool{newYork: true, "UTC": true, "Local": false, "Mars/Olympus": false} {
+		zone := zone
+		withZone := fence(&zone)
+		if err := validateGeofence(&withZone); (err == nil) != valid {
+			t.Errorf("validate with time zone %q = %v, want valid %v", zone, err, valid)
+		}
+	}
+}
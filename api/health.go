@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds all readiness checks together
+const readinessTimeout = 3 * time.Second
+
+type healthResponse struct {
+	Status string `json:"status"`
+	// Checks maps each readiness check to "ok" or the reason it failed
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz godoc
+// @Summary Liveness probe
+// @Description Answers as long as the process serves requests, it checks no dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} healthResponse{}
+// @Router /healthz [get]
+func (s *Server) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Checks that the database answers and the upload storage is writable
+// @Tags health
+// @Produce json
+// @Success 200 {object} healthResponse{}
+// @Failure 503 {object} healthResponse{}
+// @Router /readyz [get]
+func (s *Server) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]error{"storage": s.checkStorage()}
+	// handler tests run without a database
+	if s.DB != nil {
+		checks["database"] = s.pingDatabase(ctx)
+	}
+	response := healthResponse{Status: "ok", Checks: make(map[string]string)}
+	status := http.StatusOK
+	for name, err := range checks {
+		response.Checks[name] = "ok"
+		if err != nil {
+			response.Checks[name] = err.Error()
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(status, response)
+}
+
+func (s *Server) pingDatabase(ctx context.Context) error {
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkStorage writes and removes a file in the upload directory
+func (s *Server) checkStorage() error {
+	file, err := os.CreateTemp(s.config.Storage.Dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
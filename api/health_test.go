@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+)
+
+func TestReadiness(t *testing.T) {
+	h := apitest.New(t)
+	if code := h.Anonymous().Get("/healthz", nil); code != http.StatusOK {
+		t.Errorf("healthz status = %d, want 200", code)
+	}
+
+	var response api.HealthResponse
+	if code := h.Anonymous().Get("/readyz", &response); code != http.StatusOK {
+		t.Fatalf("readyz status = %d, want 200", code)
+	}
+	if response.Checks["database"] != "ok" || response.Checks["storage"] != "ok" {
+		t.Errorf("checks = %v, want the database and storage ok", response.Checks)
+	}
+
+	if err := os.RemoveAll(h.Config.Storage.Dir); err != nil {
+		t.Fatal(err)
+	}
+	if code := h.Anonymous().Get("/readyz", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz status without storage = %d, want 503", code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	h := apitest.New(t)
+	driverID := h.User(apitest.RoleDriver).ID
+	addVehicle(t, h, "Active", &driverID)
+	addVehicle(t, h, "Active", nil)
+	addVehicle(t, h, "Maintenance", nil)
+	addTask(t, h, driverID)
+	h.As(apitest.RoleDriver).Get("/vehicle", nil)
+	h.Anonymous().Get("/no/such/route", nil)
+
+	recorder := h.Anonymous().Do(http.MethodGet, "/metrics", nil, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`vms_vehicles{status="Active"} 2`,
+		`vms_vehicles{status="Maintenance"} 1`,
+		`vms_open_tasks 1`,
+		`vms_pending_maintenance_records 0`,
+		`go_sql_max_open_connections{db_name="vms"} 1`,
+		`vms_http_request_duration_seconds_count{method="GET",route="/vehicle",status="200"} 1`,
+		`vms_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
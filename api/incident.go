@@ -0,0 +1,424 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"gorm.io/gorm"
+)
+
+// incidentTransitions lists the statuses an incident may move to from each status
+var incidentTransitions = map[models.IncidentStatus][]models.IncidentStatus{
+	models.IncidentStatusReported:        {models.IncidentStatusUnderReview, models.IncidentStatusClosed},
+	models.IncidentStatusUnderReview:     {models.IncidentStatusRepairScheduled, models.IncidentStatusClosed},
+	models.IncidentStatusRepairScheduled: {models.IncidentStatusClosed},
+}
+
+func canTransitionIncident(from, to models.IncidentStatus) bool {
+	for _, allowed := range incidentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+type incidentResponse struct {
+	ID                  uint                        `json:"ID"`
+	VehicleID           *uint                       `json:"vehicle_id"`
+	DriverID            *uint                       `json:"driver_id"`
+	ReportedByID        *uint                       `json:"reported_by_id"`
+	OccurredAt          *time.Time                  `json:"occurred_at"`
+	Latitude            *float64                    `json:"latitude"`
+	Longitude           *float64                    `json:"longitude"`
+	Location            *string                     `json:"location"`
+	Description         *string                     `json:"description"`
+	ThirdParties        []models.IncidentThirdParty `json:"third_parties"`
+	Status              *models.IncidentStatus      `json:"status"`
+	MaintenanceRecordID *uint                       `json:"maintenance_record_id"`
+	RepairCost          *float64                    `json:"repair_cost"`
+	ResolutionNotes     *string                     `json:"resolution_notes"`
+	Photos              []string                    `json:"photos"`
+	CreatedAt           time.Time                   `json:"created_at"`
+	UpdatedAt           time.Time                   `json:"updated_at"`
+}
+
+func newIncidentResponse(incident models.Incident) incidentResponse {
+	response := incidentResponse{
+		ID:                  incident.ID,
+		VehicleID:           incident.VehicleID,
+		DriverID:            incident.DriverID,
+		ReportedByID:        incident.ReportedByID,
+		OccurredAt:          incident.OccurredAt,
+		Latitude:            incident.Latitude,
+		Longitude:           incident.Longitude,
+		Location:            incident.Location,
+		Description:         incident.Description,
+		ThirdParties:        incident.ThirdParties,
+		Status:              incident.Status,
+		MaintenanceRecordID: incident.MaintenanceRecordID,
+		RepairCost:          incident.RepairCost,
+		ResolutionNotes:     incident.ResolutionNotes,
+		Photos:              make([]string, len(incident.Photos)),
+		CreatedAt:           incident.CreatedAt,
+		UpdatedAt:           incident.UpdatedAt,
+	}
+	for i, photo := range incident.Photos {
+		response.Photos[i] = convertFilePathToURL(photo.Url)
+	}
+	return response
+}
+
+func parseFloat(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+type createIncidentRequest struct {
+	VehicleID    *uint     `form:"vehicle_id" binding:"required"`
+	OccurredAt   time.Time `form:"occurred_at" time_format:"2006-01-02T15:04:05Z07:00" binding:"required,notfuture"`
+	Description  string    `form:"description" binding:"required,max=2000"`
+	Latitude     string    `form:"latitude" binding:"omitempty,latitude"`
+	Longitude    string    `form:"longitude" binding:"omitempty,longitude"`
+	Location     string    `form:"location" binding:"max=200"`
+	ThirdParties string    `form:"third_parties"`
+}
+
+func (r createIncidentRequest) validate() []apperr.FieldError {
+	if (r.Latitude == "") != (r.Longitude == "") {
+		return []apperr.FieldError{{Field: "longitude", Message: "latitude and longitude must be set together"}}
+	}
+	return nil
+}
+
+// toModel builds the incident without its driver, status and photos
+func (r createIncidentRequest) toModel() models.Incident {
+	occurredAt := r.OccurredAt
+	// the coordinates were checked by the binding rules
+	latitude, _ := parseFloat(r.Latitude)
+	longitude, _ := parseFloat(r.Longitude)
+	return models.Incident{
+		VehicleID:   r.VehicleID,
+		OccurredAt:  &occurredAt,
+		Description: parseString(r.Description),
+		Latitude:    latitude,
+		Longitude:   longitude,
+		Location:    parseString(r.Location),
+	}
+}
+
+// CreateIncident godoc
+// @Summary Report an incident
+// @Description Drivers and admins report accidents, damage or traffic incidents. The incident is attributed to the driver assigned to the vehicle at that time.
+// @Tags incident
+// @Accept multipart/form-data
+// @Produce json
+// @Param vehicle_id formData uint true "Vehicle ID"
+// @Param occurred_at formData string true "RFC3339 time of the incident"
+// @Param description formData string true "What happened"
+// @Param latitude formData number false "Latitude"
+// @Param longitude formData number false "Longitude"
+// @Param location formData string false "Address or place"
+// @Param third_parties formData string false "JSON array of {name, phone_number, license_plate, insurance_company, insurance_policy}"
+// @Param photos formData file false "Photos"
+// @Success 200 {object} incidentResponse{}
+// @Router /incident [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateIncident(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" && authPayload.Role != "Driver" {
+		c.Error(apperr.Forbidden("only admins and drivers can report incidents"))
+		return
+	}
+	if err := c.Request.ParseMultipartForm(s.config.Uploads.MaxRequestSize); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req createIncidentRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	incident := req.toModel()
+	occurredAt := req.OccurredAt
+	if req.ThirdParties != "" {
+		if err := json.Unmarshal([]byte(req.ThirdParties), &incident.ThirdParties); err != nil {
+			c.Error(apperr.Invalid("third_parties", "third_parties must be a JSON array"))
+			return
+		}
+	}
+
+	reporter, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), *incident.VehicleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	driverID, err := s.driverAssignedAt(c.Request.Context(), vehicle, occurredAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if authPayload.Role == "Driver" && (driverID == nil || *driverID != reporter.ID) {
+		c.Error(apperr.Forbidden("the vehicle was not assigned to the driver at that time"))
+		return
+	}
+	incident.DriverID = driverID
+	incident.ReportedByID = &reporter.ID
+	status := models.IncidentStatusReported
+	incident.Status = &status
+
+	for _, file := range c.Request.MultipartForm.File["photos"] {
+		photoPath, err := s.saveFile(file, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		incident.Photos = append(incident.Photos, models.IncidentPhoto{Url: &photoPath})
+	}
+
+	err = s.transaction(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Create(&incident).Error; err != nil {
+			return err
+		}
+		return s.emit(tx, events.Event{
+			Type:      events.IncidentReported,
+			VehicleID: incident.VehicleID,
+			DriverID:  incident.DriverID,
+			Data:      newIncidentResponse(incident),
+		})
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response := newIncidentResponse(incident)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetIncidents godoc
+// @Summary Get incidents
+// @Description Admins get all incidents, drivers get the incidents attributed to or reported by them
+// @Tags incident
+// @Produce json
+// @Param status query string false "Filter by status"
+// @Success 200 {array} []incidentResponse{}
+// @Router /incident [get]
+// @Security ApiKeyAuth
+func (s *Server) GetIncidents(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	query := s.DB.WithContext(c.Request.Context()).Preload("Photos").Order("occurred_at DESC")
+	switch authPayload.Role {
+	case "Admin":
+	case "Driver":
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		query = query.Where("driver_id = ? OR reported_by_id = ?", user.ID, user.ID)
+	default:
+		c.Error(apperr.Forbidden("only admins and drivers can get incidents"))
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var incidents []models.Incident
+	if err := query.Find(&incidents).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]incidentResponse, len(incidents))
+	for i, incident := range incidents {
+		response[i] = newIncidentResponse(incident)
+	}
+	c.JSON(200, response)
+}
+
+// GetIncident godoc
+// @Summary Get an incident
+// @Description Get an incident with its photos
+// @Tags incident
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Success 200 {object} incidentResponse{}
+// @Router /incident/{id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetIncident(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	var incident models.Incident
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Photos").First(&incident, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if authPayload.Role != "Admin" {
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		involved := (incident.DriverID != nil && *incident.DriverID == user.ID) ||
+			(incident.ReportedByID != nil && *incident.ReportedByID == user.ID)
+		if !involved {
+			c.Error(apperr.Forbidden("only admins and the involved driver can get the incident"))
+			return
+		}
+	}
+	c.JSON(200, newIncidentResponse(incident))
+}
+
+// GetIncidentsOfVehicle godoc
+// @Summary Get incidents of a vehicle
+// @Description Get all incidents of a particular vehicle
+// @Tags incident
+// @Produce json
+// @Param vehicle_id path int true "Vehicle ID"
+// @Success 200 {array} []incidentResponse{}
+// @Router /incidents/{vehicle_id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetIncidentsOfVehicle(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get incidents of a vehicle"))
+		return
+	}
+	var incidents []models.Incident
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Photos").Where("vehicle_id = ?", c.Param("vehicle_id")).
+		Order("occurred_at DESC").Find(&incidents).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]incidentResponse, len(incidents))
+	for i, incident := range incidents {
+		response[i] = newIncidentResponse(incident)
+	}
+	c.JSON(200, response)
+}
+
+type updateIncidentStatusRequest struct {
+	Status              models.IncidentStatus `json:"status" enums:"UnderReview,RepairScheduled,Closed" binding:"required,oneof=UnderReview RepairScheduled Closed"`
+	MaintenanceRecordID *uint                 `json:"maintenance_record_id"`
+	RepairCost          *float64              `json:"repair_cost" binding:"omitempty,gte=0"`
+	ResolutionNotes     *string               `json:"resolution_notes" binding:"omitempty,max=2000"`
+}
+
+// UpdateIncidentStatus godoc
+// @Summary Triage an incident
+// @Description Admins move an incident through Reported, UnderReview, RepairScheduled and Closed. Scheduling a repair links the given maintenance record or opens a pending one.
+// @Tags incident
+// @Accept json
+// @Produce json
+// @Param id path int true "Incident ID"
+// @Param status body updateIncidentStatusRequest true "New status"
+// @Success 200 {object} incidentResponse{}
+// @Router /incident/{id}/status [put]
+// @Security ApiKeyAuth
+func (s *Server) UpdateIncidentStatus(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can triage incidents"))
+		return
+	}
+	var req updateIncidentStatusRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	var incident models.Incident
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Photos").First(&incident, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	previousStatus := *incident.Status
+	if req.Status != previousStatus && !canTransitionIncident(previousStatus, req.Status) {
+		c.Error(apperr.Conflict("cannot move incident from %s to %s", previousStatus, req.Status))
+		return
+	}
+
+	err := s.transaction(c.Request.Context(), func(tx *gorm.DB) error {
+		if req.MaintenanceRecordID != nil {
+			var maintenance models.MaintenanceRecord
+			if err := tx.First(&maintenance, *req.MaintenanceRecordID).Error; err != nil {
+				return err
+			}
+			if maintenance.VehicleID == nil || *maintenance.VehicleID != *incident.VehicleID {
+				return apperr.Invalid("maintenance_record_id", "maintenance record belongs to another vehicle")
+			}
+			incident.MaintenanceRecordID = &maintenance.ID
+		}
+		if req.Status == models.IncidentStatusRepairScheduled && incident.MaintenanceRecordID == nil {
+			maintenance := newIncidentMaintenanceRecord(incident)
+			if err := tx.Create(maintenance).Error; err != nil {
+				return err
+			}
+			incident.MaintenanceRecordID = &maintenance.ID
+		}
+		if req.RepairCost != nil {
+			incident.RepairCost = req.RepairCost
+		}
+		if req.ResolutionNotes != nil {
+			incident.ResolutionNotes = req.ResolutionNotes
+		}
+		incident.Status = &req.Status
+		if err := tx.Save(&incident).Error; err != nil {
+			return err
+		}
+		if req.Status == previousStatus {
+			return nil
+		}
+		return s.emit(tx, events.Event{
+			Type:      events.IncidentStatusChanged,
+			VehicleID: incident.VehicleID,
+			DriverID:  incident.DriverID,
+			Data: gin.H{
+				"incident_id":     incident.ID,
+				"status":          req.Status,
+				"previous_status": previousStatus,
+			},
+		})
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newIncidentResponse(incident))
+}
+
+// newIncidentMaintenanceRecord opens a pending repair for an incident
+func newIncidentMaintenanceRecord(incident models.Incident) *models.MaintenanceRecord {
+	now := time.Now()
+	status := models.MaintenanceStatusPending
+	serviceType := fmt.Sprintf("Incident repair #%d", incident.ID)
+	totalCost := 0.0
+	if incident.RepairCost != nil {
+		totalCost = *incident.RepairCost
+	}
+	return &models.MaintenanceRecord{
+		VehicleID:       incident.VehicleID,
+		MaintenanceDate: &now,
+		ServiceType:     &serviceType,
+		Status:          &status,
+		TotalCost:       &totalCost,
+		Notes:           incident.Description,
+	}
+}
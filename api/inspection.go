@@ -0,0 +1,495 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/store"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"gorm.io/gorm"
+)
+
+type inspectionTemplateItemRequest struct {
+	Label    *string `json:"label" binding:"required,min=1,max=200"`
+	Critical bool    `json:"critical"`
+}
+
+type createInspectionTemplateRequest struct {
+	Name        *string                         `json:"name" binding:"required,min=1,max=100"`
+	VehicleType *string                         `json:"vehicle_type" binding:"required,min=1,max=50"`
+	Kind        *string                         `json:"kind" enums:"pre_trip,post_trip" binding:"required,oneof=pre_trip post_trip"`
+	Items       []inspectionTemplateItemRequest `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+type inspectionTemplateItemResponse struct {
+	ID       uint    `json:"ID"`
+	Position int     `json:"position"`
+	Label    *string `json:"label"`
+	Critical bool    `json:"critical"`
+}
+
+type inspectionTemplateResponse struct {
+	ID          uint                             `json:"ID"`
+	Name        *string                          `json:"name"`
+	VehicleType *string                          `json:"vehicle_type"`
+	Kind        *string                          `json:"kind"`
+	Items       []inspectionTemplateItemResponse `json:"items"`
+}
+
+func newInspectionTemplateResponse(template models.InspectionTemplate) inspectionTemplateResponse {
+	response := inspectionTemplateResponse{
+		ID:          template.ID,
+		Name:        template.Name,
+		VehicleType: template.VehicleType,
+		Kind:        template.Kind,
+		Items:       make([]inspectionTemplateItemResponse, len(template.Items)),
+	}
+	for i, item := range template.Items {
+		response.Items[i] = inspectionTemplateItemResponse{
+			ID:       item.ID,
+			Position: item.Position,
+			Label:    item.Label,
+			Critical: item.Critical,
+		}
+	}
+	return response
+}
+
+// inspectionItemResult is one entry of the results form field
+type inspectionItemResult struct {
+	ItemID uint    `json:"item_id"`
+	Passed bool    `json:"passed"`
+	Notes  *string `json:"notes"`
+}
+
+type inspectionResultResponse struct {
+	ItemID   *uint   `json:"item_id"`
+	Label    *string `json:"label"`
+	Critical bool    `json:"critical"`
+	Passed   bool    `json:"passed"`
+	Notes    *string `json:"notes"`
+}
+
+type inspectionResponse struct {
+	ID                  uint                       `json:"ID"`
+	VehicleID           *uint                      `json:"vehicle_id"`
+	DriverID            *uint                      `json:"driver_id"`
+	TemplateID          *uint                      `json:"template_id"`
+	Kind                *string                    `json:"kind"`
+	Passed              bool                       `json:"passed"`
+	Notes               *string                    `json:"notes"`
+	MaintenanceRecordID *uint                      `json:"maintenance_record_id"`
+	Results             []inspectionResultResponse `json:"results"`
+	Photos              []string                   `json:"photos"`
+	CreatedAt           time.Time                  `json:"created_at"`
+}
+
+func newInspectionResponse(inspection models.Inspection) inspectionResponse {
+	response := inspectionResponse{
+		ID:                  inspection.ID,
+		VehicleID:           inspection.VehicleID,
+		DriverID:            inspection.DriverID,
+		TemplateID:          inspection.TemplateID,
+		Kind:                inspection.Kind,
+		Passed:              inspection.Passed,
+		Notes:               inspection.Notes,
+		MaintenanceRecordID: inspection.MaintenanceRecordID,
+		Results:             make([]inspectionResultResponse, len(inspection.Results)),
+		Photos:              make([]string, len(inspection.Photos)),
+		CreatedAt:           inspection.CreatedAt,
+	}
+	for i, result := range inspection.Results {
+		response.Results[i] = inspectionResultResponse{
+			ItemID:   result.ItemID,
+			Label:    result.Label,
+			Critical: result.Critical,
+			Passed:   result.Passed,
+			Notes:    result.Notes,
+		}
+	}
+	for i, photo := range inspection.Photos {
+		response.Photos[i] = convertFilePathToURL(photo.Url)
+	}
+	return response
+}
+
+// CreateInspectionTemplate godoc
+// @Summary Create an inspection template
+// @Description Admins define the checklist drivers fill in before or after a trip for a vehicle type
+// @Tags inspection
+// @Accept  json
+// @Produce  json
+// @Param template body createInspectionTemplateRequest true "Template"
+// @Success 200 {object} inspectionTemplateResponse{}
+// @Router /inspection/template [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateInspectionTemplate(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can create inspection templates"))
+		return
+	}
+	var req createInspectionTemplateRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	template := models.InspectionTemplate{
+		Name:        req.Name,
+		VehicleType: req.VehicleType,
+		Kind:        req.Kind,
+	}
+	for i, item := range req.Items {
+		template.Items = append(template.Items, models.InspectionTemplateItem{
+			Position: i + 1,
+			Label:    item.Label,
+			Critical: item.Critical,
+		})
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Create(&template).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newInspectionTemplateResponse(template))
+}
+
+// GetInspectionTemplates godoc
+// @Summary Get inspection templates
+// @Description Get inspection templates, optionally filtered by vehicle type and kind
+// @Tags inspection
+// @Produce  json
+// @Param vehicle_type query string false "Vehicle type"
+// @Param kind query string false "pre_trip or post_trip"
+// @Success 200 {array} []inspectionTemplateResponse{}
+// @Router /inspection/template [get]
+// @Security ApiKeyAuth
+func (s *Server) GetInspectionTemplates(c *gin.Context) {
+	query := s.DB.WithContext(c.Request.Context()).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+	if vehicleType := c.Query("vehicle_type"); vehicleType != "" {
+		query = query.Where("LOWER(vehicle_type) = LOWER(?)", vehicleType)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	var templates []models.InspectionTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]inspectionTemplateResponse, len(templates))
+	for i, template := range templates {
+		response[i] = newInspectionTemplateResponse(template)
+	}
+	c.JSON(200, response)
+}
+
+// GetInspectionTemplate godoc
+// @Summary Get an inspection template
+// @Description Get an inspection template with its items
+// @Tags inspection
+// @Produce  json
+// @Param id path int true "Template ID"
+// @Success 200 {object} inspectionTemplateResponse{}
+// @Router /inspection/template/{id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetInspectionTemplate(c *gin.Context) {
+	var template models.InspectionTemplate
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&template, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newInspectionTemplateResponse(template))
+}
+
+// DeleteInspectionTemplate godoc
+// @Summary Delete an inspection template
+// @Description Delete an inspection template, submitted inspections are kept
+// @Tags inspection
+// @Produce  json
+// @Param id path int true "Template ID"
+// @Success 200
+// @Router /inspection/template/{id} [delete]
+// @Security ApiKeyAuth
+func (s *Server) DeleteInspectionTemplate(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can delete inspection templates"))
+		return
+	}
+	var template models.InspectionTemplate
+	if err := s.DB.WithContext(c.Request.Context()).First(&template, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Delete(&template).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, gin.H{})
+}
+
+type createInspectionRequest struct {
+	VehicleID  *uint  `form:"vehicle_id" binding:"required"`
+	TemplateID *uint  `form:"template_id" binding:"required"`
+	Results    string `form:"results" binding:"required"`
+	Notes      string `form:"notes" binding:"max=1000"`
+}
+
+// CreateInspection godoc
+// @Summary Submit a vehicle inspection
+// @Description Drivers submit a completed pre-trip or post-trip checklist. A failed critical item opens a pending maintenance record and puts the vehicle in Maintenance.
+// @Tags inspection
+// @Accept multipart/form-data
+// @Produce json
+// @Param vehicle_id formData uint true "Vehicle ID"
+// @Param template_id formData uint true "Template ID"
+// @Param results formData string true "JSON array of {item_id, passed, notes}"
+// @Param notes formData string false "Additional notes"
+// @Param photos formData file false "Photos"
+// @Success 200 {object} inspectionResponse{}
+// @Router /inspection [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateInspection(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Driver" {
+		c.Error(apperr.Forbidden("only drivers can submit inspections"))
+		return
+	}
+	if err := c.Request.ParseMultipartForm(s.config.Uploads.MaxRequestSize); err != nil {
+		c.Error(err)
+		return
+	}
+	var req createInspectionRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	var submitted []inspectionItemResult
+	if err := json.Unmarshal([]byte(req.Results), &submitted); err != nil {
+		c.Error(apperr.Invalid("results", "results must be a JSON array of {item_id, passed, notes}"))
+		return
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), *req.VehicleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if vehicle.AssignedDriver == nil || *vehicle.AssignedDriver != user.ID {
+		c.Error(apperr.Forbidden("vehicle is not assigned to the driver"))
+		return
+	}
+	var template models.InspectionTemplate
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Items").First(&template, *req.TemplateID).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if vehicle.Type == nil || !strings.EqualFold(*vehicle.Type, *template.VehicleType) {
+		c.Error(apperr.Invalid("template_id", "template does not match the vehicle type"))
+		return
+	}
+
+	byItem := make(map[uint]inspectionItemResult, len(submitted))
+	for _, result := range submitted {
+		byItem[result.ItemID] = result
+	}
+	inspection := models.Inspection{
+		VehicleID:  &vehicle.ID,
+		DriverID:   &user.ID,
+		TemplateID: &template.ID,
+		Kind:       template.Kind,
+		Passed:     true,
+		Notes:      parseString(req.Notes),
+	}
+	var failedCritical []string
+	for _, item := range template.Items {
+		result, ok := byItem[item.ID]
+		if !ok {
+			c.Error(apperr.Invalid("results", "missing result for item %q", *item.Label))
+			return
+		}
+		itemID := item.ID
+		inspection.Results = append(inspection.Results, models.InspectionResult{
+			ItemID:   &itemID,
+			Label:    item.Label,
+			Critical: item.Critical,
+			Passed:   result.Passed,
+			Notes:    result.Notes,
+		})
+		if !result.Passed {
+			inspection.Passed = false
+			if item.Critical {
+				failedCritical = append(failedCritical, *item.Label)
+			}
+		}
+	}
+
+	for _, file := range c.Request.MultipartForm.File["photos"] {
+		photoPath, err := s.saveFile(file, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		inspection.Photos = append(inspection.Photos, models.InspectionPhoto{Url: &photoPath})
+	}
+
+	previousStatus := vehicle.Status
+	err = s.transaction(c.Request.Context(), func(tx *gorm.DB) error {
+		if len(failedCritical) == 0 {
+			return tx.Create(&inspection).Error
+		}
+		maintenance := newInspectionMaintenanceRecord(vehicle, failedCritical, inspection.Notes)
+		if err := tx.Create(maintenance).Error; err != nil {
+			return err
+		}
+		inspection.MaintenanceRecordID = &maintenance.ID
+		status := string(models.VehicleStatusMaintenance)
+		vehicle.Status = &status
+		if err := tx.Save(&vehicle).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&inspection).Error; err != nil {
+			return err
+		}
+		if err := s.emit(tx, events.Event{
+			Type:      events.MaintenanceCreated,
+			VehicleID: maintenance.VehicleID,
+			DriverID:  vehicle.AssignedDriver,
+			Data:      newMaintenanceRecordResponse(*maintenance),
+		}); err != nil {
+			return err
+		}
+		return s.publishVehicleStatus(c.Request.Context(), store.NewGorm(tx), vehicle, previousStatus)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, newInspectionResponse(inspection))
+}
+
+// newInspectionMaintenanceRecord opens a pending maintenance record for failed critical items
+func newInspectionMaintenanceRecord(vehicle models.Vehicle, failedItems []string, notes *string) *models.MaintenanceRecord {
+	now := time.Now()
+	status := models.MaintenanceStatusPending
+	serviceType := "Inspection failure: " + strings.Join(failedItems, ", ")
+	totalCost := 0.0
+	return &models.MaintenanceRecord{
+		VehicleID:        &vehicle.ID,
+		MaintenanceDate:  &now,
+		ServiceType:      &serviceType,
+		Status:           &status,
+		TotalCost:        &totalCost,
+		MileageAtService: vehicle.CurrentMileage,
+		Notes:            notes,
+	}
+}
+
+// GetInspections godoc
+// @Summary Get inspections
+// @Description Admins and maintenance staff get all inspections, drivers get their own
+// @Tags inspection
+// @Produce  json
+// @Success 200 {array} []inspectionResponse{}
+// @Router /inspection [get]
+// @Security ApiKeyAuth
+func (s *Server) GetInspections(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	query := s.DB.WithContext(c.Request.Context()).Preload("Results").Preload("Photos").Order("created_at DESC")
+	switch authPayload.Role {
+	case "Admin", "Maintenance_person":
+	case "Driver":
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		query = query.Where("driver_id = ?", user.ID)
+	default:
+		c.Error(apperr.Forbidden("only admins, maintenance and drivers can get inspections"))
+		return
+	}
+	var inspections []models.Inspection
+	if err := query.Find(&inspections).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]inspectionResponse, len(inspections))
+	for i, inspection := range inspections {
+		response[i] = newInspectionResponse(inspection)
+	}
+	c.JSON(200, response)
+}
+
+// GetInspection godoc
+// @Summary Get an inspection
+// @Description Get an inspection with its results and photos
+// @Tags inspection
+// @Produce  json
+// @Param id path int true "Inspection ID"
+// @Success 200 {object} inspectionResponse{}
+// @Router /inspection/{id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetInspection(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	var inspection models.Inspection
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Results").Preload("Photos").First(&inspection, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if inspection.DriverID == nil || *inspection.DriverID != user.ID {
+			c.Error(apperr.Forbidden("only admins, maintenance and the submitting driver can get the inspection"))
+			return
+		}
+	}
+	c.JSON(200, newInspectionResponse(inspection))
+}
+
+// GetInspectionsOfVehicle godoc
+// @Summary Get inspections of a vehicle
+// @Description Get all inspections of a particular vehicle
+// @Tags inspection
+// @Produce  json
+// @Param vehicle_id path int true "Vehicle ID"
+// @Success 200 {array} []inspectionResponse{}
+// @Router /inspections/{vehicle_id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetInspectionsOfVehicle(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can get inspections of a vehicle"))
+		return
+	}
+	var inspections []models.Inspection
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Results").Preload("Photos").Where("vehicle_id = ?", c.Param("vehicle_id")).
+		Order("created_at DESC").Find(&inspections).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]inspectionResponse, len(inspections))
+	for i, inspection := range inspections {
+		response[i] = newInspectionResponse(inspection)
+	}
+	c.JSON(200, response)
+}
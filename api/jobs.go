@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/config"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/scheduler"
+	"github.com/rassulmagauin/VMS_SWE/token"
+)
+
+const (
+	// outboxDispatchInterval bounds how late an event is dispatched when the dispatcher was not woken
+	outboxDispatchInterval = 5 * time.Second
+	jobRunRetention        = 30 * 24 * time.Hour
+)
+
+type jobResponse struct {
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule"`
+	Running    bool       `json:"running"`
+	LockedBy   *string    `json:"locked_by"`
+	NextRunAt  *time.Time `json:"next_run_at"`
+	LastRunAt  *time.Time `json:"last_run_at"`
+	LastStatus *string    `json:"last_status"`
+}
+
+type jobRunResponse struct {
+	ID         uint       `json:"ID"`
+	JobName    string     `json:"job_name"`
+	Trigger    string     `json:"trigger"`
+	RunBy      string     `json:"run_by"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Error      *string    `json:"error"`
+}
+
+func newJobRunResponse(run models.JobRun) jobRunResponse {
+	return jobRunResponse{
+		ID:         run.ID,
+		JobName:    run.JobName,
+		Trigger:    run.Trigger,
+		RunBy:      run.RunBy,
+		Status:     run.Status,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		Error:      run.Error,
+	}
+}
+
+// registerJobs declares the periodic background work of the server
+func (s *Server) registerJobs() error {
+	jobs := []struct {
+		name     string
+		schedule string
+		timeout  time.Duration
+		run      scheduler.Func
+	}{
+		{"purge_location_pings", "@hourly", 10 * time.Minute, s.purgeLocationPings},
+		{"check_document_expiry", "0 6 * * *", 10 * time.Minute, s.checkDocumentExpiry},
+		{"check_maintenance_due", "0 6 * * *", 10 * time.Minute, s.checkMaintenanceDue},
+		{"close_auctions", "@every 1m", time.Minute, s.closeEndedAuctions},
+		{"deliver_webhooks", "@every 10s", 5 * time.Minute, s.deliverWebhooks},
+		{"purge_job_runs", "@daily", 10 * time.Minute, s.purgeJobRuns},
+		{"purge_password_resets", "@daily", 10 * time.Minute, s.purgePasswordResets},
+	}
+	for _, job := range jobs {
+		if err := s.scheduler.Register(job.name, job.schedule, job.timeout, job.run); err != nil {
+			return err
+		}
+	}
+	// the memory backend drops its expired counters itself
+	if s.config.RateLimit.Backend == config.RateLimitDatabase {
+		return s.scheduler.Register("purge_rate_limits", "@every 10m", time.Minute, s.purgeRateLimits)
+	}
+	return nil
+}
+
+// purgeRateLimits drops the expired rate limit counters from the database
+func (s *Server) purgeRateLimits(ctx context.Context) error {
+	purged, err := s.rateLimits.Purge(ctx)
+	if err == nil && purged > 0 {
+		slog.InfoContext(ctx, "purged rate limit counters", slog.Int64("count", purged))
+	}
+	return err
+}
+
+// purgeJobRuns keeps the job run history from growing without bound
+func (s *Server) purgeJobRuns(ctx context.Context) error {
+	purged, err := s.scheduler.PurgeRuns(time.Now().Add(-jobRunRetention))
+	if err == nil && purged > 0 {
+		slog.InfoContext(ctx, "purged job runs", slog.Int64("count", purged))
+	}
+	return err
+}
+
+// GetJobs godoc
+// @Summary Get background jobs
+// @Description Lists the scheduled jobs with their schedule, lock and last outcome
+// @Tags job
+// @Produce json
+// @Success 200 {array} []jobResponse{}
+// @Router /jobs [get]
+// @Security ApiKeyAuth
+func (s *Server) GetJobs(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get jobs"))
+		return
+	}
+	jobs, err := s.scheduler.Jobs()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	now := time.Now()
+	response := make([]jobResponse, len(jobs))
+	for i, job := range jobs {
+		response[i] = jobResponse{
+			Name:       job.Name,
+			Schedule:   job.Schedule,
+			Running:    job.LockedUntil != nil && job.LockedUntil.After(now),
+			LockedBy:   job.LockedBy,
+			NextRunAt:  job.NextRunAt,
+			LastRunAt:  job.LastRunAt,
+			LastStatus: job.LastStatus,
+		}
+	}
+	c.JSON(200, response)
+}
+
+// GetJobRuns godoc
+// @Summary Get the run history of a job
+// @Description Returns the latest runs of a job, newest first
+// @Tags job
+// @Produce json
+// @Param name path string true "Job name"
+// @Param status query string false "running, succeeded or failed"
+// @Success 200 {array} []jobRunResponse{}
+// @Router /jobs/{name}/runs [get]
+// @Security ApiKeyAuth
+func (s *Server) GetJobRuns(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get job runs"))
+		return
+	}
+	query := s.DB.WithContext(c.Request.Context()).Where("job_name = ?", c.Param("name"))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var runs []models.JobRun
+	if err := query.Order("started_at DESC").Limit(100).Find(&runs).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]jobRunResponse, len(runs))
+	for i, run := range runs {
+		response[i] = newJobRunResponse(run)
+	}
+	c.JSON(200, response)
+}
+
+// TriggerJob godoc
+// @Summary Run a job now
+// @Description Starts a run of the job outside its schedule and returns the run record, the job continues in the background
+// @Tags job
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 202 {object} jobRunResponse{}
+// @Router /jobs/{name}/run [post]
+// @Security ApiKeyAuth
+func (s *Server) TriggerJob(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can run jobs"))
+		return
+	}
+	run, err := s.scheduler.Trigger(c.Param("name"))
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		c.Error(apperr.NotFound("unknown job %s", c.Param("name")))
+		return
+	case errors.Is(err, scheduler.ErrLocked):
+		c.Error(apperr.Conflict("job %s is already running", c.Param("name")))
+		return
+	case err != nil:
+		c.Error(err)
+		return
+	}
+	c.JSON(202, newJobRunResponse(*run))
+}
@@ -0,0 +1,411 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/store"
+	"github.com/rassulmagauin/VMS_SWE/token"
+)
+
+const (
+	qualificationHazmat             = "hazmat"
+	qualificationPassengerTransport = "passenger_transport"
+)
+
+// licenseCategories maps a vehicle type to the license category needed to drive it
+var licenseCategories = map[string]string{
+	"motorcycle": "A",
+	"car":        "B",
+	"van":        "B",
+	"truck":      "C",
+	"tanker":     "C",
+	"bus":        "D",
+}
+
+// vehicleTypeList returns the known vehicle types in a stable order for messages
+func vehicleTypeList() string {
+	types := make([]string, 0, len(licenseCategories))
+	for vehicleType := range licenseCategories {
+		types = append(types, vehicleType)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// requiredQualifications maps a vehicle type to the qualifications needed on top of the license
+var requiredQualifications = map[string][]string{
+	"tanker": {qualificationHazmat},
+	"bus":    {qualificationPassengerTransport},
+}
+
+type driverLicenseResponse struct {
+	ID         uint       `json:"ID"`
+	UserID     *uint      `json:"user_id"`
+	Number     *string    `json:"number"`
+	Categories []string   `json:"categories"`
+	IssuedAt   *time.Time `json:"issued_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	File       *string    `json:"file"`
+	Expired    bool       `json:"expired"`
+}
+
+type driverQualificationResponse struct {
+	ID        uint       `json:"ID"`
+	UserID    *uint      `json:"user_id"`
+	Kind      *string    `json:"kind"`
+	Number    *string    `json:"number"`
+	IssuedAt  *time.Time `json:"issued_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	File      *string    `json:"file"`
+	Expired   bool       `json:"expired"`
+}
+
+func newDriverLicenseResponse(license models.DriverLicense) driverLicenseResponse {
+	response := driverLicenseResponse{
+		ID:         license.ID,
+		UserID:     license.UserID,
+		Number:     license.Number,
+		Categories: licenseCategoryList(license.Categories),
+		IssuedAt:   license.IssuedAt,
+		ExpiresAt:  license.ExpiresAt,
+		Expired:    license.ExpiresAt != nil && license.ExpiresAt.Before(time.Now()),
+	}
+	if license.File != nil {
+		url := convertFilePathToURL(license.File)
+		response.File = &url
+	}
+	return response
+}
+
+func newDriverQualificationResponse(qualification models.DriverQualification) driverQualificationResponse {
+	response := driverQualificationResponse{
+		ID:        qualification.ID,
+		UserID:    qualification.UserID,
+		Kind:      qualification.Kind,
+		Number:    qualification.Number,
+		IssuedAt:  qualification.IssuedAt,
+		ExpiresAt: qualification.ExpiresAt,
+		Expired:   qualification.ExpiresAt != nil && qualification.ExpiresAt.Before(time.Now()),
+	}
+	if qualification.File != nil {
+		url := convertFilePathToURL(qualification.File)
+		response.File = &url
+	}
+	return response
+}
+
+// licenseCategoryList splits a stored category list such as "b, C" into ["B", "C"]
+func licenseCategoryList(categories *string) []string {
+	if categories == nil {
+		return nil
+	}
+	var list []string
+	for _, category := range strings.Split(*categories, ",") {
+		if category = strings.ToUpper(strings.TrimSpace(category)); category != "" {
+			list = append(list, category)
+		}
+	}
+	return list
+}
+
+// canSeeDriverRecords reports whether the caller is an admin or the driver the records belong to
+func canSeeDriverRecords(authPayload *token.Payload, user models.User) bool {
+	return authPayload.Role == "Admin" || authPayload.UserID == user.ID
+}
+
+// checkDriverQualified returns an error if the driver may not drive the vehicle until the given time.
+// Drivers without a license on file are refused unless the allow_unlicensed_drivers setting is on.
+func (s *Server) checkDriverQualified(ctx context.Context, driverID uint, vehicle *models.Vehicle, until time.Time) error {
+	license, err := s.store.Licenses().Get(ctx, driverID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if s.config.AllowUnlicensedDrivers {
+			return nil
+		}
+		return apperr.Invalid("driver_id", "driver has no license on file")
+	}
+	if license.ExpiresAt == nil || license.ExpiresAt.Before(until) {
+		return apperr.Invalid("driver_id", "driver license is expired")
+	}
+	if vehicle == nil {
+		return nil
+	}
+	if vehicle.Type == nil {
+		return apperr.Invalid("vehicle_id", "vehicle has no type to check the driver license against")
+	}
+	vehicleType := strings.ToLower(*vehicle.Type)
+	category, ok := licenseCategories[vehicleType]
+	if !ok {
+		return apperr.Invalid("vehicle_id", "vehicle type %s has no license category, set the type to one of %s", *vehicle.Type, vehicleTypeList())
+	}
+	if !slices.Contains(licenseCategoryList(license.Categories), category) {
+		return apperr.Invalid("driver_id", "driver license lacks category %s required for %s", category, vehicleType)
+	}
+	for _, kind := range requiredQualifications[vehicleType] {
+		qualified, err := s.store.Licenses().HasQualification(ctx, driverID, kind, until)
+		if err != nil {
+			return err
+		}
+		if !qualified {
+			return apperr.Invalid("driver_id", "driver lacks a valid %s qualification required for %s", kind, vehicleType)
+		}
+	}
+	return nil
+}
+
+type saveDriverLicenseRequest struct {
+	Number     string `form:"number" binding:"required,max=50"`
+	Categories string `form:"categories" binding:"required"`
+	IssuedAt   string `form:"issued_at" binding:"omitempty,date"`
+	ExpiresAt  string `form:"expires_at" binding:"required,date"`
+}
+
+func (r saveDriverLicenseRequest) validate() []apperr.FieldError {
+	var fields []apperr.FieldError
+	if r.Categories != "" && len(licenseCategoryList(&r.Categories)) == 0 {
+		fields = append(fields, apperr.FieldError{Field: "categories", Message: "categories must list at least one category"})
+	}
+	issuedAt, _ := parseDate(r.IssuedAt)
+	expiresAt, _ := parseDate(r.ExpiresAt)
+	return append(fields, timeOrder("issued_at", issuedAt, "expires_at", expiresAt)...)
+}
+
+// SaveDriverLicense godoc
+// @Summary Save the license of a driver
+// @Description Admins create or replace the driving license record
+// @Tags license
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "User ID"
+// @Param number formData string true "License number"
+// @Param categories formData string true "Comma separated categories, e.g. B,C"
+// @Param issued_at formData string false "Issue date (YYYY-MM-DD)"
+// @Param expires_at formData string true "Expiry date (YYYY-MM-DD)"
+// @Param file formData file false "Scanned license"
+// @Success 200 {object} driverLicenseResponse{}
+// @Router /user/{id}/license [put]
+// @Security ApiKeyAuth
+func (s *Server) SaveDriverLicense(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	// the license decides what the driver may drive, so drivers cannot edit their own
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can update licenses"))
+		return
+	}
+	var req saveDriverLicenseRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	license, err := s.store.Licenses().Get(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(err)
+		return
+	}
+	categories := strings.Join(licenseCategoryList(&req.Categories), ",")
+	license.UserID = &user.ID
+	license.Number = parseString(req.Number)
+	license.Categories = &categories
+	license.IssuedAt, _ = parseDate(req.IssuedAt)
+	license.ExpiresAt, _ = parseDate(req.ExpiresAt)
+	if file, err := c.FormFile("file"); err == nil {
+		filePath, err := s.saveFile(file, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		license.File = &filePath
+	}
+
+	err = s.storeTransaction(c.Request.Context(), func(tx store.Store) error {
+		if err := tx.Licenses().Save(c.Request.Context(), &license); err != nil {
+			return err
+		}
+		user.DrivingLicenseNumber = license.Number
+		return tx.Users().Update(c.Request.Context(), &user)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, newDriverLicenseResponse(license))
+}
+
+// GetDriverLicense godoc
+// @Summary Get the license of a driver
+// @Description Admins and the driver get the driving license record
+// @Tags license
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} driverLicenseResponse{}
+// @Router /user/{id}/license [get]
+// @Security ApiKeyAuth
+func (s *Server) GetDriverLicense(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !canSeeDriverRecords(authPayload, user) {
+		c.Error(apperr.Forbidden("only admins and the driver can get the license"))
+		return
+	}
+	license, err := s.store.Licenses().Get(c.Request.Context(), user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newDriverLicenseResponse(license))
+}
+
+type createDriverQualificationRequest struct {
+	Kind      string `form:"kind" binding:"required,oneof=hazmat passenger_transport"`
+	Number    string `form:"number" binding:"max=50"`
+	IssuedAt  string `form:"issued_at" binding:"omitempty,date"`
+	ExpiresAt string `form:"expires_at" binding:"omitempty,date"`
+}
+
+func (r createDriverQualificationRequest) validate() []apperr.FieldError {
+	issuedAt, _ := parseDate(r.IssuedAt)
+	expiresAt, _ := parseDate(r.ExpiresAt)
+	return timeOrder("issued_at", issuedAt, "expires_at", expiresAt)
+}
+
+// toModel builds the qualification without its file, the date fields are already validated
+func (r createDriverQualificationRequest) toModel(userID uint) models.DriverQualification {
+	issuedAt, _ := parseDate(r.IssuedAt)
+	expiresAt, _ := parseDate(r.ExpiresAt)
+	return models.DriverQualification{
+		UserID:    &userID,
+		Kind:      parseString(r.Kind),
+		Number:    parseString(r.Number),
+		IssuedAt:  issuedAt,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// CreateDriverQualification godoc
+// @Summary Add a qualification to a driver
+// @Description Admins record hazmat or passenger transport qualifications of a driver
+// @Tags license
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "User ID"
+// @Param kind formData string true "hazmat or passenger_transport"
+// @Param number formData string false "Certificate number"
+// @Param issued_at formData string false "Issue date (YYYY-MM-DD)"
+// @Param expires_at formData string false "Expiry date (YYYY-MM-DD)"
+// @Param file formData file false "Scanned certificate"
+// @Success 200 {object} driverQualificationResponse{}
+// @Router /user/{id}/qualifications [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateDriverQualification(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can add qualifications"))
+		return
+	}
+	var req createDriverQualificationRequest
+	if err := bindForm(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	qualification := req.toModel(user.ID)
+	if file, err := c.FormFile("file"); err == nil {
+		filePath, err := s.saveFile(file, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		qualification.File = &filePath
+	}
+
+	if err := s.store.Licenses().CreateQualification(c.Request.Context(), &qualification); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, newDriverQualificationResponse(qualification))
+}
+
+// GetDriverQualifications godoc
+// @Summary Get qualifications of a driver
+// @Description Admins and the driver get the qualifications of a driver
+// @Tags license
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {array} []driverQualificationResponse{}
+// @Router /user/{id}/qualifications [get]
+// @Security ApiKeyAuth
+func (s *Server) GetDriverQualifications(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !canSeeDriverRecords(authPayload, user) {
+		c.Error(apperr.Forbidden("only admins and the driver can get qualifications"))
+		return
+	}
+	qualifications, err := s.store.Licenses().ListQualifications(c.Request.Context(), user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]driverQualificationResponse, len(qualifications))
+	for i, qualification := range qualifications {
+		response[i] = newDriverQualificationResponse(qualification)
+	}
+	c.JSON(200, response)
+}
+
+// DeleteDriverQualification godoc
+// @Summary Delete a qualification
+// @Description Delete a driver qualification
+// @Tags license
+// @Produce json
+// @Param id path int true "Qualification ID"
+// @Success 200 {object} driverQualificationResponse{}
+// @Router /qualifications/{id} [delete]
+// @Security ApiKeyAuth
+func (s *Server) DeleteDriverQualification(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can delete qualifications"))
+		return
+	}
+	qualification, err := s.store.Licenses().GetQualification(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.store.Licenses().DeleteQualification(c.Request.Context(), qualification.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newDriverQualificationResponse(qualification))
+}
@@ -0,0 +1,160 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/config"
+)
+
+// createTaskWith posts a task of the seeded driver in vehicleType and returns the response
+func createTaskWith(t *testing.T, h *apitest.Harness, vehicleType string) (int, api.ErrorResponse) {
+	t.Helper()
+	vehicle := addVehicle(t, h, "Active", nil)
+	// through the store, vehicle create refuses types without a license category
+	vehicle.Type = &vehicleType
+	if err := h.Store.Vehicles().Update(context.Background(), &vehicle); err != nil {
+		t.Fatal(err)
+	}
+	body := map[string]interface{}{
+		"driver_id": h.User(apitest.RoleDriver).ID, "vehicle_id": vehicle.ID, "status": "assigned",
+		"start_latitude": 51.12, "start_longitude": 71.43, "end_latitude": 51.09, "end_longitude": 71.41,
+	}
+	recorder := h.As(apitest.RoleAdmin).Do(http.MethodPost, "/task", body, nil)
+	var response api.ErrorResponse
+	if recorder.Code != http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return recorder.Code, response
+}
+
+func TestTaskNeedsQualifiedDriver(t *testing.T) {
+	nextYear, yesterday := time.Now().AddDate(1, 0, 0), time.Now().AddDate(0, 0, -1)
+	tests := []struct {
+		name        string
+		categories  string
+		expires     time.Time
+		vehicleType string
+		wantField   string
+		wantMessage string
+	}{
+		{"qualified", "B", nextYear, "car", "", ""},
+		{"type in upper case", "B", nextYear, "Van", "", ""},
+		{"no license", "", time.Time{}, "car", "driver_id", "driver has no license on file"},
+		{"expired license", "B", yesterday, "car", "driver_id", "driver license is expired"},
+		{"wrong category", "B", nextYear, "truck", "driver_id", "driver license lacks category C required for truck"},
+		{"unmapped type", "B,C,D", nextYear, "minibus", "vehicle_id",
+			"vehicle type minibus has no license category, set the type to one of bus, car, motorcycle, tanker, truck, van"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := apitest.NewMemory(t)
+			if tt.categories != "" {
+				h.AddLicense(h.User(apitest.RoleDriver).ID, tt.categories, tt.expires)
+			}
+			code, response := createTaskWith(t, h, tt.vehicleType)
+			if tt.wantField == "" {
+				if code != http.StatusOK {
+					t.Fatalf("status = %d %+v, want 200", code, response)
+				}
+				return
+			}
+			if code != http.StatusUnprocessableEntity || len(response.Fields) != 1 {
+				t.Fatalf("status = %d %+v, want 422 with one field", code, response)
+			}
+			if field := response.Fields[0]; field.Field != tt.wantField || field.Message != tt.wantMessage {
+				t.Errorf("field = %+v, want %s: %s", field, tt.wantField, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestUnlicensedDriversCanBeAllowed(t *testing.T) {
+	h := apitest.NewMemory(t, func(cfg *config.Config) { cfg.AllowUnlicensedDrivers = true })
+	if code, response := createTaskWith(t, h, "car"); code != http.StatusOK {
+		t.Errorf("status = %d %+v, want 200", code, response)
+	}
+}
+
+func TestVehicleTypeMustHaveLicenseCategory(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin := h.As(apitest.RoleAdmin)
+
+	var vehicle struct {
+		ID uint `json:"ID"`
+	}
+	body := map[string]interface{}{"license_plate": "777AAA02", "vin": "1HGCM82633A004352", "type": "Bus"}
+	if code := admin.Post("/vehicle", body, &vehicle); code != http.StatusOK {
+		t.Fatalf("create bus: status = %d, want 200", code)
+	}
+	for _, vehicleType := range []string{"minibus", "Lorry"} {
+		body["type"] = vehicleType
+		if code := admin.Post("/vehicle", body, nil); code != http.StatusUnprocessableEntity {
+			t.Errorf("create %s: status = %d, want 422", vehicleType, code)
+		}
+		if code := admin.Put(apitest.Path("/vehicle/:id", vehicle.ID), map[string]interface{}{"type": vehicleType}, nil); code != http.StatusUnprocessableEntity {
+			t.Errorf("update to %s: status = %d, want 422", vehicleType, code)
+		}
+	}
+}
+
+func TestLicenseAndQualifications(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	driverID := h.User(apitest.RoleDriver).ID
+	licensePath := apitest.Path("/user/:id/license", driverID)
+	qualificationsPath := apitest.Path("/user/:id/qualifications", driverID)
+
+	if code := driver.Get(licensePath, nil); code != http.StatusNotFound {
+		t.Errorf("license before it is saved: status = %d, want 404", code)
+	}
+	fields := map[string]string{"number": "KZ0000001", "categories": "c", "expires_at": time.Now().AddDate(1, 0, 0).Format("2006-01-02")}
+	if recorder := admin.Form(http.MethodPut, licensePath, fields, nil); recorder.Code != http.StatusOK {
+		t.Fatalf("save license: status = %d %s", recorder.Code, recorder.Body)
+	}
+	var license struct {
+		Number     string   `json:"number"`
+		Categories []string `json:"categories"`
+	}
+	if code := driver.Get(licensePath, &license); code != http.StatusOK || license.Number != "KZ0000001" || len(license.Categories) != 1 {
+		t.Errorf("driver getting their license: status = %d, license = %+v", code, license)
+	}
+	if user, err := h.Store.Users().Get(context.Background(), driverID); err != nil || user.DrivingLicenseNumber == nil || *user.DrivingLicenseNumber != "KZ0000001" {
+		t.Errorf("user license number not updated: %+v, %v", user.DrivingLicenseNumber, err)
+	}
+
+	// a tanker needs a hazmat qualification on top of category C
+	if code, response := createTaskWith(t, h, "tanker"); code != http.StatusUnprocessableEntity {
+		t.Errorf("tanker without hazmat: status = %d %+v, want 422", code, response)
+	}
+	var qualification struct {
+		ID uint `json:"ID"`
+	}
+	if recorder := admin.Form(http.MethodPost, qualificationsPath, map[string]string{"kind": "hazmat"}, &qualification); recorder.Code != http.StatusOK {
+		t.Fatalf("add hazmat: status = %d %s", recorder.Code, recorder.Body)
+	}
+	var qualifications []struct {
+		Kind string `json:"kind"`
+	}
+	if code := driver.Get(qualificationsPath, &qualifications); code != http.StatusOK || len(qualifications) != 1 || qualifications[0].Kind != "hazmat" {
+		t.Errorf("qualifications: status = %d, %+v", code, qualifications)
+	}
+	if code, response := createTaskWith(t, h, "tanker"); code != http.StatusOK {
+		t.Errorf("tanker with hazmat: status = %d %+v, want 200", code, response)
+	}
+
+	qualificationPath := apitest.Path("/qualifications/:id", qualification.ID)
+	if code := admin.Delete(qualificationPath); code != http.StatusOK {
+		t.Errorf("delete qualification: status = %d", code)
+	}
+	if code := admin.Delete(qualificationPath); code != http.StatusNotFound {
+		t.Errorf("delete qualification again: status = %d, want 404", code)
+	}
+}
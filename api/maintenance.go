@@ -1,34 +1,106 @@
 package api
 
 import (
-	"errors"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
 	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
 type createMaintenanceRecordRequest struct {
-	VehicleID           *uint      `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
-	MaintenancePersonID *uint      `gorm:"not null" json:"maintenance_person_id"`
+	VehicleID           *uint      `json:"vehicle_id" binding:"required"`
+	MaintenancePersonID *uint      `json:"maintenance_person_id"`
+	MaintenanceDate     *time.Time `json:"maintenance_date"`
+	ServiceType         *string    `json:"service_type" binding:"omitempty,max=100"`
+	Status              *string    `json:"status" binding:"required,oneof=Pending Done"`
+	TotalCost           *float64   `json:"total_cost" binding:"required,gte=0"`
+	MileageAtService    *int       `json:"mileage_at_service" binding:"omitempty,gte=0"`
+	Notes               *string    `json:"notes" binding:"omitempty,max=1000"`
+}
+
+func (r createMaintenanceRecordRequest) toModel() models.MaintenanceRecord {
+	return models.MaintenanceRecord{
+		VehicleID:           r.VehicleID,
+		MaintenancePersonID: r.MaintenancePersonID,
+		MaintenanceDate:     r.MaintenanceDate,
+		ServiceType:         r.ServiceType,
+		Status:              maintenanceStatus(r.Status),
+		TotalCost:           r.TotalCost,
+		MileageAtService:    r.MileageAtService,
+		Notes:               r.Notes,
+	}
+}
+
+// updateMaintenanceRecordRequest changes only the fields that are present, a record
+// stays with the vehicle it was created for
+type updateMaintenanceRecordRequest struct {
+	MaintenancePersonID *uint      `json:"maintenance_person_id"`
+	MaintenanceDate     *time.Time `json:"maintenance_date"`
+	ServiceType         *string    `json:"service_type" binding:"omitempty,max=100"`
+	Status              *string    `json:"status" binding:"omitempty,oneof=Pending Done"`
+	TotalCost           *float64   `json:"total_cost" binding:"omitempty,gte=0"`
+	MileageAtService    *int       `json:"mileage_at_service" binding:"omitempty,gte=0"`
+	Notes               *string    `json:"notes" binding:"omitempty,max=1000"`
+}
+
+func (r updateMaintenanceRecordRequest) apply(record *models.MaintenanceRecord) {
+	setIfPresent(&record.MaintenancePersonID, r.MaintenancePersonID)
+	setIfPresent(&record.MaintenanceDate, r.MaintenanceDate)
+	setIfPresent(&record.ServiceType, r.ServiceType)
+	setIfPresent(&record.Status, maintenanceStatus(r.Status))
+	setIfPresent(&record.TotalCost, r.TotalCost)
+	setIfPresent(&record.MileageAtService, r.MileageAtService)
+	setIfPresent(&record.Notes, r.Notes)
+}
+
+func maintenanceStatus(status *string) *models.MaintenanceStatus {
+	if status == nil {
+		return nil
+	}
+	value := models.MaintenanceStatus(*status)
+	return &value
+}
+
+type maintenanceRecordResponse struct {
+	ID                  uint       `json:"ID"`
+	VehicleID           *uint      `json:"vehicle_id"`
+	MaintenancePersonID *uint      `json:"maintenance_person_id"`
 	MaintenanceDate     *time.Time `json:"maintenance_date"`
 	ServiceType         *string    `json:"service_type"`
-	Status              *string    `gorm:"not null" json:"status"`
-	TotalCost           *float64   `gorm:"not null" json:"total_cost"`
+	Status              *string    `json:"status"`
+	TotalCost           *float64   `json:"total_cost"`
 	MileageAtService    *int       `json:"mileage_at_service"`
 	Notes               *string    `json:"notes"`
 }
-type createMaintenanceRecordResponse struct {
-	VehicleID           *uint      `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
-	MaintenancePersonID *uint      `gorm:"not null" json:"maintenance_person_id"`
-	MaintenanceDate     *time.Time `json:"maintenance_date"`
-	ServiceType         *string    `json:"service_type"`
-	Status              *string    `gorm:"not null" json:"status"`
 
-	TotalCost        *float64 `gorm:"not null" json:"total_cost"`
-	MileageAtService *int     `json:"mileage_at_service"`
-	Notes            *string  `json:"notes"`
+func newMaintenanceRecordResponse(record models.MaintenanceRecord) maintenanceRecordResponse {
+	response := maintenanceRecordResponse{
+		ID:                  record.ID,
+		VehicleID:           record.VehicleID,
+		MaintenancePersonID: record.MaintenancePersonID,
+		MaintenanceDate:     record.MaintenanceDate,
+		ServiceType:         record.ServiceType,
+		TotalCost:           record.TotalCost,
+		MileageAtService:    record.MileageAtService,
+		Notes:               record.Notes,
+	}
+	if record.Status != nil {
+		status := string(*record.Status)
+		response.Status = &status
+	}
+	return response
+}
+
+func newMaintenanceRecordResponses(records []models.MaintenanceRecord) []maintenanceRecordResponse {
+	response := make([]maintenanceRecordResponse, len(records))
+	for i, record := range records {
+		response[i] = newMaintenanceRecordResponse(record)
+	}
+	return response
 }
 
 // CreateMaintenanceRecord godoc
@@ -38,26 +110,38 @@ type createMaintenanceRecordResponse struct {
 // @Accept  json
 // @Produce  json
 // @Param maintenance body createMaintenanceRecordRequest true "Maintenance"
-// @Success 200 {object} createMaintenanceRecordResponse{}
+// @Success 200 {object} maintenanceRecordResponse{}
 // @Router /maintenance [post]
 // @Security ApiKeyAuth
 func (s *Server) CreateMaintenanceRecord(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can create maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can create maintenance records"))
 		return
 	}
-	var maintenance models.MaintenanceRecord
-	if err := c.ShouldBindJSON(&maintenance); err != nil {
-		c.JSON(400, errorResponse(err))
+	var req createMaintenanceRecordRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	if err := s.DB.Create(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	ctx := c.Request.Context()
+	maintenance := req.toModel()
+	err := s.storeTransaction(ctx, func(tx store.Store) error {
+		if err := tx.Maintenance().Create(ctx, &maintenance); err != nil {
+			return err
+		}
+		return tx.WriteEvent(ctx, events.Event{
+			Type:      events.MaintenanceCreated,
+			VehicleID: maintenance.VehicleID,
+			Data:      newMaintenanceRecordResponse(maintenance),
+		})
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponse(maintenance))
 }
 
 // GetMaintenanceRecordsOfVehicle godoc
@@ -67,21 +151,21 @@ func (s *Server) CreateMaintenanceRecord(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param vehicle_id path int true "Vehicle ID"
-// @Success 200 {object} []createMaintenanceRecordResponse{}
+// @Success 200 {array} maintenanceRecordResponse{}
 // @Router /maintenance [get]
 // @Security ApiKeyAuth
 func (s *Server) GetMaintenanceRecordsOfVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can get maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can get maintenance records"))
 		return
 	}
-	var maintenance []models.MaintenanceRecord
-	if err := s.DB.Where("vehicle_id = ?", c.Param("vehicle_id")).Find(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().ListByVehicle(c.Request.Context(), idParam(c, "vehicle_id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponses(maintenance))
 }
 
 // GetMaintenanceRecords godoc
@@ -90,21 +174,21 @@ func (s *Server) GetMaintenanceRecordsOfVehicle(c *gin.Context) {
 // @Tags maintenance
 // @Accept  json
 // @Produce  json
-// @Success 200 {object} []createMaintenanceRecordResponse{}
+// @Success 200 {array} maintenanceRecordResponse{}
 // @Router /maintenance [get]
 // @Security ApiKeyAuth
 func (s *Server) GetMaintenanceRecords(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can get maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can get maintenance records"))
 		return
 	}
-	var maintenance []models.MaintenanceRecord
-	if err := s.DB.Find(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponses(maintenance))
 }
 
 // GetMaintenanceRecord godoc
@@ -114,21 +198,21 @@ func (s *Server) GetMaintenanceRecords(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Maintenance ID"
-// @Success 200 {object} createMaintenanceRecordResponse{}
+// @Success 200 {object} maintenanceRecordResponse{}
 // @Router /maintenance/{id} [get]
 // @Security ApiKeyAuth
 func (s *Server) GetMaintenanceRecord(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can get maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can get maintenance records"))
 		return
 	}
-	var maintenance models.MaintenanceRecord
-	if err := s.DB.First(&maintenance, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponse(maintenance))
 }
 
 // UpdateMaintenanceRecord godoc
@@ -138,30 +222,32 @@ func (s *Server) GetMaintenanceRecord(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Maintenance ID"
-// @Param maintenance body createMaintenanceRecordRequest true "Maintenance"
-// @Success 200 {object} createMaintenanceRecordResponse{}
+// @Param maintenance body updateMaintenanceRecordRequest true "Fields to change"
+// @Success 200 {object} maintenanceRecordResponse{}
 // @Router /maintenance/{id} [put]
 // @Security ApiKeyAuth
 func (s *Server) UpdateMaintenanceRecord(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can update maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can update maintenance records"))
 		return
 	}
-	var maintenance models.MaintenanceRecord
-	if err := s.DB.First(&maintenance, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	if err := c.ShouldBindJSON(&maintenance); err != nil {
-		c.JSON(400, errorResponse(err))
+	var req updateMaintenanceRecordRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	if err := s.DB.Save(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	req.apply(&maintenance)
+	if err := s.store.Maintenance().Update(c.Request.Context(), &maintenance); err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponse(maintenance))
 }
 
 // DeleteMaintenanceRecord godoc
@@ -171,25 +257,25 @@ func (s *Server) UpdateMaintenanceRecord(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Maintenance ID"
-// @Success 200 {object} createMaintenanceRecordResponse{}
+// @Success 200 {object} maintenanceRecordResponse{}
 // @Router /maintenance/{id} [delete]
 // @Security ApiKeyAuth
 func (s *Server) DeleteMaintenanceRecord(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can delete maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can delete maintenance records"))
 		return
 	}
-	var maintenance models.MaintenanceRecord
-	if err := s.DB.First(&maintenance, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	if err := s.DB.Delete(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := s.store.Maintenance().Delete(c.Request.Context(), maintenance.ID); err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponse(maintenance))
 }
 
 // GetMaintenanceRecordsOfUser godoc
@@ -199,19 +285,19 @@ func (s *Server) DeleteMaintenanceRecord(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param user_id path int true "User ID"
-// @Success 200 {object} []createMaintenanceRecordResponse{}
+// @Success 200 {array} maintenanceRecordResponse{}
 // @Router /maintenance [get]
 // @Security ApiKeyAuth
 func (s *Server) GetMaintenanceRecordsOfUser(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "Admin" || authPayload.Role != "Maintenance" {
-		c.JSON(400, errorResponse(errors.New("only admins and maintenance can get maintenance records")))
+	if authPayload.Role != "Admin" && authPayload.Role != "Maintenance_person" {
+		c.Error(apperr.Forbidden("only admins and maintenance can get maintenance records"))
 		return
 	}
-	var maintenance []models.MaintenanceRecord
-	if err := s.DB.Where("user_id = ?", c.Param("user_id")).Find(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().ListByPerson(c.Request.Context(), idParam(c, "user_id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, maintenance)
+	c.JSON(200, newMaintenanceRecordResponses(maintenance))
 }
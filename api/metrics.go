@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rassulmagauin/VMS_SWE/store"
+	"gorm.io/gorm"
+)
+
+const (
+	metricsNamespace = "vms"
+	// domainMetricsTimeout bounds the queries run on every scrape
+	domainMetricsTimeout = 5 * time.Second
+)
+
+// metrics are served on /metrics. Each server has its own registry so tests can build many servers.
+type metrics struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	uploadSize      prometheus.Histogram
+}
+
+func newMetrics(db *gorm.DB, st store.Store) (*metrics, error) {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		uploadSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "upload_size_bytes",
+			Help:      "Size of uploaded files.",
+			// 1KB to 64MB
+			Buckets: prometheus.ExponentialBuckets(1<<10, 4, 9),
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.uploadSize,
+		newDomainCollector(st),
+	)
+	// handler tests run without a database
+	if db != nil {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		m.registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, metricsNamespace))
+	}
+	return m, nil
+}
+
+// middleware observes the duration of every request. Requests matching no route share one label
+// so scanners cannot blow up the number of series.
+func (m *metrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}))
+}
+
+// domainCollector counts vehicles, tasks and maintenance records when scraped
+type domainCollector struct {
+	store              store.Store
+	vehicles           *prometheus.Desc
+	openTasks          *prometheus.Desc
+	pendingMaintenance *prometheus.Desc
+}
+
+func newDomainCollector(st store.Store) *domainCollector {
+	return &domainCollector{
+		store: st,
+		vehicles: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "vehicles"),
+			"Number of vehicles by status.", []string{"status"}, nil),
+		openTasks: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "open_tasks"),
+			"Number of tasks that are neither completed nor canceled.", nil, nil),
+		pendingMaintenance: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "pending_maintenance_records"),
+			"Number of maintenance records that are pending.", nil, nil),
+	}
+}
+
+func (d *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- d.vehicles
+	ch <- d.openTasks
+	ch <- d.pendingMaintenance
+}
+
+// Collect reports a failed query as an invalid metric, the handler leaves it out and serves the rest
+func (d *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), domainMetricsTimeout)
+	defer cancel()
+
+	if counts, err := d.store.Vehicles().CountByStatus(ctx); err != nil {
+		d.invalid(ch, d.vehicles, err)
+	} else {
+		for status, count := range counts {
+			ch <- prometheus.MustNewConstMetric(d.vehicles, prometheus.GaugeValue, float64(count), status)
+		}
+	}
+	d.gauge(ctx, ch, d.openTasks, d.store.Tasks().CountOpen)
+	d.gauge(ctx, ch, d.pendingMaintenance, d.store.Maintenance().CountPending)
+}
+
+func (d *domainCollector) gauge(ctx context.Context, ch chan<- prometheus.Metric, desc *prometheus.Desc, count func(context.Context) (int64, error)) {
+	n, err := count(ctx)
+	if err != nil {
+		d.invalid(ch, desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n))
+}
+
+func (d *domainCollector) invalid(ch chan<- prometheus.Metric, desc *prometheus.Desc, err error) {
+	slog.Error("cannot collect metric", slog.String("metric", desc.String()), slog.Any("error", err))
+	ch <- prometheus.NewInvalidMetric(desc, fmt.Errorf("cannot collect metric: %w", err))
+}
@@ -2,10 +2,24 @@ package api
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/config"
+	"github.com/rassulmagauin/VMS_SWE/logging"
+	"github.com/rassulmagauin/VMS_SWE/ratelimit"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
@@ -13,39 +27,188 @@ const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
 	authorizationPayloadKey = "authorization_payload"
+
+	requestIDHeader = "X-Request-ID"
+
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// validRequestID keeps client supplied IDs short and printable
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDMiddleware gives every request an ID, the one from the X-Request-ID header if the
+// client sent a usable one, echoes it in the response and attaches it to the request's log lines
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Header(requestIDHeader, id)
+		ctx := logging.NewContext(c.Request.Context())
+		logging.AddAttrs(ctx, slog.String("request_id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// accessLogMiddleware logs every finished request, server errors as errors and client errors as warnings
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// recoveryMiddleware turns a panic into a 500 and logs it with its stack
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		slog.ErrorContext(c.Request.Context(), "panic", slog.String("error", fmt.Sprint(recovered)), slog.String("stack", string(debug.Stack())))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: "internal server error", Code: apperr.CodeInternal})
+	})
+}
+
+// authMiddleware accepts the access tokens of users that still exist and have not changed
+// their password since the token was issued
+func authMiddleware(tokenMaker token.Maker, st store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authorizationHeader := c.GetHeader(authorizationHeaderKey)
 		if authorizationHeader == "" {
-			c.JSON(http.StatusUnauthorized, errorResponse(errors.New("authorization header is required")))
+			c.Error(apperr.Unauthorized("authorization header is required"))
 			c.Abort()
 			return
 		}
 
 		fields := strings.Fields(authorizationHeader)
 		if len(fields) < 2 {
-			err := errors.New("invalid authorization header format")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			c.Error(apperr.Unauthorized("invalid authorization header format"))
+			c.Abort()
 			return
 		}
 
 		authorizationType := strings.ToLower(fields[0])
 
 		if authorizationType != authorizationTypeBearer {
-			err := errors.New("authorization type is not bearer")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			c.Error(apperr.Unauthorized("authorization type is not bearer"))
+			c.Abort()
 			return
 		}
 		accessToken := fields[1]
 		payload, err := tokenMaker.VerifyToken(accessToken)
 		if err != nil {
-			c.JSON(401, errorResponse(err))
+			c.Error(apperr.Unauthorized("%s", err.Error()))
 			c.Abort()
 			return
 		}
+		user, err := st.Users().Get(c.Request.Context(), payload.UserID)
+		if errors.Is(err, store.ErrNotFound) {
+			c.Error(apperr.Unauthorized("user no longer exists"))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if user.PasswordChangedAt != nil && payload.IssuedAt.Before(*user.PasswordChangedAt) {
+			c.Error(apperr.Unauthorized("token was revoked by a password change, log in again"))
+			c.Abort()
+			return
+		}
+		// the token identifies the account, its current name and role are what count
+		payload.Username = user.Username
+		payload.Role = *user.Role
 		c.Set(authorizationPayloadKey, payload)
+		logging.AddAttrs(c.Request.Context(), slog.String("user", payload.Username), slog.String("role", payload.Role))
+		c.Next()
+	}
+}
+
+// rateLimitMiddleware limits the requests made with each access token, it runs after authMiddleware
+func rateLimitMiddleware(limiter *ratelimit.Limiter, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit.Requests <= 0 {
+			c.Next()
+			return
+		}
+		payload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+		result, err := limiter.Allow(c.Request.Context(), "token:"+payload.ID.String(), limit)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Header(rateLimitLimitHeader, strconv.FormatInt(limit.Requests, 10))
+		c.Header(rateLimitRemainingHeader, strconv.FormatInt(result.Remaining, 10))
+		if !result.Allowed {
+			c.Error(apperr.TooMany(result.RetryAfter, "too many requests, try again later"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// idParamsMiddleware rejects requests whose id path parameters are not numbers,
+// so handlers can pass them straight to the database
+func idParamsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, param := range c.Params {
+			if param.Key != "id" && !strings.HasSuffix(param.Key, "_id") {
+				continue
+			}
+			if _, err := strconv.ParseUint(param.Value, 10, 64); err != nil {
+				c.Error(apperr.BadRequest("%s must be a number", param.Key))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// idParam returns a numeric path parameter, idParamsMiddleware has already rejected other values
+func idParam(c *gin.Context, key string) uint {
+	id, _ := strconv.ParseUint(c.Param(key), 10, 64)
+	return uint(id)
+}
+
+// corsMiddleware answers preflight requests and rejects cross-origin requests from origins the configuration does not allow
+func corsMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	return cors.New(cors.Config{
+		AllowOriginFunc:  cfg.AllowsOrigin,
+		AllowMethods:     cfg.AllowedMethods,
+		AllowHeaders:     cfg.AllowedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	})
+}
+
+// maxBodySize rejects request bodies larger than limit bytes while they are read
+func maxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
 		c.Next()
 	}
 }
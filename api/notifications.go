@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/notify"
+	"gorm.io/gorm"
+)
+
+// maintenanceDueWarning is how long before Vehicle.NextMaintenance admins are reminded
+const maintenanceDueWarning = 7 * 24 * time.Hour
+
+type notificationResponse struct {
+	ID        uint       `json:"ID"`
+	Kind      string     `json:"kind"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+}
+
+func newNotificationResponse(notification models.Notification) notificationResponse {
+	return notificationResponse{
+		ID:        notification.ID,
+		Kind:      notification.Kind,
+		Subject:   notification.Subject,
+		Body:      notification.Body,
+		ReadAt:    notification.ReadAt,
+		CreatedAt: notification.CreatedAt,
+	}
+}
+
+// notifyAdmins sends the notification to every admin
+func (s *Server) notifyAdmins(kind notify.Kind, reference string, data notify.Data) error {
+	var admins []models.User
+	if err := s.DB.Where("role = ?", string(models.RolesListAdmin)).Find(&admins).Error; err != nil {
+		return err
+	}
+	var failures []error
+	for _, admin := range admins {
+		if err := s.notifier.Notify(notify.Message{UserID: admin.ID, Kind: kind, Reference: reference, Data: data}); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// vehicleLabel names a vehicle in notifications by its plate
+func vehicleLabel(vehicle models.Vehicle) string {
+	if vehicle.LicensePlate != nil {
+		return *vehicle.LicensePlate
+	}
+	return fmt.Sprintf("#%d", vehicle.ID)
+}
+
+func (s *Server) vehicleLabelByID(vehicleID *uint) string {
+	if vehicleID == nil {
+		return ""
+	}
+	var vehicle models.Vehicle
+	if err := s.DB.Unscoped().First(&vehicle, *vehicleID).Error; err != nil {
+		return fmt.Sprintf("#%d", *vehicleID)
+	}
+	return vehicleLabel(vehicle)
+}
+
+// notifyForEvent is the outbox subscriber turning events into notifications. Every message
+// carries a reference so an event dispatched twice does not notify twice.
+func (s *Server) notifyForEvent(evt events.Event) error {
+	var data struct {
+		TaskID       uint   `json:"task_id"`
+		DriverID     uint   `json:"driver_id"`
+		StartTime    string `json:"start_time"`
+		AssignmentID uint   `json:"assignment_id"`
+		AuctionID    uint   `json:"auction_id"`
+		WinnerID     uint   `json:"winner_id"`
+		Date         string `json:"date"`
+		ID           uint   `json:"ID"`
+		Message      string `json:"message"`
+		DocumentID   *uint  `json:"document_id"`
+	}
+	if raw, ok := evt.Data.(json.RawMessage); ok {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+	}
+	switch evt.Type {
+	case events.TaskAssigned:
+		return s.notifier.Notify(notify.Message{
+			UserID:    data.DriverID,
+			Kind:      notify.TaskAssigned,
+			Reference: fmt.Sprintf("task:%d:driver:%d", data.TaskID, data.DriverID),
+			Data:      notify.Data{"task_id": data.TaskID, "start_time": data.StartTime},
+		})
+	case events.VehicleAssigned:
+		return s.notifier.Notify(notify.Message{
+			UserID:    data.DriverID,
+			Kind:      notify.VehicleAssigned,
+			Reference: fmt.Sprintf("assignment:%d", data.AssignmentID),
+			Data:      notify.Data{"vehicle": s.vehicleLabelByID(evt.VehicleID)},
+		})
+	case events.AuctionClosed:
+		if data.WinnerID == 0 {
+			return nil
+		}
+		return s.notifier.Notify(notify.Message{
+			UserID:    data.WinnerID,
+			Kind:      notify.AuctionWon,
+			Reference: fmt.Sprintf("auction:%d", data.AuctionID),
+			Data:      notify.Data{"auction_id": data.AuctionID, "vehicle": s.vehicleLabelByID(evt.VehicleID)},
+		})
+	case events.MaintenanceDue:
+		return s.notifyAdmins(notify.MaintenanceDue, fmt.Sprintf("vehicle:%d:%s", *evt.VehicleID, data.Date), notify.Data{
+			"vehicle": s.vehicleLabelByID(evt.VehicleID),
+			"date":    data.Date,
+		})
+	case events.AlertRaised:
+		if data.DocumentID == nil {
+			return nil
+		}
+		return s.notifyAdmins(notify.DocumentExpiring, fmt.Sprintf("alert:%d", data.ID), notify.Data{
+			"vehicle": s.vehicleLabelByID(evt.VehicleID),
+			"message": data.Message,
+		})
+	}
+	return nil
+}
+
+// checkMaintenanceDue raises an alert and a maintenance.due event for vehicles whose next maintenance
+// is close or overdue, and resolves the alerts of vehicles that are no longer due
+func (s *Server) checkMaintenanceDue(ctx context.Context) error {
+	now := time.Now()
+	var vehicles []models.Vehicle
+	if err := s.DB.WithContext(ctx).Where("next_maintenance <= ?", now.Add(maintenanceDueWarning)).Find(&vehicles).Error; err != nil {
+		return err
+	}
+	var failures []error
+	due := make([]uint, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		vehicle := vehicle
+		due = append(due, vehicle.ID)
+		date := vehicle.NextMaintenance.Format("2006-01-02")
+		err := s.transaction(ctx, func(tx *gorm.DB) error {
+			created, err := s.raiseAlert(tx, &models.Alert{
+				Kind:      alertMaintenanceDue,
+				Severity:  alertSeverityWarning,
+				Message:   fmt.Sprintf("maintenance of vehicle %s is due on %s", vehicleLabel(vehicle), date),
+				VehicleID: &vehicle.ID,
+			})
+			if err != nil || !created {
+				return err
+			}
+			return s.emit(tx, events.Event{
+				Type:      events.MaintenanceDue,
+				VehicleID: &vehicle.ID,
+				DriverID:  vehicle.AssignedDriver,
+				Data:      gin.H{"vehicle_id": vehicle.ID, "date": date},
+			})
+		})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("vehicle %d: %w", vehicle.ID, err))
+		}
+	}
+	query := s.DB.WithContext(ctx).Model(&models.Alert{}).Where("kind = ? AND resolved_at IS NULL", alertMaintenanceDue)
+	if len(due) > 0 {
+		query = query.Where("vehicle_id NOT IN ?", due)
+	}
+	if err := query.Update("resolved_at", now).Error; err != nil {
+		failures = append(failures, err)
+	}
+	return errors.Join(failures...)
+}
+
+// GetNotifications godoc
+// @Summary Get notifications
+// @Description Returns the in-app inbox of the current user, newest first
+// @Tags notification
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Success 200 {array} []notificationResponse{}
+// @Router /notifications [get]
+// @Security ApiKeyAuth
+func (s *Server) GetNotifications(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	query := s.DB.WithContext(c.Request.Context()).Where("user_id = ? AND in_app = ?", user.ID, true)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").Find(&notifications).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]notificationResponse, len(notifications))
+	for i, notification := range notifications {
+		response[i] = newNotificationResponse(notification)
+	}
+	c.JSON(200, response)
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read
+// @Description Mark a notification of the current user as read
+// @Tags notification
+// @Produce json
+// @Param id path int true "Notification ID"
+// @Success 200 {object} notificationResponse{}
+// @Router /notifications/{id}/read [post]
+// @Security ApiKeyAuth
+func (s *Server) MarkNotificationRead(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var notification models.Notification
+	if err := s.DB.WithContext(c.Request.Context()).Where("user_id = ?", user.ID).First(&notification, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := s.DB.WithContext(c.Request.Context()).Model(&notification).Update("read_at", now).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	c.JSON(200, newNotificationResponse(notification))
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications as read
+// @Description Mark every unread notification of the current user as read
+// @Tags notification
+// @Produce json
+// @Success 200 {object} string "Number of notifications marked as read"
+// @Router /notifications/read [post]
+// @Security ApiKeyAuth
+func (s *Server) MarkAllNotificationsRead(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result := s.DB.WithContext(c.Request.Context()).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", user.ID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	c.JSON(200, gin.H{"updated": result.RowsAffected})
+}
+
+// GetNotificationPreferences godoc
+// @Summary Get notification preferences
+// @Description Returns which channels the current user receives each kind of notification on
+// @Tags notification
+// @Produce json
+// @Success 200 {array} []notify.Preference{}
+// @Router /notifications/preferences [get]
+// @Security ApiKeyAuth
+func (s *Server) GetNotificationPreferences(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	preferences, err := s.notifier.Preferences(user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, preferences)
+}
+
+// UpdateNotificationPreferences godoc
+// @Summary Update notification preferences
+// @Description Sets the in-app and email channels per notification kind for the current user
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param preferences body []notify.Preference true "Preferences"
+// @Success 200 {array} []notify.Preference{}
+// @Router /notifications/preferences [put]
+// @Security ApiKeyAuth
+func (s *Server) UpdateNotificationPreferences(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var req []notify.Preference
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	for _, preference := range req {
+		if !notify.ValidKind(preference.Kind) {
+			c.Error(apperr.Invalid("kind", "unknown notification kind %s", preference.Kind))
+			return
+		}
+	}
+	for _, preference := range req {
+		if err := s.notifier.SetPreference(user.ID, preference); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	preferences, err := s.notifier.Preferences(user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, preferences)
+}
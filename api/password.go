@@ -0,0 +1,383 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/ratelimit"
+	"github.com/rassulmagauin/VMS_SWE/store"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"github.com/rassulmagauin/VMS_SWE/utils"
+)
+
+// checkPassword applies the password policy to a new password sent in field
+func (s *Server) checkPassword(field, password string) error {
+	if err := s.config.Password.Policy().Check(password); err != nil {
+		return apperr.Invalid(field, "%s", err.Error())
+	}
+	return nil
+}
+
+// setPassword stores the new password of the user, which revokes the access tokens issued
+// before at and the unused reset tokens
+func setPassword(ctx context.Context, st store.Store, user *models.User, password string, at time.Time) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.HashedPassword = &hashedPassword
+	user.PasswordChangedAt = &at
+	if err := st.Users().Update(ctx, user); err != nil {
+		return err
+	}
+	return st.PasswordResets().DeleteForUser(ctx, user.ID)
+}
+
+// newResetToken returns a random token for the email and the hash that is stored
+func newResetToken() (string, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", fmt.Errorf("cannot generate reset token: %w", err)
+	}
+	resetToken := base64.RawURLEncoding.EncodeToString(random)
+	return resetToken, hashResetToken(resetToken), nil
+}
+
+func hashResetToken(resetToken string) string {
+	sum := sha256.Sum256([]byte(resetToken))
+	return hex.EncodeToString(sum[:])
+}
+
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,max=72"`
+}
+
+type changePasswordResponse struct {
+	// AccessToken replaces the tokens revoked by the change
+	AccessToken string `json:"access_token"`
+}
+
+// ChangePassword godoc
+// @Summary Change own password
+// @Description Changes the password of the logged in user, who must send the old one. All access tokens
+// @Description issued before are revoked; the response holds a new one.
+// @Param password body changePasswordRequest true "Passwords"
+// @Produce application/json
+// @Tags user
+// @Success 200 {object} changePasswordResponse{}
+// @Failure 422 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /user/me/password [post]
+// @Security ApiKeyAuth
+func (s *Server) ChangePassword(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	var req changePasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	// guessing the old password counts as failed logins, so a stolen token cannot be used to find it
+	locked, err := s.lockout.Locked(ctx, authPayload.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if locked > 0 {
+		c.Error(apperr.TooMany(locked, "too many failed logins, try again later"))
+		return
+	}
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := utils.CheckPassword(req.OldPassword, *user.HashedPassword); err != nil {
+		if _, err := s.lockout.Fail(ctx, user.Username); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Error(apperr.Invalid("old_password", "old password is wrong"))
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		c.Error(apperr.Invalid("new_password", "new password must differ from the old one"))
+		return
+	}
+	if err := s.checkPassword("new_password", req.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := setPassword(ctx, s.store, &user, req.NewPassword, time.Now()); err != nil {
+		c.Error(err)
+		return
+	}
+	accessToken, err := s.tokenMaker.CreateToken(user.ID, user.Username, *user.Role, s.config.Token.AccessTokenDuration)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, changePasswordResponse{AccessToken: accessToken})
+}
+
+type resetUserPasswordResponse struct {
+}
+
+// ResetUserPassword godoc
+// @Summary Reset password of user
+// @Description Replaces the password of the user with a random one, revokes their access tokens and emails
+// @Description them a link to choose a new password. The user needs an email address and SMTP must be configured.
+// @Description When the email cannot be sent the password is left unchanged.
+// @Param id path string true "User ID"
+// @Produce application/json
+// @Tags user
+// @Success 200 {object} resetUserPasswordResponse{}
+// @Failure 409 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /user/{id}/password/reset [post]
+// @Security ApiKeyAuth
+func (s *Server) ResetUserPassword(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can reset passwords"))
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := s.store.Users().Get(ctx, idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if s.mailer == nil {
+		c.Error(apperr.Conflict("password reset emails cannot be sent, smtp is not configured"))
+		return
+	}
+	if user.Email == nil || *user.Email == "" {
+		c.Error(apperr.Conflict("user has no email address to send the reset link to"))
+		return
+	}
+	// nobody knows the random password, the user has to follow the link
+	random, _, err := newResetToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = s.store.Transaction(ctx, func(tx store.Store) error {
+		if err := setPassword(ctx, tx, &user, random, time.Now()); err != nil {
+			return err
+		}
+		resetToken, err := createResetToken(ctx, tx, user.ID, s.config.Password.ResetTokenTTL)
+		if err != nil {
+			return err
+		}
+		// sent before the commit, the user must not lose the old password without getting the link
+		if err := s.mailer.Send(*user.Email, resetEmailSubject, s.resetEmailBody(user, resetToken, true)); err != nil {
+			return apperr.BadGateway(err, "cannot send the reset email, the password was not changed")
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resetUserPasswordResponse{})
+}
+
+// createResetToken stores a new reset token for the user and returns it
+func createResetToken(ctx context.Context, st store.Store, userID uint, ttl time.Duration) (string, error) {
+	resetToken, hash, err := newResetToken()
+	if err != nil {
+		return "", err
+	}
+	record := models.PasswordResetToken{UserID: userID, TokenHash: hash, ExpiresAt: time.Now().Add(ttl)}
+	if err := st.PasswordResets().Create(ctx, &record); err != nil {
+		return "", err
+	}
+	return resetToken, nil
+}
+
+const resetEmailSubject = "Reset your password"
+
+// resetEmailBody is the text of the email with the reset link
+func (s *Server) resetEmailBody(user models.User, resetToken string, byAdmin bool) string {
+	link := resetToken
+	if s.config.Password.ResetURL != "" {
+		u, _ := url.Parse(s.config.Password.ResetURL)
+		query := u.Query()
+		query.Set("token", resetToken)
+		u.RawQuery = query.Encode()
+		link = u.String()
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello %s,\n\n", user.Username)
+	if byAdmin {
+		body.WriteString("An administrator has reset your password.")
+	} else {
+		body.WriteString("Somebody asked to reset your password.")
+	}
+	fmt.Fprintf(&body, " Use this to choose a new one within %s:\n\n%s\n", s.config.Password.ResetTokenTTL, link)
+	if !byAdmin {
+		body.WriteString("\nIf it was not you, ignore this email, your password stays the same.\n")
+	}
+	return body.String()
+}
+
+// sendResetEmail mails the reset link in the background, so the response time does not
+// tell whether a user has an email address
+func (s *Server) sendResetEmail(ctx context.Context, user models.User, resetToken string) {
+	body := s.resetEmailBody(user, resetToken, false)
+	ctx = context.WithoutCancel(ctx)
+	s.emails.Add(1)
+	go func() {
+		defer s.emails.Done()
+		if err := s.mailer.Send(*user.Email, resetEmailSubject, body); err != nil {
+			slog.ErrorContext(ctx, "cannot send password reset email", slog.Uint64("user_id", uint64(user.ID)), slog.Any("error", err))
+		}
+	}()
+}
+
+type forgotPasswordRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type forgotPasswordResponse struct {
+}
+
+// ForgotPassword godoc
+// @Summary Forgot password
+// @Description Emails a single-use link to choose a new password to the user, if they exist and have an
+// @Description email address. The response is the same either way, so usernames cannot be probed.
+// @Param user body forgotPasswordRequest true "User"
+// @Produce application/json
+// @Tags user
+// @Success 202 {object} forgotPasswordResponse{}
+// @Failure 429 {object} ErrorResponse
+// @Router /password/forgot [post]
+func (s *Server) ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	window := s.config.RateLimit.LoginWindow
+	limits := []struct {
+		key   string
+		limit ratelimit.Limit
+	}{
+		{"password_reset:ip:" + c.ClientIP(), ratelimit.Limit{Requests: s.config.RateLimit.LoginPerIP, Window: window}},
+		{"password_reset:username:" + strings.ToLower(req.Username), ratelimit.Limit{Requests: s.config.RateLimit.LoginPerUsername, Window: window}},
+	}
+	for _, l := range limits {
+		result, err := s.limiter.Allow(ctx, l.key, l.limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !result.Allowed {
+			c.Error(apperr.TooMany(result.RetryAfter, "too many password reset requests, try again later"))
+			return
+		}
+	}
+	user, err := s.store.Users().GetByUsername(ctx, req.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.Error(err)
+		return
+	}
+	if err == nil {
+		switch {
+		case s.mailer == nil:
+			slog.WarnContext(ctx, "password reset requested without smtp configured", slog.Uint64("user_id", uint64(user.ID)))
+		case user.Email == nil || *user.Email == "":
+			slog.WarnContext(ctx, "password reset requested for a user without email", slog.Uint64("user_id", uint64(user.ID)))
+		default:
+			resetToken, err := createResetToken(ctx, s.store, user.ID, s.config.Password.ResetTokenTTL)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			s.sendResetEmail(ctx, user, resetToken)
+		}
+	}
+	c.JSON(http.StatusAccepted, forgotPasswordResponse{})
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,max=72"`
+}
+
+type resetPasswordResponse struct {
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Sets a new password with the token of a reset email. The token works once and expires;
+// @Description the user's access tokens are revoked and a lockout after failed logins is lifted.
+// @Param reset body resetPasswordRequest true "Reset"
+// @Produce application/json
+// @Tags user
+// @Success 200 {object} resetPasswordResponse{}
+// @Failure 422 {object} ErrorResponse
+// @Router /password/reset [post]
+func (s *Server) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	// a weak password must not use up the token
+	if err := s.checkPassword("new_password", req.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	var user models.User
+	err := s.store.Transaction(ctx, func(tx store.Store) error {
+		now := time.Now()
+		resetToken, err := tx.PasswordResets().Use(ctx, hashResetToken(req.Token), now)
+		if errors.Is(err, store.ErrNotFound) {
+			return apperr.Invalid("token", "reset token is invalid, used or expired")
+		}
+		if err != nil {
+			return err
+		}
+		user, err = tx.Users().Get(ctx, resetToken.UserID)
+		if err != nil {
+			return err
+		}
+		return setPassword(ctx, tx, &user, req.NewPassword, now)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.lockout.Unlock(ctx, user.Username); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resetPasswordResponse{})
+}
+
+// purgePasswordResets drops the expired reset tokens
+func (s *Server) purgePasswordResets(ctx context.Context) error {
+	purged, err := s.store.PasswordResets().DeleteExpired(ctx, time.Now())
+	if err == nil && purged > 0 {
+		slog.InfoContext(ctx, "purged password reset tokens", slog.Int64("count", purged))
+	}
+	return err
+}
@@ -0,0 +1,307 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/config"
+)
+
+// resetURL is where the reset links of the emails point to
+const resetURL = "https://fleet.example.com/reset"
+
+func withResetURL(cfg *config.Config) {
+	cfg.Password.ResetURL = resetURL
+}
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// fakeMailer hands the emails to the test, they are sent from another goroutine
+type fakeMailer chan sentMail
+
+func (m fakeMailer) Send(to, subject, body string) error {
+	m <- sentMail{to: to, subject: subject, body: body}
+	return nil
+}
+
+// withMailer makes the server send emails to the returned mailer
+func withMailer(h *apitest.Harness) fakeMailer {
+	mailer := make(fakeMailer, 10)
+	api.SetMailer(h.Server, mailer)
+	return mailer
+}
+
+// resetToken waits for an email and returns the token of its reset link
+func (m fakeMailer) resetToken(t *testing.T, to string) string {
+	t.Helper()
+	select {
+	case mail := <-m:
+		if mail.to != to {
+			t.Fatalf("email sent to %s, want %s", mail.to, to)
+		}
+		start := strings.Index(mail.body, resetURL+"?")
+		if start < 0 {
+			t.Fatalf("email has no reset link: %s", mail.body)
+		}
+		link, err := url.Parse(strings.Fields(mail.body[start:])[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link.Query().Get("token")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email sent")
+	}
+	return ""
+}
+
+// loginStatus logs in through POST /login and returns the status
+func loginStatus(h *apitest.Harness, username, password string) int {
+	return h.Anonymous().Post("/login", api.LoginRequest{Username: username, Password: password}, nil)
+}
+
+func TestChangePassword(t *testing.T) {
+	h := apitest.New(t)
+	driver := h.As(apitest.RoleDriver)
+
+	for _, req := range []api.ChangePasswordRequest{
+		{OldPassword: "wrong", NewPassword: "Str0ngPassword"},
+		{OldPassword: apitest.Password, NewPassword: "weak"},
+		{OldPassword: apitest.Password, NewPassword: apitest.Password},
+	} {
+		if code := driver.Post("/user/me/password", req, nil); code != http.StatusUnprocessableEntity {
+			t.Errorf("change %+v status = %d, want 422", req, code)
+		}
+	}
+
+	var response api.ChangePasswordResponse
+	req := api.ChangePasswordRequest{OldPassword: apitest.Password, NewPassword: "Str0ngPassword"}
+	if code := driver.Post("/user/me/password", req, &response); code != http.StatusOK {
+		t.Fatalf("change status = %d, want 200", code)
+	}
+	if code := driver.Get("/vehicle", nil); code != http.StatusUnauthorized {
+		t.Errorf("token from before the change status = %d, want 401", code)
+	}
+	if code := h.WithToken(response.AccessToken).Get("/vehicle", nil); code != http.StatusOK {
+		t.Errorf("token from the change status = %d, want 200", code)
+	}
+	if code := loginStatus(h, "driver", apitest.Password); code != http.StatusUnauthorized {
+		t.Errorf("login with the old password status = %d, want 401", code)
+	}
+	if code := loginStatus(h, "driver", "Str0ngPassword"); code != http.StatusOK {
+		t.Errorf("login with the new password status = %d, want 200", code)
+	}
+}
+
+func TestCreateUserChecksPasswordPolicy(t *testing.T) {
+	h := apitest.New(t, func(cfg *config.Config) {
+		cfg.Password.RequireSymbol = true
+	})
+	name, role := "Dana", apitest.RoleDriver
+	req := api.CreateUserRequest{Username: "dana", Role: &role, FirstName: &name, LastName: &name}
+
+	for password, want := range map[string]int{
+		"Str0ngPassword":  http.StatusUnprocessableEntity,
+		"Str0ngPassword!": http.StatusOK,
+	} {
+		req.Password = &password
+		if code := h.Anonymous().Post("/user", req, nil); code != want {
+			t.Errorf("create with password %q status = %d, want %d", password, code, want)
+		}
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	h := apitest.New(t, withResetURL)
+	mailer := withMailer(h)
+	driver := h.As(apitest.RoleDriver)
+
+	if code := h.Anonymous().Post("/password/forgot", api.ForgotPasswordRequest{Username: "nobody"}, nil); code != http.StatusAccepted {
+		t.Errorf("forgot for unknown user status = %d, want 202", code)
+	}
+	if code := h.Anonymous().Post("/password/forgot", api.ForgotPasswordRequest{Username: "driver"}, nil); code != http.StatusAccepted {
+		t.Fatalf("forgot status = %d, want 202", code)
+	}
+	resetToken := mailer.resetToken(t, "driver@example.com")
+
+	reset := func(token, password string) int {
+		return h.Anonymous().Post("/password/reset", api.ResetPasswordRequest{Token: token, NewPassword: password}, nil)
+	}
+	if code := reset("made-up", "Str0ngPassword"); code != http.StatusUnprocessableEntity {
+		t.Errorf("reset with made up token status = %d, want 422", code)
+	}
+	// a weak password leaves the token usable
+	if code := reset(resetToken, "weak"); code != http.StatusUnprocessableEntity {
+		t.Errorf("reset with weak password status = %d, want 422", code)
+	}
+	if code := reset(resetToken, "Str0ngPassword"); code != http.StatusOK {
+		t.Fatalf("reset status = %d, want 200", code)
+	}
+	if code := reset(resetToken, "0therPassword"); code != http.StatusUnprocessableEntity {
+		t.Errorf("second reset with the token status = %d, want 422", code)
+	}
+
+	if code := driver.Get("/vehicle", nil); code != http.StatusUnauthorized {
+		t.Errorf("token from before the reset status = %d, want 401", code)
+	}
+	if code := loginStatus(h, "driver", "Str0ngPassword"); code != http.StatusOK {
+		t.Errorf("login with the new password status = %d, want 200", code)
+	}
+}
+
+func TestResetTokenExpires(t *testing.T) {
+	h := apitest.New(t, withResetURL, func(cfg *config.Config) {
+		cfg.Password.ResetTokenTTL = -time.Minute
+	})
+	mailer := withMailer(h)
+
+	h.Anonymous().Post("/password/forgot", api.ForgotPasswordRequest{Username: "driver"}, nil)
+	resetToken := mailer.resetToken(t, "driver@example.com")
+	if code := h.Anonymous().Post("/password/reset", api.ResetPasswordRequest{Token: resetToken, NewPassword: "Str0ngPassword"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("reset with expired token status = %d, want 422", code)
+	}
+}
+
+func TestAdminResetsPassword(t *testing.T) {
+	h := apitest.New(t, withResetURL)
+	admin := h.As(apitest.RoleAdmin)
+	noEmail := h.AddUser("mechanic", apitest.RoleMaintenancePerson)
+	if err := h.DB.Model(&noEmail).Update("email", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := apitest.Path("/user/:id/password/reset", h.User(apitest.RoleDriver).ID)
+	if code := admin.Post(path, nil, nil); code != http.StatusConflict {
+		t.Errorf("reset without smtp status = %d, want 409", code)
+	}
+	mailer := withMailer(h)
+	if code := h.As(apitest.RoleDriver).Post(path, nil, nil); code != http.StatusForbidden {
+		t.Errorf("reset by driver status = %d, want 403", code)
+	}
+	if code := admin.Post(apitest.Path("/user/:id/password/reset", noEmail.ID), nil, nil); code != http.StatusConflict {
+		t.Errorf("reset of user without email status = %d, want 409", code)
+	}
+	if code := admin.Post(path, nil, nil); code != http.StatusOK {
+		t.Fatalf("reset status = %d, want 200", code)
+	}
+	resetToken := mailer.resetToken(t, "driver@example.com")
+
+	if code := loginStatus(h, "driver", apitest.Password); code != http.StatusUnauthorized {
+		t.Errorf("login with the old password status = %d, want 401", code)
+	}
+	if code := h.Anonymous().Post("/password/reset", api.ResetPasswordRequest{Token: resetToken, NewPassword: "Str0ngPassword"}, nil); code != http.StatusOK {
+		t.Fatalf("reset with the emailed token status = %d, want 200", code)
+	}
+	if code := loginStatus(h, "driver", "Str0ngPassword"); code != http.StatusOK {
+		t.Errorf("login with the new password status = %d, want 200", code)
+	}
+}
+
+// failingMailer cannot reach the mail server
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("connection refused")
+}
+
+func TestAdminResetKeepsPasswordWhenEmailFails(t *testing.T) {
+	// the memory store does not roll transactions back
+	h := apitest.New(t, withResetURL)
+	api.SetMailer(h.Server, failingMailer{})
+	driver := h.As(apitest.RoleDriver)
+
+	path := apitest.Path("/user/:id/password/reset", h.User(apitest.RoleDriver).ID)
+	if code := h.As(apitest.RoleAdmin).Post(path, nil, nil); code != http.StatusBadGateway {
+		t.Fatalf("reset status = %d, want 502", code)
+	}
+	if code := loginStatus(h, "driver", apitest.Password); code != http.StatusOK {
+		t.Errorf("login with the old password status = %d, want 200", code)
+	}
+	if code := driver.Get("/vehicle", nil); code != http.StatusOK {
+		t.Errorf("token from before the failed reset status = %d, want 200", code)
+	}
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	h := apitest.New(t, withResetURL)
+	mailer := withMailer(h)
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	h.Anonymous().Post("/password/forgot", api.ForgotPasswordRequest{Username: "driver"}, nil)
+	resetToken := mailer.resetToken(t, "driver@example.com")
+
+	name, phone := "Dana", "+77001234567"
+	req := api.UpdateUserRequest{Username: "driver", Role: h.User(apitest.RoleDriver).Role, FirstName: &name, LastName: &name, PhoneNumber: &phone}
+	path := apitest.Path("/user/:id", h.User(apitest.RoleDriver).ID)
+	if code := admin.Put(path, req, nil); code != http.StatusOK {
+		t.Fatalf("update without password status = %d, want 200", code)
+	}
+	if code := driver.Get("/vehicle", nil); code != http.StatusOK {
+		t.Errorf("token after an update without password status = %d, want 200", code)
+	}
+
+	password := "weak"
+	req.Password = &password
+	if code := admin.Put(path, req, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("update with weak password status = %d, want 422", code)
+	}
+	password = "Str0ngPassword"
+	if code := admin.Put(path, req, nil); code != http.StatusOK {
+		t.Fatalf("update with password status = %d, want 200", code)
+	}
+	if code := driver.Get("/vehicle", nil); code != http.StatusUnauthorized {
+		t.Errorf("token from before the new password status = %d, want 401", code)
+	}
+	if code := h.Anonymous().Post("/password/reset", api.ResetPasswordRequest{Token: resetToken, NewPassword: "0therPassword"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("reset with a token from before the new password status = %d, want 422", code)
+	}
+	if code := loginStatus(h, "driver", "Str0ngPassword"); code != http.StatusOK {
+		t.Errorf("login with the new password status = %d, want 200", code)
+	}
+}
+
+// blockingMailer holds every email until release is closed
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan string
+}
+
+func (m blockingMailer) Send(to, subject, body string) error {
+	<-m.release
+	m.sent <- to
+	return nil
+}
+
+func TestShutdownWaitsForResetEmails(t *testing.T) {
+	h := apitest.New(t)
+	mailer := blockingMailer{release: make(chan struct{}), sent: make(chan string, 1)}
+	api.SetMailer(h.Server, mailer)
+	if code := h.Anonymous().Post("/password/forgot", api.ForgotPasswordRequest{Username: "driver"}, nil); code != http.StatusAccepted {
+		t.Fatalf("forgot status = %d, want 202", code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown with the email pending = %v, want the deadline", err)
+	}
+	close(mailer.release)
+	if err := api.WaitForEmails(h.Server, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case to := <-mailer.sent:
+		if to != "driver@example.com" {
+			t.Errorf("email sent to %s", to)
+		}
+	default:
+		t.Error("waiting for emails returned before the email was sent")
+	}
+}
@@ -1,44 +1,17 @@
 package api
 
 import (
-	"errors"
-	"time"
-
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
 	"github.com/rassulmagauin/VMS_SWE/models"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
+// Report is built from response types only, so it shares their redaction rules
 type Report struct {
-	Vehicle            models.Vehicle             `json:"vehicle"`
-	FuelingRecords     []models.FuelingRecord     `json:"fueling_records"`
-	MaintenanceRecords []models.MaintenanceRecord `json:"maintenance_records"`
-}
-type ReportForSwagger struct {
-	Vehicle            createVehicleResponse `json:"vehicle"`
-	FuelingRecords     []myFuelingRecord     `json:"fueling_records"`
-	MaintenanceRecords []myMaintenanceRecord `json:"maintenance_records"`
-}
-type myFuelingRecord struct {
-	VehicleID          *uint    `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
-	FuelingPersonID    *uint    `gorm:"not null" json:"fueling_person_id"`
-	Amount             *float64 `gorm:"not null" json:"amount"`
-	TotalCost          *float64 `gorm:"not null" json:"total_cost"`
-	GasStation         *string  `json:"gas_station"`
-	Notes              *string  `json:"notes"`
-	BeforeFuelingImage *string  `gorm:"not null" json:"before_fueling_image"`
-	AfterFuelingImage  *string  `gorm:"not null" json:"after_fueling_image"`
-}
-type myMaintenanceRecord struct {
-	VehicleID           *uint      `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
-	MaintenancePersonID *uint      `gorm:"not null" json:"maintenance_person_id"`
-	MaintenanceDate     *time.Time `json:"maintenance_date"`
-	ServiceType         *string    `json:"service_type"`
-	Status              *string    `gorm:"not null" json:"status"`
-	//Parts               []Part             `gorm:"foreignKey:ID" json:"parts"`
-	TotalCost        *float64 `gorm:"not null" json:"total_cost"`
-	MileageAtService *int     `json:"mileage_at_service"`
-	Notes            *string  `json:"notes"`
+	Vehicle            vehicleResponse             `json:"vehicle"`
+	FuelingRecords     []FuelingRecordResponse     `json:"fueling_records"`
+	MaintenanceRecords []maintenanceRecordResponse `json:"maintenance_records"`
 }
 
 // this handler returns all fueiling record, maintenance records for a vehicle
@@ -49,33 +22,99 @@ type myMaintenanceRecord struct {
 // @Accept  json
 // @Produce  json
 // @Param vehicle_id path int true "Vehicle ID"
-// @Success 200 {object} ReportForSwagger{}
+// @Success 200 {object} Report{}
 // @Router /report/{vehicle_id} [get]
 // @Security ApiKeyAuth
 func (s *Server) GetReport(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can get reports")))
+		c.Error(apperr.Forbidden("only admins can get reports"))
 		return
 	}
-	var report Report
-	var vehicle models.Vehicle
-	if err := s.DB.First(&vehicle, c.Param("vehicle_id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	ctx := c.Request.Context()
+	vehicleID := idParam(c, "vehicle_id")
+	vehicle, err := s.store.Vehicles().Get(ctx, vehicleID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	report.Vehicle = vehicle
-	var fueling []models.FuelingRecord
-	if err := s.DB.Where("vehicle_id = ?", c.Param("vehicle_id")).Find(&fueling).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	fueling, err := s.store.Fuelings().ListByVehicle(ctx, vehicleID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	report.FuelingRecords = fueling
-	var maintenance []models.MaintenanceRecord
-	if err := s.DB.Where("vehicle_id = ?", c.Param("vehicle_id")).Find(&maintenance).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	maintenance, err := s.store.Maintenance().ListByVehicle(ctx, vehicleID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	report.MaintenanceRecords = maintenance
+	report := Report{
+		Vehicle:            newVehicleResponse(vehicle),
+		FuelingRecords:     newFuelingRecordResponses(fueling),
+		MaintenanceRecords: newMaintenanceRecordResponses(maintenance),
+	}
+	c.JSON(200, report)
+}
+
+type taskDistanceReport struct {
+	TaskID            uint     `json:"task_id"`
+	Status            *string  `json:"status"`
+	EstimatedDistance *float64 `json:"estimated_distance"`
+	RoadDistance      *float64 `json:"road_distance"`
+	ActualDistance    float64  `json:"actual_distance"`
+	// Difference is the actual distance minus the road distance, or the great-circle estimate without one
+	Difference *float64 `json:"difference"`
+}
+
+// GetDistanceReport godoc
+// @Summary Get estimated vs actual distances
+// @Description Compares the estimated distance of each task of a vehicle with the distance it was tracked driving, recorded when the task is completed
+// @Tags report
+// @Produce  json
+// @Param vehicle_id path int true "Vehicle ID"
+// @Success 200 {array} []taskDistanceReport{}
+// @Router /report/{vehicle_id}/distance [get]
+// @Security ApiKeyAuth
+func (s *Server) GetDistanceReport(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get reports"))
+		return
+	}
+	var tasks []models.Task
+	if err := s.DB.WithContext(c.Request.Context()).Where("vehicle_id = ?", c.Param("vehicle_id")).Order("id").Find(&tasks).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	var usages []models.VehicleUsage
+	if err := s.DB.WithContext(c.Request.Context()).Where("vehicle_id = ? AND task_id IS NOT NULL", c.Param("vehicle_id")).Find(&usages).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	actual := make(map[uint]float64)
+	for _, usage := range usages {
+		if usage.Distance != nil {
+			actual[*usage.TaskID] += *usage.Distance
+		}
+	}
+
+	report := make([]taskDistanceReport, len(tasks))
+	for i, task := range tasks {
+		report[i] = taskDistanceReport{
+			TaskID:            task.ID,
+			Status:            task.Status,
+			EstimatedDistance: task.EstimatedDistance,
+			RoadDistance:      task.RoadDistance,
+			ActualDistance:    actual[task.ID],
+		}
+		expected := task.RoadDistance
+		if expected == nil {
+			expected = task.EstimatedDistance
+		}
+		if expected != nil {
+			difference := actual[task.ID] - *expected
+			report[i].Difference = &difference
+		}
+	}
 	c.JSON(200, report)
 }
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"gorm.io/gorm"
+)
+
+// responseTypes lists every type a handler writes as a response body
+var responseTypes = []interface{}{
+	AuctionVehicleResponse{},
+	vehicleDocumentResponse{},
+	alertResponse{},
+	ErrorResponse{},
+	FuelingRecordResponse{},
+	geofenceResponse{},
+	geofenceEventResponse{},
+	incidentResponse{},
+	inspectionTemplateResponse{},
+	inspectionResponse{},
+	jobResponse{},
+	jobRunResponse{},
+	driverLicenseResponse{},
+	driverQualificationResponse{},
+	maintenanceRecordResponse{},
+	notificationResponse{},
+	Report{},
+	taskDistanceReport{},
+	taskResponse{},
+	trackingPingResponse{},
+	vehicleTrackResponse{},
+	fleetPositionResponse{},
+	userResponse{},
+	deleteUserResponse{},
+	loginResponse{},
+	vehicleResponse{},
+	webhookEndpointResponse{},
+	webhookDeliveryResponse{},
+}
+
+var sensitiveKeys = []string{"hashed_password", "HashedPassword", "password", "DeletedAt", "deleted_at"}
+
+var gormModelType = reflect.TypeOf(gorm.Model{})
+
+// walkFields calls fn for every field reachable from t, following pointers, slices and nested structs
+func walkFields(t reflect.Type, path string, seen map[reflect.Type]bool, fn func(path string, field reflect.StructField)) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldPath := path + "." + field.Name
+		fn(fieldPath, field)
+		walkFields(field.Type, fieldPath, seen, fn)
+	}
+}
+
+func TestResponsesDoNotEmbedModels(t *testing.T) {
+	for _, response := range responseTypes {
+		responseType := reflect.TypeOf(response)
+		walkFields(responseType, responseType.Name(), map[reflect.Type]bool{}, func(path string, field reflect.StructField) {
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType == gormModelType || fieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+				t.Errorf("%s exposes GORM internals", path)
+			}
+			if fieldType.Kind() == reflect.Struct {
+				if model, ok := fieldType.FieldByName("Model"); ok && model.Type == gormModelType {
+					t.Errorf("%s is the database model %s, use a response type", path, fieldType.Name())
+				}
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			for _, key := range sensitiveKeys {
+				if name == key && field.Tag.Get("json") != "-" {
+					t.Errorf("%s is serialized as the sensitive key %q", path, name)
+				}
+			}
+		})
+	}
+}
+
+func testUser() models.User {
+	hash, governmentID, address, license, role := "secret-hash", "900101300123", "Kabanbay Batyr 53", "AB123456", "Driver"
+	user := models.User{
+		ID:                   7,
+		Username:             "driver",
+		HashedPassword:       &hash,
+		GovermentID:          &governmentID,
+		Address:              &address,
+		DrivingLicenseNumber: &license,
+		Role:                 &role,
+	}
+	user.ID = 7
+	return user
+}
+
+func TestResponsesDoNotLeakPasswordHash(t *testing.T) {
+	user := testUser()
+	vehicle := models.Vehicle{AssignedDriver: &user.ID, Driver: &user}
+	task := models.Task{DriverID: &user.ID, Driver: &user}
+
+	bodies := map[string]interface{}{
+		"user model":   user,
+		"vehicle":      newVehicleResponse(vehicle),
+		"task":         newTaskResponse(task),
+		"user":         newUserResponse(user, true),
+		"login":        loginResponse{AccessToken: "token", User: newUserResponse(user, true)},
+		"report":       Report{Vehicle: newVehicleResponse(vehicle)},
+		"vehicle list": newVehicleResponses([]models.Vehicle{vehicle}),
+		"task list":    newTaskResponses([]models.Task{task}),
+	}
+	for name, body := range bodies {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, leak := range []string{"secret-hash", "hashed_password", "HashedPassword"} {
+			if strings.Contains(string(data), leak) {
+				t.Errorf("%s response contains %q: %s", name, leak, data)
+			}
+		}
+	}
+}
+
+func TestUserResponseRedactsPersonalData(t *testing.T) {
+	user := testUser()
+	tests := []struct {
+		name   string
+		viewer *token.Payload
+		show   bool
+	}{
+		{"admin", &token.Payload{UserID: 1, Username: "admin", Role: "Admin"}, true},
+		{"the user", &token.Payload{UserID: 7, Username: "driver", Role: "Driver"}, true},
+		{"another driver", &token.Payload{UserID: 8, Username: "other", Role: "Driver"}, false},
+		{"fueling person", &token.Payload{UserID: 9, Username: "fueler", Role: "Fueling"}, false},
+		{"anonymous", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canSeePersonalData(tt.viewer, user); got != tt.show {
+				t.Fatalf("canSeePersonalData = %v, want %v", got, tt.show)
+			}
+			data, err := json.Marshal(newUserResponse(user, tt.show))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"goverment_id", "address", "driving_license_number"} {
+				if visible := body[key] != nil; visible != tt.show {
+					t.Errorf("%s visible = %v, want %v", key, visible, tt.show)
+				}
+			}
+			if body["username"] != "driver" {
+				t.Errorf("username = %v, want driver", body["username"])
+			}
+		})
+	}
+}
@@ -1,14 +1,26 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"time"
+	"log/slog"
+	"net/http"
+	"sync"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/config"
 	_ "github.com/rassulmagauin/VMS_SWE/docs"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/notify"
+	"github.com/rassulmagauin/VMS_SWE/outbox"
+	"github.com/rassulmagauin/VMS_SWE/ratelimit"
+	"github.com/rassulmagauin/VMS_SWE/routing"
+	"github.com/rassulmagauin/VMS_SWE/scheduler"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
+	"github.com/rassulmagauin/VMS_SWE/tracing"
+	"github.com/rassulmagauin/VMS_SWE/webhook"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/gorm"
@@ -16,52 +28,161 @@ import (
 
 type Server struct {
 	Router     *gin.Engine
+	config     config.Config
 	tokenMaker token.Maker
 	DB         *gorm.DB
+	store      store.Store
+	events     *events.Hub
+	outbox     *outbox.Dispatcher
+	scheduler  *scheduler.Scheduler
+	notifier   *notify.Service
+	mailer     notify.Mailer
+	// emails tracks the emails sent outside of a request, Shutdown waits for them
+	emails        sync.WaitGroup
+	webhooks      *webhook.Sender
+	routeEngine   routing.Engine
+	averageSpeeds routing.Speeds
+	metrics       *metrics
+	rateLimits    ratelimit.Backend
+	limiter       *ratelimit.Limiter
+	lockout       *ratelimit.Lockout
 }
 
-const (
-	tokenSymmetricKey   = "12345678901234567890123456789012"
-	AccessTokenDuration = 45 * time.Minute
-)
+// NewServer creates the server from a validated configuration, see config.Load
+func NewServer(cfg config.Config, DB *gorm.DB) (*Server, error) {
+	return NewServerWithStore(cfg, DB, store.NewGorm(DB))
+}
 
-func NewServer(DB *gorm.DB) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(tokenSymmetricKey)
+// NewServerWithStore creates the server with the store used by the handlers ported to the store package.
+// Handler tests of users, vehicles, tasks and licenses pass store.NewMemory() and a nil DB, see apitest.NewMemory.
+func NewServerWithStore(cfg config.Config, DB *gorm.DB, st store.Store) (*Server, error) {
+	tokenMaker, err := token.NewPasetoMaker(cfg.Token.SymmetricKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
-	server := &Server{DB: DB, tokenMaker: tokenMaker}
+	averageSpeeds, err := routing.ParseSpeeds(cfg.Routing.AverageSpeeds)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse routing average speeds: %w", err)
+	}
+	server := &Server{
+		DB:            DB,
+		store:         st,
+		config:        cfg,
+		tokenMaker:    tokenMaker,
+		events:        events.NewHub(),
+		webhooks:      webhook.NewSender(),
+		averageSpeeds: averageSpeeds,
+	}
+	server.metrics, err = newMetrics(DB, st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create metrics: %w", err)
+	}
+	switch cfg.RateLimit.Backend {
+	case config.RateLimitDatabase:
+		server.rateLimits = ratelimit.NewGorm(DB)
+	default:
+		server.rateLimits = ratelimit.NewMemory()
+	}
+	server.limiter = ratelimit.NewLimiter(server.rateLimits)
+	server.lockout = ratelimit.NewLockout(server.rateLimits, ratelimit.LockoutPolicy{
+		MaxFailures:   cfg.RateLimit.LockoutFailures,
+		FailureWindow: cfg.RateLimit.LockoutWindow,
+		Duration:      cfg.RateLimit.LockoutDuration,
+		MaxDuration:   cfg.RateLimit.LockoutMaxDuration,
+	})
+	// without an SMTP server notifications only go to the in-app inbox
+	var mailer notify.Mailer
+	if cfg.SMTP.Addr != "" {
+		mailer = notify.NewSMTPMailer(cfg.SMTP.Addr, cfg.SMTP.From, cfg.SMTP.Username, cfg.SMTP.Password)
+	}
+	server.mailer = mailer
+	server.notifier = notify.NewService(DB, mailer)
+	server.outbox = outbox.NewDispatcher(DB,
+		outbox.Subscriber{Name: "stream", Handle: server.streamEvent},
+		outbox.Subscriber{Name: "webhooks", Handle: server.queueWebhookDeliveries},
+		outbox.Subscriber{Name: "notifications", Handle: server.notifyForEvent},
+		outbox.Subscriber{Name: "usage", Handle: server.recordTaskUsage},
+	)
+	server.scheduler = scheduler.New(DB)
+	if err := server.registerJobs(); err != nil {
+		return nil, fmt.Errorf("cannot register jobs: %w", err)
+	}
+	// road distance is optional, great-circle estimates are used without it
+	if cfg.Routing.EngineURL != "" {
+		server.routeEngine = routing.NewOSRMEngine(cfg.Routing.EngineURL)
+	}
+	registerValidators()
 	server.setupRouter()
+	// rate limits go by client IP, which only trusted proxies may set through X-Forwarded-For
+	if err := server.Router.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("http trusted_proxies: %w", err)
+	}
 	return server, nil
 }
 
-func (s *Server) Run(addr string) error {
-	return s.Router.Run(addr)
+// HTTPServer returns the http.Server serving the API on addr. Event streams are ended
+// when it shuts down, so they do not hold up draining the other requests.
+func (s *Server) HTTPServer(addr string) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.Router,
+		ReadHeaderTimeout: s.config.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       s.config.HTTP.ReadTimeout,
+		WriteTimeout:      s.config.HTTP.WriteTimeout,
+		IdleTimeout:       s.config.HTTP.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	}
+	server.RegisterOnShutdown(s.events.Close)
+	return server
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+// Start starts the background work of the server: the job scheduler and the outbox dispatcher
+func (s *Server) Start() error {
+	if err := s.scheduler.Start(); err != nil {
+		return fmt.Errorf("cannot start background jobs: %w", err)
+	}
+	s.outbox.Start(outboxDispatchInterval)
+	return nil
 }
 
-func (server *Server) setupRouter() {
-	router := gin.Default()
-
-	router.Use(cors.New(cors.Config{
-		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	router.Static("/static", "./uploads")
-	swaggerHost := os.Getenv("HOST")
-	if swaggerHost == "" {
-		swaggerHost = "localhost:8080" // Default value for local development
+// Shutdown stops the background work, waiting for running jobs until ctx is done.
+// Call it after the http.Server is shut down so requests still see their events published.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return errors.Join(s.scheduler.Stop(ctx), s.outbox.Stop(ctx), s.waitForEmails(ctx))
+}
+
+// waitForEmails waits until the emails being sent are out or ctx is done
+func (s *Server) waitForEmails(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.emails.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	swaggerURL := fmt.Sprintf("https://%s/docs/doc.json", swaggerHost)
+}
+
+func (server *Server) setupRouter() {
+	router := gin.New()
+	router.Use(requestIDMiddleware(), tracing.Middleware(), accessLogMiddleware(), server.metrics.middleware(), recoveryMiddleware())
+	router.Use(errorMiddleware(), idParamsMiddleware())
+	router.MaxMultipartMemory = server.config.Uploads.MaxRequestSize
+
+	router.Use(corsMiddleware(server.config.CORS))
+	router.Use(maxBodySize(server.config.Uploads.MaxRequestSize))
+	router.Static("/static", server.config.Storage.Dir)
+	swaggerURL := fmt.Sprintf("https://%s/docs/doc.json", server.config.HTTP.PublicHost)
 	router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(swaggerURL)))
+	router.GET("/metrics", server.metrics.handler())
+	router.GET("/healthz", server.Healthz)
+	router.GET("/readyz", server.Readyz)
 
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	requestLimit := ratelimit.Limit{Requests: server.config.RateLimit.RequestsPerToken, Window: server.config.RateLimit.RequestWindow}
+	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker, server.store), rateLimitMiddleware(server.limiter, requestLimit))
 	authRoutes.POST("/vehicle", server.CreateVehicle)
 	authRoutes.GET("/vehicle", server.GetVehicles)
 	authRoutes.GET("/vehicle/:id", server.GetVehicle)
@@ -69,12 +190,30 @@ func (server *Server) setupRouter() {
 	authRoutes.DELETE("/vehicle/:id", server.DeleteVehicle)
 	authRoutes.POST("/vehicle/:id", server.ActivateVehicle)
 	authRoutes.POST("/vehicle/register", server.RegisterVehicle)
+	authRoutes.GET("/vehicle/:id/track", server.GetVehicleTrack)
+	authRoutes.POST("/vehicle/:id/documents", server.CreateVehicleDocument)
+	authRoutes.GET("/vehicle/:id/documents", server.GetVehicleDocuments)
+
+	authRoutes.GET("/documents/expiring", server.GetExpiringDocuments)
+	authRoutes.PUT("/documents/:id", server.UpdateVehicleDocument)
+	authRoutes.DELETE("/documents/:id", server.DeleteVehicleDocument)
+
+	authRoutes.GET("/alerts", server.GetAlerts)
+	authRoutes.POST("/alerts/:id/resolve", server.ResolveAlert)
 
 	router.POST("/user", server.CreateUser)
 	authRoutes.GET("/user", server.GetUsers)
 	authRoutes.GET("/user/:id", server.GetUser)
 	authRoutes.PUT("/user/:id", server.UpdateUser)
 	authRoutes.DELETE("/user/:id", server.DeleteUser)
+	authRoutes.POST("/user/:id/unlock", server.UnlockUser)
+	authRoutes.POST("/user/:id/password/reset", server.ResetUserPassword)
+	authRoutes.POST("/user/me/password", server.ChangePassword)
+	authRoutes.PUT("/user/:id/license", server.SaveDriverLicense)
+	authRoutes.GET("/user/:id/license", server.GetDriverLicense)
+	authRoutes.POST("/user/:id/qualifications", server.CreateDriverQualification)
+	authRoutes.GET("/user/:id/qualifications", server.GetDriverQualifications)
+	authRoutes.DELETE("/qualifications/:id", server.DeleteDriverQualification)
 
 	authRoutes.POST("/maintenance", server.CreateMaintenanceRecord)
 	authRoutes.GET("/maintenance", server.GetMaintenanceRecords)
@@ -91,6 +230,21 @@ func (server *Server) setupRouter() {
 	authRoutes.GET("/fuelings/:vehicle_id", server.GetFuelingRecordsOfVehicle)
 	authRoutes.GET("/fueling/user/:user_id", server.GetFuelingRecordsOfUser)
 
+	authRoutes.POST("/inspection/template", server.CreateInspectionTemplate)
+	authRoutes.GET("/inspection/template", server.GetInspectionTemplates)
+	authRoutes.GET("/inspection/template/:id", server.GetInspectionTemplate)
+	authRoutes.DELETE("/inspection/template/:id", server.DeleteInspectionTemplate)
+	authRoutes.POST("/inspection", server.CreateInspection)
+	authRoutes.GET("/inspection", server.GetInspections)
+	authRoutes.GET("/inspection/:id", server.GetInspection)
+	authRoutes.GET("/inspections/:vehicle_id", server.GetInspectionsOfVehicle)
+
+	authRoutes.POST("/incident", server.CreateIncident)
+	authRoutes.GET("/incident", server.GetIncidents)
+	authRoutes.GET("/incident/:id", server.GetIncident)
+	authRoutes.PUT("/incident/:id/status", server.UpdateIncidentStatus)
+	authRoutes.GET("/incidents/:vehicle_id", server.GetIncidentsOfVehicle)
+
 	authRoutes.POST("/vehicle/assign", server.AssignVehicle)
 	authRoutes.POST("/vehicle/unassign", server.UnassignVehicle)
 
@@ -101,12 +255,46 @@ func (server *Server) setupRouter() {
 	authRoutes.DELETE("/task/:id", server.DeleteTask)
 
 	authRoutes.GET("/report/:vehicle_id", server.GetReport)
+	authRoutes.GET("/report/:vehicle_id/distance", server.GetDistanceReport)
 
 	authRoutes.POST("/auction", server.CreateAuction)
 	router.GET("/auction", server.GetAuctions)
 	router.GET("/auction/:id", server.GetAuction)
 	authRoutes.DELETE("/auction/:id", server.DeleteAuction)
+	authRoutes.POST("/auction/:id/close", server.CloseAuction)
+
+	authRoutes.POST("/tracking/ping", server.PostTrackingPing)
+	authRoutes.GET("/fleet/positions", server.GetFleetPositions)
+
+	authRoutes.POST("/geofence", server.CreateGeofence)
+	authRoutes.GET("/geofence", server.GetGeofences)
+	authRoutes.GET("/geofence/events", server.GetGeofenceEvents)
+	authRoutes.GET("/geofence/:id", server.GetGeofence)
+	authRoutes.PUT("/geofence/:id", server.UpdateGeofence)
+	authRoutes.DELETE("/geofence/:id", server.DeleteGeofence)
+
+	authRoutes.GET("/notifications", server.GetNotifications)
+	authRoutes.POST("/notifications/read", server.MarkAllNotificationsRead)
+	authRoutes.POST("/notifications/:id/read", server.MarkNotificationRead)
+	authRoutes.GET("/notifications/preferences", server.GetNotificationPreferences)
+	authRoutes.PUT("/notifications/preferences", server.UpdateNotificationPreferences)
+
+	authRoutes.POST("/webhooks", server.CreateWebhook)
+	authRoutes.GET("/webhooks", server.GetWebhooks)
+	authRoutes.GET("/webhooks/:id", server.GetWebhook)
+	authRoutes.PUT("/webhooks/:id", server.UpdateWebhook)
+	authRoutes.DELETE("/webhooks/:id", server.DeleteWebhook)
+	authRoutes.GET("/webhooks/:id/deliveries", server.GetWebhookDeliveries)
+	authRoutes.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", server.RedeliverWebhook)
+
+	authRoutes.GET("/jobs", server.GetJobs)
+	authRoutes.GET("/jobs/:name/runs", server.GetJobRuns)
+	authRoutes.POST("/jobs/:name/run", server.TriggerJob)
+
+	router.GET("/events", queryTokenMiddleware(), authMiddleware(server.tokenMaker, server.store), rateLimitMiddleware(server.limiter, requestLimit), server.StreamEvents)
 
 	router.POST("/login", server.LoginUser)
+	router.POST("/password/forgot", server.ForgotPassword)
+	router.POST("/password/reset", server.ResetPassword)
 	server.Router = router
 }
@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/models"
+)
+
+func addVehicle(t *testing.T, h *apitest.Harness, status string, driverID *uint) models.Vehicle {
+	t.Helper()
+	plate, vin := "123ABC01", "1HGCM82633A004352"
+	vehicle := models.Vehicle{LicensePlate: &plate, VIN: &vin, Status: &status, AssignedDriver: driverID}
+	if err := h.Store.Vehicles().Create(context.Background(), &vehicle); err != nil {
+		t.Fatal(err)
+	}
+	return vehicle
+}
+
+// login logs in as user, which must have the harness password
+func login(h *apitest.Harness, user models.User) *apitest.Client {
+	return h.WithToken(h.Login(user.Username, apitest.Password))
+}
+
+func TestLoginDoesNotRevealUsernames(t *testing.T) {
+	h := apitest.New(t)
+
+	var response api.LoginResponse
+	if code := h.Anonymous().Post("/login", api.LoginRequest{Username: "driver", Password: apitest.Password}, &response); code != http.StatusOK {
+		t.Fatalf("login status = %d, want 200", code)
+	}
+	if response.AccessToken == "" || response.User.Username != "driver" {
+		t.Errorf("login response = %+v", response)
+	}
+
+	wrongPassword := h.Anonymous().Post("/login", api.LoginRequest{Username: "driver", Password: "wrong"}, nil)
+	unknownUser := h.Anonymous().Post("/login", api.LoginRequest{Username: "nobody", Password: apitest.Password}, nil)
+	if wrongPassword != http.StatusUnauthorized || unknownUser != http.StatusUnauthorized {
+		t.Errorf("wrong password status = %d, unknown user status = %d, want 401 for both", wrongPassword, unknownUser)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	h := apitest.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/auction", nil)
+	req.Header.Set(api.RequestIDHeader, "client-id.1")
+	recorder := httptest.NewRecorder()
+	h.Server.Router.ServeHTTP(recorder, req)
+	if got := recorder.Header().Get(api.RequestIDHeader); got != "client-id.1" {
+		t.Errorf("request ID = %q, want the client's", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/auction", nil)
+	req.Header.Set(api.RequestIDHeader, "not valid\n")
+	recorder = httptest.NewRecorder()
+	h.Server.Router.ServeHTTP(recorder, req)
+	if got := recorder.Header().Get(api.RequestIDHeader); got == "" || got == "not valid\n" {
+		t.Errorf("request ID = %q, want a generated one", got)
+	}
+}
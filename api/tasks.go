@@ -1,37 +1,168 @@
 package api
 
 import (
-	"errors"
+	"context"
+	"log/slog"
+	"math"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/geo"
 	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/routing"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
+type taskWaypointRequest struct {
+	Sequence  int      `json:"sequence" binding:"gte=0"`
+	Latitude  *float64 `json:"latitude" binding:"required,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" binding:"required,gte=-180,lte=180"`
+}
+
+type taskWaypointResponse struct {
+	ID        uint       `json:"ID"`
+	Sequence  int        `json:"sequence"`
+	Latitude  *float64   `json:"latitude"`
+	Longitude *float64   `json:"longitude"`
+	ArrivedAt *time.Time `json:"arrived_at"`
+}
+
 type createTaskRequest struct {
-	DriverID       *uint      `gorm:"not null;onDelete:CASCADE" json:"driver_id"`
-	StartLatitude  *float64   `gorm:"not null" json:"start_latitude"`
-	StartLongitude *float64   `gorm:"not null" json:"start_longitude"`
-	EndLatitude    *float64   `gorm:"not null" json:"end_latitude"`
-	EndLongitude   *float64   `gorm:"not null" json:"end_longitude"`
-	StartTime      *time.Time `json:"start_time"`
-	EndTime        *time.Time `json:"end_time"`
-	Status         *string    `gorm:"not null" json:"status"`
-	Notes          *string    `json:"notes"`
-}
-
-type createTaskResponse struct {
-	ID             uint       `gorm:"not null" json:"ID"`
-	DriverID       *uint      `gorm:"not null;onDelete:CASCADE" json:"driver_id"`
-	StartLatitude  *float64   `gorm:"not null" json:"start_latitude"`
-	StartLongitude *float64   `gorm:"not null" json:"start_longitude"`
-	EndLatitude    *float64   `gorm:"not null" json:"end_latitude"`
-	EndLongitude   *float64   `gorm:"not null" json:"end_longitude"`
-	StartTime      *time.Time `json:"start_time"`
-	EndTime        *time.Time `json:"end_time"`
-	Status         *string    `gorm:"not null" json:"status"`
-	Notes          *string    `json:"notes"`
+	DriverID       *uint                 `json:"driver_id" binding:"required"`
+	VehicleID      *uint                 `json:"vehicle_id"`
+	StartLatitude  *float64              `json:"start_latitude" binding:"required,gte=-90,lte=90"`
+	StartLongitude *float64              `json:"start_longitude" binding:"required,gte=-180,lte=180"`
+	EndLatitude    *float64              `json:"end_latitude" binding:"required,gte=-90,lte=90"`
+	EndLongitude   *float64              `json:"end_longitude" binding:"required,gte=-180,lte=180"`
+	StartTime      *time.Time            `json:"start_time"`
+	EndTime        *time.Time            `json:"end_time"`
+	Status         *string               `json:"status" binding:"required,oneof=assigned in_progress delayed completed canceled"`
+	Notes          *string               `json:"notes" binding:"omitempty,max=1000"`
+	Waypoints      []taskWaypointRequest `json:"waypoints" binding:"max=25,dive"`
+}
+
+func (r createTaskRequest) validate() []apperr.FieldError {
+	return timeOrder("start_time", r.StartTime, "end_time", r.EndTime)
+}
+
+// toModel builds the task, route estimates are filled in by the server
+func (r createTaskRequest) toModel() models.Task {
+	return models.Task{
+		DriverID:       r.DriverID,
+		VehicleID:      r.VehicleID,
+		StartLatitude:  r.StartLatitude,
+		StartLongitude: r.StartLongitude,
+		EndLatitude:    r.EndLatitude,
+		EndLongitude:   r.EndLongitude,
+		StartTime:      r.StartTime,
+		EndTime:        r.EndTime,
+		Status:         r.Status,
+		Notes:          r.Notes,
+		Waypoints:      newTaskWaypoints(r.Waypoints),
+	}
+}
+
+// updateTaskRequest changes only the fields that are present, waypoints replace the whole route
+type updateTaskRequest struct {
+	DriverID       *uint                 `json:"driver_id"`
+	VehicleID      *uint                 `json:"vehicle_id"`
+	StartLatitude  *float64              `json:"start_latitude" binding:"omitempty,gte=-90,lte=90"`
+	StartLongitude *float64              `json:"start_longitude" binding:"omitempty,gte=-180,lte=180"`
+	EndLatitude    *float64              `json:"end_latitude" binding:"omitempty,gte=-90,lte=90"`
+	EndLongitude   *float64              `json:"end_longitude" binding:"omitempty,gte=-180,lte=180"`
+	StartTime      *time.Time            `json:"start_time"`
+	EndTime        *time.Time            `json:"end_time"`
+	Status         *string               `json:"status" binding:"omitempty,oneof=assigned in_progress delayed completed canceled"`
+	Notes          *string               `json:"notes" binding:"omitempty,max=1000"`
+	Waypoints      []taskWaypointRequest `json:"waypoints" binding:"max=25,dive"`
+}
+
+func (r updateTaskRequest) apply(task *models.Task) {
+	setIfPresent(&task.DriverID, r.DriverID)
+	setIfPresent(&task.VehicleID, r.VehicleID)
+	setIfPresent(&task.StartLatitude, r.StartLatitude)
+	setIfPresent(&task.StartLongitude, r.StartLongitude)
+	setIfPresent(&task.EndLatitude, r.EndLatitude)
+	setIfPresent(&task.EndLongitude, r.EndLongitude)
+	setIfPresent(&task.StartTime, r.StartTime)
+	setIfPresent(&task.EndTime, r.EndTime)
+	setIfPresent(&task.Status, r.Status)
+	setIfPresent(&task.Notes, r.Notes)
+	task.Waypoints = newTaskWaypoints(r.Waypoints)
+}
+
+func newTaskWaypoints(waypoints []taskWaypointRequest) []models.TaskWaypoint {
+	if len(waypoints) == 0 {
+		return nil
+	}
+	result := make([]models.TaskWaypoint, len(waypoints))
+	for i, waypoint := range waypoints {
+		result[i] = models.TaskWaypoint{
+			Sequence:  waypoint.Sequence,
+			Latitude:  waypoint.Latitude,
+			Longitude: waypoint.Longitude,
+		}
+	}
+	return result
+}
+
+type taskResponse struct {
+	ID                uint                   `json:"ID"`
+	DriverID          *uint                  `json:"driver_id"`
+	VehicleID         *uint                  `json:"vehicle_id"`
+	StartLatitude     *float64               `json:"start_latitude"`
+	StartLongitude    *float64               `json:"start_longitude"`
+	EndLatitude       *float64               `json:"end_latitude"`
+	EndLongitude      *float64               `json:"end_longitude"`
+	StartTime         *time.Time             `json:"start_time"`
+	EndTime           *time.Time             `json:"end_time"`
+	Status            *string                `json:"status"`
+	Notes             *string                `json:"notes"`
+	EstimatedDistance *float64               `json:"estimated_distance"`
+	RoadDistance      *float64               `json:"road_distance"`
+	EstimatedMinutes  *int                   `json:"estimated_minutes"`
+	Waypoints         []taskWaypointResponse `json:"waypoints"`
+}
+
+func newTaskResponse(task models.Task) taskResponse {
+	response := taskResponse{
+		ID:                task.ID,
+		DriverID:          task.DriverID,
+		VehicleID:         task.VehicleID,
+		StartLatitude:     task.StartLatitude,
+		StartLongitude:    task.StartLongitude,
+		EndLatitude:       task.EndLatitude,
+		EndLongitude:      task.EndLongitude,
+		StartTime:         task.StartTime,
+		EndTime:           task.EndTime,
+		Status:            task.Status,
+		Notes:             task.Notes,
+		EstimatedDistance: task.EstimatedDistance,
+		RoadDistance:      task.RoadDistance,
+		EstimatedMinutes:  task.EstimatedMinutes,
+		Waypoints:         make([]taskWaypointResponse, len(task.Waypoints)),
+	}
+	for i, waypoint := range task.Waypoints {
+		response.Waypoints[i] = taskWaypointResponse{
+			ID:        waypoint.ID,
+			Sequence:  waypoint.Sequence,
+			Latitude:  waypoint.Latitude,
+			Longitude: waypoint.Longitude,
+			ArrivedAt: waypoint.ArrivedAt,
+		}
+	}
+	return response
+}
+
+func newTaskResponses(tasks []models.Task) []taskResponse {
+	response := make([]taskResponse, len(tasks))
+	for i, task := range tasks {
+		response[i] = newTaskResponse(task)
+	}
+	return response
 }
 
 // CreateTask godoc
@@ -41,25 +172,44 @@ type createTaskResponse struct {
 // @Accept  json
 // @Produce  json
 // @Param task body createTaskRequest true "Task"
-// @Success 200 {object} createTaskResponse{}
+// @Success 200 {object} taskResponse{}
 // @Router /task [post]
 // @Security ApiKeyAuth
 func (s *Server) CreateTask(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can create tasks")))
+		c.Error(apperr.Forbidden("only admins can create tasks"))
 		return
 	}
-	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
-		c.JSON(400, errorResponse(err))
+	var req createTaskRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	if err := s.DB.Create(&task).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	ctx := c.Request.Context()
+	task := req.toModel()
+	if task.DriverID != nil {
+		if err := s.checkTaskDriver(ctx, &task); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	orderWaypoints(task.Waypoints)
+	s.estimateRoute(ctx, &task, task.Waypoints)
+	err := s.storeTransaction(ctx, func(tx store.Store) error {
+		if err := tx.Tasks().Create(ctx, &task); err != nil {
+			return err
+		}
+		if err := s.publishTaskStatus(ctx, tx, task, nil); err != nil {
+			return err
+		}
+		return s.publishTaskAssigned(ctx, tx, task)
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, task)
+	c.JSON(200, newTaskResponse(task))
 }
 
 // GetTasks godoc
@@ -68,33 +218,31 @@ func (s *Server) CreateTask(c *gin.Context) {
 // @Tags task
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} []createTaskResponse{}
+// @Success 200 {array} taskResponse{}
 // @Router /task [get]
 // @Security ApiKeyAuth
 func (s *Server) GetTasks(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		var user models.User
-		username := authPayload.Username
-		if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		userID := user.ID
-		var tasks []models.Task
-		if err := s.DB.Where("driver_id = ?", userID).Find(&tasks).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		tasks, err := s.store.Tasks().ListByDriver(c.Request.Context(), user.ID)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		c.JSON(200, tasks)
+		c.JSON(200, newTaskResponses(tasks))
 		return
 	}
-	var tasks []models.Task
-	if err := s.DB.Find(&tasks).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	tasks, err := s.store.Tasks().List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, tasks)
+	c.JSON(200, newTaskResponses(tasks))
 }
 
 // GetTask godoc
@@ -104,21 +252,21 @@ func (s *Server) GetTasks(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Task ID"
-// @Success 200 {object} createTaskResponse{}
+// @Success 200 {object} taskResponse{}
 // @Router /task/{id} [get]
 // @Security ApiKeyAuth
 func (s *Server) GetTask(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can get tasks")))
+		c.Error(apperr.Forbidden("only admins can get tasks"))
 		return
 	}
-	var task models.Task
-	if err := s.DB.First(&task, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	task, err := s.store.Tasks().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, task)
+	c.JSON(200, newTaskResponse(task))
 }
 
 // UpdateTask godoc
@@ -128,50 +276,189 @@ func (s *Server) GetTask(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Task ID"
-// @Param task body createTaskRequest true "Task"
-// @Success 200 {object} createTaskResponse{}
+// @Param task body updateTaskRequest true "Fields to change"
+// @Success 200 {object} taskResponse{}
 // @Router /task/{id} [put]
 // @Security ApiKeyAuth
 func (s *Server) UpdateTask(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	ctx := c.Request.Context()
+	task, err := s.store.Tasks().Get(ctx, idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if authPayload.Role != "Admin" {
-		var user models.User
-		username := authPayload.Username
-		if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		var task models.Task
-		if err := s.DB.First(&task, c.Param("id")).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		if task.DriverID == nil || *task.DriverID != user.ID {
+			c.Error(apperr.NotFound("driver has no assigned tasks"))
 			return
 		}
-		if task.DriverID == nil || task.DriverID != &user.ID {
-			c.JSON(404, errorResponse(errors.New("driver has no assigned tasks")))
-		}
-		temp := "Completed"
-		task.Status = &temp
-		if err := s.DB.Save(&task).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		previousStatus := task.Status
+		completed := string(models.TaskStatusCompleted)
+		task.Status = &completed
+		err = s.storeTransaction(ctx, func(tx store.Store) error {
+			if err := tx.Tasks().Update(ctx, &task); err != nil {
+				return err
+			}
+			return s.publishTaskStatus(ctx, tx, task, previousStatus)
+		})
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		c.JSON(200, task)
+		c.JSON(200, newTaskResponse(task))
 		return
 	}
-	var task models.Task
-	if err := s.DB.First(&task, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	var req updateTaskRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	if err := c.ShouldBindJSON(&task); err != nil {
-		c.JSON(400, errorResponse(err))
+	previousStatus := task.Status
+	var previousDriver uint
+	if task.DriverID != nil {
+		previousDriver = *task.DriverID
+	}
+	route := task.Waypoints
+	task.Waypoints = nil
+	req.apply(&task)
+	if fields := timeOrder("start_time", task.StartTime, "end_time", task.EndTime); fields != nil {
+		c.Error(apperr.Validation(fields...))
+		return
+	}
+	// the driver must be qualified for whatever the task now asks of them
+	if task.DriverID != nil && (req.DriverID != nil || req.VehicleID != nil || req.StartTime != nil || req.EndTime != nil) {
+		if err := s.checkTaskDriver(ctx, &task); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	// new waypoints replace the old route
+	replaceRoute := len(task.Waypoints) > 0
+	if replaceRoute {
+		route = task.Waypoints
+		orderWaypoints(route)
+	}
+	s.estimateRoute(ctx, &task, route)
+	err = s.storeTransaction(ctx, func(tx store.Store) error {
+		if replaceRoute {
+			if err := tx.Tasks().ReplaceWaypoints(ctx, task.ID, route); err != nil {
+				return err
+			}
+		}
+		if err := tx.Tasks().Update(ctx, &task); err != nil {
+			return err
+		}
+		if err := s.publishTaskStatus(ctx, tx, task, previousStatus); err != nil {
+			return err
+		}
+		if task.DriverID != nil && *task.DriverID != previousDriver {
+			return s.publishTaskAssigned(ctx, tx, task)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	if err := s.DB.Save(&task).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	// respond with the route whether or not it was replaced
+	task.Waypoints = route
+	c.JSON(200, newTaskResponse(task))
+}
+
+// orderWaypoints sorts waypoints by the requested sequence and renumbers them from 1
+func orderWaypoints(waypoints []models.TaskWaypoint) {
+	sort.SliceStable(waypoints, func(i, j int) bool {
+		return waypoints[i].Sequence < waypoints[j].Sequence
+	})
+	for i := range waypoints {
+		waypoints[i].Sequence = i + 1
+	}
+}
+
+// estimateRoute fills the distance and duration estimates of the task.
+// Waypoints must already be ordered by sequence.
+func (s *Server) estimateRoute(ctx context.Context, task *models.Task, waypoints []models.TaskWaypoint) {
+	if task.StartLatitude == nil || task.StartLongitude == nil || task.EndLatitude == nil || task.EndLongitude == nil {
 		return
 	}
-	c.JSON(200, task)
+	points := []geo.Point{{Latitude: *task.StartLatitude, Longitude: *task.StartLongitude}}
+	for _, waypoint := range waypoints {
+		if waypoint.Latitude == nil || waypoint.Longitude == nil {
+			continue
+		}
+		points = append(points, geo.Point{Latitude: *waypoint.Latitude, Longitude: *waypoint.Longitude})
+	}
+	points = append(points, geo.Point{Latitude: *task.EndLatitude, Longitude: *task.EndLongitude})
+
+	distance := geo.PathDistance(points)
+	task.EstimatedDistance = &distance
+	task.RoadDistance = nil
+	travelled := distance
+	if s.routeEngine != nil {
+		roadDistance, err := s.routeEngine.RoadDistance(ctx, points)
+		if err != nil {
+			// the great-circle estimate is still useful without the routing engine
+			slog.WarnContext(ctx, "cannot compute road distance", slog.Any("error", err))
+		} else {
+			task.RoadDistance = &roadDistance
+			travelled = roadDistance
+		}
+	}
+
+	speed := s.averageSpeeds.For(s.taskVehicleType(ctx, task))
+	minutes := int(math.Ceil(routing.EstimateDuration(travelled, speed).Minutes()))
+	task.EstimatedMinutes = &minutes
+}
+
+// taskVehicleType returns the type of the task vehicle or, if none is set, of the driver's vehicle
+func (s *Server) taskVehicleType(ctx context.Context, task *models.Task) *string {
+	vehicle, err := s.taskVehicle(ctx, task)
+	if err != nil {
+		return nil
+	}
+	return vehicle.Type
+}
+
+// taskVehicle returns the task vehicle or, if none is set, the first vehicle assigned to the driver
+func (s *Server) taskVehicle(ctx context.Context, task *models.Task) (models.Vehicle, error) {
+	if task.VehicleID != nil {
+		return s.store.Vehicles().Get(ctx, *task.VehicleID)
+	}
+	if task.DriverID == nil {
+		return models.Vehicle{}, store.ErrNotFound
+	}
+	vehicles, err := s.store.Vehicles().ListByDriver(ctx, *task.DriverID)
+	if err != nil {
+		return models.Vehicle{}, err
+	}
+	if len(vehicles) == 0 {
+		return models.Vehicle{}, store.ErrNotFound
+	}
+	return vehicles[0], nil
+}
+
+// checkTaskDriver verifies the driver may drive the task vehicle, or their assigned vehicle, until the task ends
+func (s *Server) checkTaskDriver(ctx context.Context, task *models.Task) error {
+	until := time.Now()
+	if task.EndTime != nil && task.EndTime.After(until) {
+		until = *task.EndTime
+	} else if task.StartTime != nil && task.StartTime.After(until) {
+		until = *task.StartTime
+	}
+	vehicle, err := s.taskVehicle(ctx, task)
+	if err != nil {
+		if task.VehicleID != nil {
+			return err
+		}
+		return s.checkDriverQualified(ctx, *task.DriverID, nil, until)
+	}
+	return s.checkDriverQualified(ctx, *task.DriverID, &vehicle, until)
 }
 
 // DeleteTask godoc
@@ -181,23 +468,23 @@ func (s *Server) UpdateTask(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Task ID"
-// @Success 200 {object} createTaskResponse{}
+// @Success 200 {object} taskResponse{}
 // @Router /task/{id} [delete]
 // @Security ApiKeyAuth
 func (s *Server) DeleteTask(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can delete tasks")))
+		c.Error(apperr.Forbidden("only admins can delete tasks"))
 		return
 	}
-	var task models.Task
-	if err := s.DB.First(&task, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	task, err := s.store.Tasks().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	if err := s.DB.Delete(&task).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := s.store.Tasks().Delete(c.Request.Context(), task.ID); err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, task)
+	c.JSON(200, newTaskResponse(task))
 }
@@ -0,0 +1,140 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+)
+
+func addTask(t *testing.T, h *apitest.Harness, driverID uint, waypoints ...models.TaskWaypoint) models.Task {
+	t.Helper()
+	startLat, startLon, endLat, endLon, status := 51.12, 71.43, 51.09, 71.41, "assigned"
+	task := models.Task{
+		DriverID:       &driverID,
+		StartLatitude:  &startLat,
+		StartLongitude: &startLon,
+		EndLatitude:    &endLat,
+		EndLongitude:   &endLon,
+		Status:         &status,
+		Waypoints:      waypoints,
+	}
+	if err := h.Store.Tasks().Create(context.Background(), &task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func waypointAt(sequence int, lat, lon float64) models.TaskWaypoint {
+	return models.TaskWaypoint{Sequence: sequence, Latitude: &lat, Longitude: &lon}
+}
+
+func TestDriverCompletesOwnTask(t *testing.T) {
+	h := apitest.NewMemory(t)
+	other := h.AddUser("other", apitest.RoleDriver)
+	task := addTask(t, h, h.User(apitest.RoleDriver).ID, waypointAt(1, 51.1, 71.42))
+	path := apitest.Path("/task/:id", task.ID)
+
+	if code := login(h, other).Put(path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("other driver status = %d, want 404", code)
+	}
+	var completed api.TaskResponse
+	if code := h.As(apitest.RoleDriver).Put(path, nil, &completed); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if *completed.Status != "completed" || len(completed.Waypoints) != 1 {
+		t.Errorf("task = %+v, want it completed with its waypoint", completed)
+	}
+	if got, want := h.EventTypes(), []string{events.TaskStatusChanged}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateTaskReplacesRoute(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin := h.As(apitest.RoleAdmin)
+	task := addTask(t, h, h.User(apitest.RoleDriver).ID, waypointAt(1, 51.1, 71.42))
+	path := apitest.Path("/task/:id", task.ID)
+
+	// an update without waypoints keeps the route
+	notes := map[string]interface{}{"notes": "gate 4"}
+	var updated api.TaskResponse
+	if code := admin.Put(path, notes, &updated); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if len(updated.Waypoints) != 1 || updated.EstimatedDistance == nil {
+		t.Errorf("task = %+v, want the old route and a distance estimate", updated)
+	}
+
+	route := map[string]interface{}{"waypoints": []map[string]interface{}{
+		{"sequence": 2, "latitude": 51.11, "longitude": 71.40},
+		{"sequence": 1, "latitude": 51.10, "longitude": 71.45},
+	}}
+	if code := admin.Put(path, route, &updated); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	stored, err := h.Store.Tasks().Get(context.Background(), task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Waypoints) != 2 || *stored.Waypoints[0].Longitude != 71.45 || stored.Waypoints[1].Sequence != 2 {
+		t.Errorf("stored waypoints = %+v, want the new route in sequence order", stored.Waypoints)
+	}
+	if *stored.Notes != "gate 4" {
+		t.Errorf("notes = %v, want them kept", stored.Notes)
+	}
+}
+
+func TestGetTasksOfDriver(t *testing.T) {
+	h := apitest.NewMemory(t)
+	other := h.AddUser("other", apitest.RoleDriver)
+	own := addTask(t, h, h.User(apitest.RoleDriver).ID)
+	addTask(t, h, other.ID)
+
+	var tasks []api.TaskResponse
+	h.As(apitest.RoleDriver).Get("/task", &tasks)
+	if len(tasks) != 1 || tasks[0].ID != own.ID {
+		t.Errorf("driver tasks = %+v, want only task %d", tasks, own.ID)
+	}
+	h.As(apitest.RoleAdmin).Get("/task", &tasks)
+	if len(tasks) != 2 {
+		t.Errorf("admin sees %d tasks, want 2", len(tasks))
+	}
+}
+
+func TestTaskStatusMustBeKnown(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin := h.As(apitest.RoleAdmin)
+	path := apitest.Path("/task/:id", addTask(t, h, h.User(apitest.RoleDriver).ID).ID)
+
+	for status, want := range map[string]int{
+		"delayed":     http.StatusOK,
+		"in_progress": http.StatusOK,
+		"Finished":    http.StatusUnprocessableEntity,
+		"Completed":   http.StatusUnprocessableEntity,
+		"":            http.StatusUnprocessableEntity,
+	} {
+		if code := admin.Put(path, map[string]interface{}{"status": status}, nil); code != want {
+			t.Errorf("update to status %q: status = %d, want %d", status, code, want)
+		}
+	}
+	create := map[string]interface{}{
+		"driver_id": h.User(apitest.RoleDriver).ID, "status": "bogus",
+		"start_latitude": 51.12, "start_longitude": 71.43, "end_latitude": 51.09, "end_longitude": 71.41,
+	}
+	// the driver has no license either, the status must be what is reported
+	recorder := admin.Do(http.MethodPost, "/task", create, nil)
+	var response api.ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusUnprocessableEntity || len(response.Fields) != 1 || response.Fields[0].Field != "status" {
+		t.Errorf("create with status bogus: status = %d %+v, want 422 for status", recorder.Code, response)
+	}
+}
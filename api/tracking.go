@@ -0,0 +1,360 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/geo"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTrackWindow = 24 * time.Hour
+	// maxTrackWindow bounds the pings a single track request loads
+	maxTrackWindow = 7 * 24 * time.Hour
+)
+
+type trackingPoint struct {
+	Latitude  *float64   `json:"lat" binding:"required,gte=-90,lte=90"`
+	Longitude *float64   `json:"lon" binding:"required,gte=-180,lte=180"`
+	Speed     *float64   `json:"speed" binding:"omitempty,gte=0"`
+	Heading   *float64   `json:"heading" binding:"omitempty,gte=0,lt=360"`
+	Timestamp *time.Time `json:"timestamp" binding:"omitempty,notfuture"`
+}
+
+// trackingPingRequest is a batch of at most 500 points
+type trackingPingRequest struct {
+	VehicleID uint            `json:"vehicle_id" binding:"required"`
+	TaskID    *uint           `json:"task_id"`
+	Points    []trackingPoint `json:"points" binding:"required,min=1,max=500,dive"`
+}
+
+type trackingPingResponse struct {
+	Accepted int `json:"accepted"`
+}
+
+type trackPointResponse struct {
+	Latitude   *float64  `json:"lat"`
+	Longitude  *float64  `json:"lon"`
+	Speed      *float64  `json:"speed"`
+	Heading    *float64  `json:"heading"`
+	TaskID     *uint     `json:"task_id"`
+	RecordedAt time.Time `json:"timestamp"`
+}
+
+type vehicleTrackResponse struct {
+	VehicleID uint                 `json:"vehicle_id"`
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Distance  float64              `json:"distance"` // great-circle km along the path
+	Points    []trackPointResponse `json:"points"`
+}
+
+type fleetPositionResponse struct {
+	VehicleID  uint      `json:"vehicle_id"`
+	DriverID   *uint     `json:"driver_id"`
+	TaskID     *uint     `json:"task_id"`
+	Latitude   *float64  `json:"lat"`
+	Longitude  *float64  `json:"lon"`
+	Speed      *float64  `json:"speed"`
+	Heading    *float64  `json:"heading"`
+	RecordedAt time.Time `json:"timestamp"`
+}
+
+func newTrackPointResponse(ping models.LocationPing) trackPointResponse {
+	return trackPointResponse{
+		Latitude:   ping.Latitude,
+		Longitude:  ping.Longitude,
+		Speed:      ping.Speed,
+		Heading:    ping.Heading,
+		TaskID:     ping.TaskID,
+		RecordedAt: ping.RecordedAt,
+	}
+}
+
+// PostTrackingPing godoc
+// @Summary Submit GPS points
+// @Description Drivers submit a batch of GPS points for their vehicle and current task
+// @Tags tracking
+// @Accept  json
+// @Produce  json
+// @Param ping body trackingPingRequest true "Batch of points"
+// @Success 200 {object} trackingPingResponse{}
+// @Router /tracking/ping [post]
+// @Security ApiKeyAuth
+func (s *Server) PostTrackingPing(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Driver" {
+		c.Error(apperr.Forbidden("only drivers can submit location pings"))
+		return
+	}
+	var req trackingPingRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), req.VehicleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if vehicle.AssignedDriver == nil || *vehicle.AssignedDriver != user.ID {
+		c.Error(apperr.Forbidden("vehicle is not assigned to the driver"))
+		return
+	}
+	if req.TaskID != nil {
+		task, err := s.store.Tasks().Get(c.Request.Context(), *req.TaskID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if task.DriverID == nil || *task.DriverID != user.ID {
+			c.Error(apperr.Forbidden("task is not assigned to the driver"))
+			return
+		}
+	}
+
+	// the last known position is needed to detect geofence transitions
+	var previous *models.LocationPing
+	var last models.LocationPing
+	if err := s.DB.WithContext(c.Request.Context()).Where("vehicle_id = ?", vehicle.ID).Order("recorded_at DESC").First(&last).Error; err == nil {
+		previous = &last
+	}
+
+	now := time.Now()
+	pings := make([]models.LocationPing, len(req.Points))
+	for i, point := range req.Points {
+		recordedAt := now
+		if point.Timestamp != nil {
+			recordedAt = *point.Timestamp
+		}
+		pings[i] = models.LocationPing{
+			VehicleID:  &vehicle.ID,
+			TaskID:     req.TaskID,
+			DriverID:   &user.ID,
+			Latitude:   point.Latitude,
+			Longitude:  point.Longitude,
+			Speed:      point.Speed,
+			Heading:    point.Heading,
+			RecordedAt: recordedAt,
+		}
+	}
+	err = s.transaction(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&pings, 100).Error; err != nil {
+			return err
+		}
+		return s.evaluatePositions(tx, previous, pings)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	latest := pings[len(pings)-1]
+	s.publishLive(events.Event{
+		Type:      events.LocationPinged,
+		VehicleID: latest.VehicleID,
+		DriverID:  latest.DriverID,
+		Data:      newTrackPointResponse(latest),
+	})
+	c.JSON(200, trackingPingResponse{Accepted: len(pings)})
+}
+
+// GetVehicleTrack godoc
+// @Summary Get the path of a vehicle
+// @Description Returns the GPS points of a vehicle between from and to (RFC3339), the last 24 hours by default.
+// @Description The window may span at most 7 days.
+// @Tags tracking
+// @Produce  json
+// @Param id path int true "Vehicle ID"
+// @Param from query string false "Start of the window"
+// @Param to query string false "End of the window"
+// @Success 200 {object} vehicleTrackResponse{}
+// @Router /vehicle/{id}/track [get]
+// @Security ApiKeyAuth
+func (s *Server) GetVehicleTrack(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if authPayload.Role != "Admin" {
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if vehicle.AssignedDriver == nil || *vehicle.AssignedDriver != user.ID {
+			c.Error(apperr.Forbidden("only admins and the assigned driver can get the track"))
+			return
+		}
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.Error(apperr.Invalid("to", "to must be an RFC3339 time"))
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultTrackWindow)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.Error(apperr.Invalid("from", "from must be an RFC3339 time"))
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		c.Error(apperr.Invalid("from", "from must be before to"))
+		return
+	}
+	if to.Sub(from) > maxTrackWindow {
+		c.Error(apperr.Invalid("from", "from must be at most 7 days before to"))
+		return
+	}
+
+	var pings []models.LocationPing
+	if err := s.DB.WithContext(c.Request.Context()).Where("vehicle_id = ? AND recorded_at BETWEEN ? AND ?", vehicle.ID, from, to).
+		Order("recorded_at").Find(&pings).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := vehicleTrackResponse{
+		VehicleID: vehicle.ID,
+		From:      from,
+		To:        to,
+		Points:    make([]trackPointResponse, len(pings)),
+	}
+	path := make([]geo.Point, len(pings))
+	for i, ping := range pings {
+		response.Points[i] = newTrackPointResponse(ping)
+		path[i] = geo.Point{Latitude: *ping.Latitude, Longitude: *ping.Longitude}
+	}
+	response.Distance = geo.PathDistance(path)
+	c.JSON(200, response)
+}
+
+// GetFleetPositions godoc
+// @Summary Get last known positions
+// @Description Returns the last known position of every vehicle that has reported one
+// @Tags tracking
+// @Produce  json
+// @Success 200 {array} []fleetPositionResponse{}
+// @Router /fleet/positions [get]
+// @Security ApiKeyAuth
+func (s *Server) GetFleetPositions(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get fleet positions"))
+		return
+	}
+	latest := s.DB.WithContext(c.Request.Context()).Model(&models.LocationPing{}).
+		Select("vehicle_id, MAX(recorded_at) AS recorded_at").
+		Group("vehicle_id")
+	var pings []models.LocationPing
+	if err := s.DB.WithContext(c.Request.Context()).Joins("JOIN (?) AS latest ON latest.vehicle_id = location_pings.vehicle_id AND latest.recorded_at = location_pings.recorded_at", latest).
+		Order("location_pings.vehicle_id, location_pings.id DESC").
+		Find(&pings).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := make([]fleetPositionResponse, 0, len(pings))
+	seen := make(map[uint]bool)
+	for _, ping := range pings {
+		// several pings can share the latest timestamp, keep the last inserted one
+		if seen[*ping.VehicleID] {
+			continue
+		}
+		seen[*ping.VehicleID] = true
+		response = append(response, fleetPositionResponse{
+			VehicleID:  *ping.VehicleID,
+			DriverID:   ping.DriverID,
+			TaskID:     ping.TaskID,
+			Latitude:   ping.Latitude,
+			Longitude:  ping.Longitude,
+			Speed:      ping.Speed,
+			Heading:    ping.Heading,
+			RecordedAt: ping.RecordedAt,
+		})
+	}
+	c.JSON(200, response)
+}
+
+// purgeLocationPings deletes pings older than the retention period
+func (s *Server) purgeLocationPings(ctx context.Context) error {
+	cutoff := time.Now().Add(-s.config.LocationRetention)
+	result := s.DB.WithContext(ctx).Where("recorded_at < ?", cutoff).Delete(&models.LocationPing{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		slog.InfoContext(ctx, "purged location pings", slog.Int64("count", result.RowsAffected), slog.Time("before", cutoff))
+	}
+	return nil
+}
+
+// recordTaskUsage is the outbox subscriber that stores the tracked path of a completed task as a
+// vehicle usage, so the distance report keeps it after the pings are purged
+func (s *Server) recordTaskUsage(evt events.Event) error {
+	if evt.Type != events.TaskStatusChanged {
+		return nil
+	}
+	var data struct {
+		TaskID uint    `json:"task_id"`
+		Status *string `json:"status"`
+	}
+	if raw, ok := evt.Data.(json.RawMessage); ok {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+	}
+	if data.Status == nil || !strings.EqualFold(*data.Status, string(models.TaskStatusCompleted)) {
+		return nil
+	}
+	var pings []models.LocationPing
+	if err := s.DB.Where("task_id = ?", data.TaskID).Order("recorded_at").Find(&pings).Error; err != nil {
+		return err
+	}
+	if len(pings) == 0 {
+		return nil
+	}
+	path := make([]geo.Point, len(pings))
+	for i, ping := range pings {
+		path[i] = geo.Point{Latitude: *ping.Latitude, Longitude: *ping.Longitude}
+	}
+	distance := geo.PathDistance(path)
+	first, last := pings[0], pings[len(pings)-1]
+	usage := models.VehicleUsage{
+		VehicleID: first.VehicleID,
+		TaskID:    &data.TaskID,
+		StartTime: &first.RecordedAt,
+		EndTime:   &last.RecordedAt,
+		Distance:  &distance,
+	}
+	// a task that is completed again replaces its usage
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("task_id = ?", data.TaskID).Delete(&models.VehicleUsage{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&usage).Error
+	})
+}
@@ -0,0 +1,33 @@
+package api_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+)
+
+func TestTrackWindowIsCapped(t *testing.T) {
+	h := apitest.New(t)
+	vehicle := addVehicle(t, h, "Active", nil)
+	to := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		window time.Duration
+		want   int
+	}{
+		{7 * 24 * time.Hour, http.StatusOK},
+		{7*24*time.Hour + time.Second, http.StatusUnprocessableEntity},
+	} {
+		query := url.Values{
+			"from": {to.Add(-test.window).Format(time.RFC3339)},
+			"to":   {to.Format(time.RFC3339)},
+		}
+		path := apitest.Path("/vehicle/:id/track", vehicle.ID) + "?" + query.Encode()
+		if code := h.As(apitest.RoleAdmin).Get(path, nil); code != test.want {
+			t.Errorf("track over %v status = %d, want %d", test.window, code, test.want)
+		}
+	}
+}
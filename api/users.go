@@ -1,31 +1,55 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
 	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/ratelimit"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 	"github.com/rassulmagauin/VMS_SWE/utils"
-	"gorm.io/gorm"
 )
 
 type createUserRequest struct {
-	Username             string  `gorm:"not null" json:"username"`
-	Password             *string `gorm:"not null" json:"password"`
-	GovermentID          *string `json:"goverment_id"`
-	MiddleName           *string `json:"middle_name"`
-	Address              *string `json:"address"`
-	PhoneNumber          *string `json:"phone_number"`
-	DrivingLicenseNumber *string `json:"driving_license_number"`
-	Role                 *string `gorm:"not null" json:"role"`
-	FirstName            *string `gorm:"not null" json:"first_name"`
-	LastName             *string `gorm:"not null" json:"last_name"`
-	Email                *string `json:"email"`
-	Status               *string `json:"status"`
+	Username             string  `json:"username" binding:"required,min=3,max=50"`
+	Password             *string `json:"password" binding:"required,min=1,max=72"`
+	GovermentID          *string `json:"goverment_id" binding:"omitempty,max=30"`
+	MiddleName           *string `json:"middle_name" binding:"omitempty,max=50"`
+	Address              *string `json:"address" binding:"omitempty,max=200"`
+	PhoneNumber          *string `json:"phone_number" binding:"omitempty,max=20"`
+	DrivingLicenseNumber *string `json:"driving_license_number" binding:"omitempty,max=30"`
+	Role                 *string `json:"role" binding:"required,oneof=Admin Driver Fueling_person Maintenance_person"`
+	FirstName            *string `json:"first_name" binding:"required,min=1,max=50"`
+	LastName             *string `json:"last_name" binding:"required,min=1,max=50"`
+	Email                *string `json:"email" binding:"omitempty,email"`
+	Status               *string `json:"status" binding:"omitempty,max=30"`
+}
+
+// updateUserRequest replaces the user's fields, the password only changes when one is sent
+type updateUserRequest struct {
+	Username             string  `json:"username" binding:"required,min=3,max=50"`
+	Password             *string `json:"password" binding:"omitempty,min=1,max=72"`
+	GovermentID          *string `json:"goverment_id" binding:"omitempty,max=30"`
+	MiddleName           *string `json:"middle_name" binding:"omitempty,max=50"`
+	Address              *string `json:"address" binding:"omitempty,max=200"`
+	PhoneNumber          *string `json:"phone_number" binding:"omitempty,max=20"`
+	DrivingLicenseNumber *string `json:"driving_license_number" binding:"omitempty,max=30"`
+	Role                 *string `json:"role" binding:"required,oneof=Admin Driver Fueling_person Maintenance_person"`
+	FirstName            *string `json:"first_name" binding:"required,min=1,max=50"`
+	LastName             *string `json:"last_name" binding:"required,min=1,max=50"`
+	Email                *string `json:"email" binding:"omitempty,email"`
+	Status               *string `json:"status" binding:"omitempty,max=30"`
 }
 
+// userResponse never carries the password hash. The personal fields are null unless the
+// caller may see them, see canSeePersonalData.
 type userResponse struct {
 	ID                   uint    `json:"ID"`
 	FirstName            *string `json:"first_name"`
@@ -41,21 +65,36 @@ type userResponse struct {
 	Status               *string `json:"status"`
 }
 
-func newUserResonse(user models.User) userResponse {
-	return userResponse{
-		ID:                   user.ID,
-		FirstName:            user.FirstName,
-		LastName:             user.LastName,
-		Username:             user.Username,
-		GovermentID:          user.GovermentID,
-		MiddleName:           user.MiddleName,
-		Address:              user.Address,
-		PhoneNumber:          user.PhoneNumber,
-		DrivingLicenseNumber: user.DrivingLicenseNumber,
-		Role:                 user.Role,
-		Email:                user.Email,
-		Status:               user.Status,
+func newUserResponse(user models.User, showPersonal bool) userResponse {
+	response := userResponse{
+		ID:          user.ID,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		Username:    user.Username,
+		MiddleName:  user.MiddleName,
+		PhoneNumber: user.PhoneNumber,
+		Role:        user.Role,
+		Email:       user.Email,
+		Status:      user.Status,
 	}
+	if showPersonal {
+		response.GovermentID = user.GovermentID
+		response.Address = user.Address
+		response.DrivingLicenseNumber = user.DrivingLicenseNumber
+	}
+	return response
+}
+
+// canSeePersonalData reports whether the caller may see the government ID, address and
+// license number of user, only admins and the user themself may
+func canSeePersonalData(viewer *token.Payload, user models.User) bool {
+	return viewer != nil && (viewer.Role == "Admin" || viewer.UserID == user.ID)
+}
+
+// currentUser returns the user the request is authenticated as
+func (s *Server) currentUser(c *gin.Context) (models.User, error) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	return s.store.Users().Get(c.Request.Context(), authPayload.UserID)
 }
 
 // CreateUser godoc
@@ -73,13 +112,17 @@ func (s *Server) CreateUser(c *gin.Context) {
 	// 	return
 	// }
 	var userReq createUserRequest
-	if err := c.ShouldBindJSON(&userReq); err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := bindJSON(c, &userReq); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.checkPassword("password", *userReq.Password); err != nil {
+		c.Error(err)
 		return
 	}
 	hashedPassword, err := utils.HashPassword(*userReq.Password)
 	if err != nil {
-		c.JSON(400, errorResponse(err))
+		c.Error(err)
 		return
 	}
 	user := models.User{
@@ -97,84 +140,43 @@ func (s *Server) CreateUser(c *gin.Context) {
 		Status:               userReq.Status,
 	}
 
-	if err := s.DB.Create(&user).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := s.store.Users().Create(c.Request.Context(), &user); err != nil {
+		c.Error(err)
 		return
 	}
-	response := newUserResonse(user)
+	// the caller has just sent the personal data
+	response := newUserResponse(user, true)
 	c.JSON(200, response)
 }
 
-type getUserResponse struct {
-	ID                   uint    `gorm:"not null" json:"ID"`
-	FirstName            *string `gorm:"not null" json:"first_name"`
-	LastName             *string `gorm:"not null" json:"last_name"`
-	Username             string  `gorm:"not null;unique" json:"username"`
-	GovermentID          *string `json:"goverment_id"`
-	MiddleName           *string `json:"middle_name"`
-	Address              *string `json:"address"`
-	PhoneNumber          *string `json:"phone_number"`
-	DrivingLicenseNumber *string `json:"driving_license_number"`
-	Role                 *string `gorm:"not null" json:"role"`
-	Email                *string `json:"email"`
-	Status               *string `json:"status"`
-}
-
 // GetUsers godoc
 // @Summary Get users
 // @Description Gets all users from database
 // @Produce application/json
 // @Tags user
-// @Success 200 {object} []getUserResponse{}
+// @Success 200 {object} []userResponse{}
 // @Router /user [get]
 // @Security ApiKeyAuth
 func (s *Server) GetUsers(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		var user models.User
-		username := authPayload.Username
-		if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		response := getUserResponse{
-			ID:                   user.ID,
-			FirstName:            user.FirstName,
-			LastName:             user.LastName,
-			Username:             user.Username,
-			GovermentID:          user.GovermentID,
-			MiddleName:           user.MiddleName,
-			Address:              user.Address,
-			PhoneNumber:          user.PhoneNumber,
-			DrivingLicenseNumber: user.DrivingLicenseNumber,
-			Role:                 user.Role,
-			Email:                user.Email,
-			Status:               user.Status,
-		}
+		response := newUserResponse(user, canSeePersonalData(authPayload, user))
 		c.JSON(200, response)
 		return
 	}
-	var users []models.User
-	if err := s.DB.Find(&users).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	users, err := s.store.Users().List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	response := make([]getUserResponse, len(users))
+	response := make([]userResponse, len(users))
 	for i, user := range users {
-		response[i] = getUserResponse{
-			ID:                   user.ID,
-			FirstName:            user.FirstName,
-			LastName:             user.LastName,
-			Username:             user.Username,
-			GovermentID:          user.GovermentID,
-			MiddleName:           user.MiddleName,
-			Address:              user.Address,
-			PhoneNumber:          user.PhoneNumber,
-			DrivingLicenseNumber: user.DrivingLicenseNumber,
-			Role:                 user.Role,
-			Email:                user.Email,
-			Status:               user.Status,
-		}
+		response[i] = newUserResponse(user, canSeePersonalData(authPayload, user))
 	}
 	c.JSON(200, response)
 }
@@ -185,42 +187,29 @@ func (s *Server) GetUsers(c *gin.Context) {
 // @Param id path string true "User ID"
 // @Produce application/json
 // @Tags user
-// @Success 200 {object} getUserResponse{}
+// @Success 200 {object} userResponse{}
 // @Router /user/{id} [get]
 // @Security ApiKeyAuth
 func (s *Server) GetUser(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can get users")))
+		c.Error(apperr.Forbidden("only admins can get users"))
 		return
 	}
-	var user models.User
-	if err := s.DB.First(&user, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	response := getUserResponse{
-		ID:                   user.ID,
-		FirstName:            user.FirstName,
-		LastName:             user.LastName,
-		Username:             user.Username,
-		GovermentID:          user.GovermentID,
-		MiddleName:           user.MiddleName,
-		Address:              user.Address,
-		PhoneNumber:          user.PhoneNumber,
-		DrivingLicenseNumber: user.DrivingLicenseNumber,
-		Role:                 user.Role,
-		Email:                user.Email,
-		Status:               user.Status,
-	}
+	response := newUserResponse(user, canSeePersonalData(authPayload, user))
 	c.JSON(200, response)
 }
 
 // UpdateUser godoc
 // @Summary Update user
-// @Description Updates user in database
+// @Description Updates user in database. A new password logs the user out everywhere.
 // @Param id path string true "User ID"
-// @Param user body createUserRequest true "User"
+// @Param user body updateUserRequest true "User"
 // @Produce application/json
 // @Tags user
 // @Success 200 {object} userResponse{}
@@ -229,27 +218,26 @@ func (s *Server) GetUser(c *gin.Context) {
 func (s *Server) UpdateUser(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can update users")))
+		c.Error(apperr.Forbidden("only admins can update users"))
 		return
 	}
-	var user models.User
-	if err := s.DB.First(&user, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	var userReq createUserRequest
-	if err := c.ShouldBindJSON(&userReq); err != nil {
-		c.JSON(400, errorResponse(err))
+	var userReq updateUserRequest
+	if err := bindJSON(c, &userReq); err != nil {
+		c.Error(err)
 		return
 	}
-	hashedPassword, err := utils.HashPassword(*userReq.Password)
-	if err != nil {
-		c.JSON(400, errorResponse(err))
-		return
-
+	if userReq.Password != nil {
+		if err := s.checkPassword("password", *userReq.Password); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 	user.Username = userReq.Username
-	user.HashedPassword = &hashedPassword
 	user.GovermentID = userReq.GovermentID
 	user.MiddleName = userReq.MiddleName
 	user.Address = userReq.Address
@@ -261,11 +249,18 @@ func (s *Server) UpdateUser(c *gin.Context) {
 	user.Email = userReq.Email
 	user.Status = userReq.Status
 
-	if err := s.DB.Save(&user).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	ctx := c.Request.Context()
+	err = s.storeTransaction(ctx, func(tx store.Store) error {
+		if userReq.Password != nil {
+			return setPassword(ctx, tx, &user, *userReq.Password, time.Now())
+		}
+		return tx.Users().Update(ctx, &user)
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	response := newUserResonse(user)
+	response := newUserResponse(user, canSeePersonalData(authPayload, user))
 	c.JSON(200, response)
 }
 
@@ -284,16 +279,11 @@ type deleteUserResponse struct {
 func (s *Server) DeleteUser(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can delete users")))
-		return
-	}
-	var user models.User
-	if err := s.DB.First(&user, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+		c.Error(apperr.Forbidden("only admins can delete users"))
 		return
 	}
-	if err := s.DB.Delete(&user).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := s.store.Users().Delete(c.Request.Context(), idParam(c, "id")); err != nil {
+		c.Error(err)
 		return
 	}
 	deleteUserResponse := deleteUserResponse{}
@@ -301,49 +291,147 @@ func (s *Server) DeleteUser(c *gin.Context) {
 }
 
 type loginRequest struct {
-	Username string `gorm:"not null" json:"username"`
-	Password string `gorm:"not null" json:"password"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 type loginResponse struct {
 	AccessToken string       `json:"access_token"`
 	User        userResponse `json:"user"`
 }
 
+var (
+	dummyPasswordHashOnce sync.Once
+	dummyPasswordHash     string
+)
+
+// checkDummyPassword takes as long as checking a real password, so unknown usernames
+// cannot be told apart by the response time
+func checkDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = utils.HashPassword("dummy password")
+	})
+	utils.CheckPassword(password, dummyPasswordHash)
+}
+
 // LoginUser godoc
 // @Summary Login user
-// @Description Logs user in
+// @Description Logs user in. Attempts are limited per client IP and per username, and repeated failures
+// @Description lock the username out for a while; both are answered with 429 and a Retry-After header.
+// @Description Unknown usernames and wrong passwords get the same 401.
 // @Param user body loginRequest true "User"
 // @Produce application/json
 // @Tags user
 // @Success 200 {object} loginResponse{}
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Router /login [post]
 func (s *Server) LoginUser(c *gin.Context) {
 	var loginReq loginRequest
-	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := bindJSON(c, &loginReq); err != nil {
+		c.Error(err)
+		return
+	}
+	ctx := c.Request.Context()
+	if err := s.checkLoginLimits(ctx, c.ClientIP(), loginReq.Username); err != nil {
+		c.Error(err)
 		return
 	}
-	var user models.User
-	if err := s.DB.Where("username = ?", loginReq.Username).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, errorResponse(err))
+	// unknown users and wrong passwords fail alike so usernames cannot be probed
+	user, err := s.store.Users().GetByUsername(ctx, loginReq.Username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			checkDummyPassword(loginReq.Password)
+			c.Error(s.failLogin(ctx, loginReq.Username))
 			return
 		}
-		c.JSON(400, errorResponse(err))
+		c.Error(err)
 		return
 	}
 	if err := utils.CheckPassword(loginReq.Password, *user.HashedPassword); err != nil {
-		c.JSON(http.StatusUnauthorized, errorResponse(err))
+		c.Error(s.failLogin(ctx, loginReq.Username))
 		return
 	}
-	accessToken, err := s.tokenMaker.CreateToken(user.Username, *user.Role, AccessTokenDuration)
+	if err := s.lockout.Succeed(ctx, loginReq.Username); err != nil {
+		c.Error(err)
+		return
+	}
+	accessToken, err := s.tokenMaker.CreateToken(user.ID, user.Username, *user.Role, s.config.Token.AccessTokenDuration)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		c.Error(err)
 		return
 	}
+	// the caller has just proven to be the user
 	response := loginResponse{
 		AccessToken: accessToken,
-		User:        newUserResonse(user),
+		User:        newUserResponse(user, true),
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+// checkLoginLimits counts a login attempt, returning a too_many_requests error when the client
+// or the username has made too many or the username is locked out
+func (s *Server) checkLoginLimits(ctx context.Context, clientIP, username string) error {
+	window := s.config.RateLimit.LoginWindow
+	limits := []struct {
+		key   string
+		limit ratelimit.Limit
+	}{
+		{"login:ip:" + clientIP, ratelimit.Limit{Requests: s.config.RateLimit.LoginPerIP, Window: window}},
+		{"login:username:" + strings.ToLower(username), ratelimit.Limit{Requests: s.config.RateLimit.LoginPerUsername, Window: window}},
+	}
+	for _, l := range limits {
+		result, err := s.limiter.Allow(ctx, l.key, l.limit)
+		if err != nil {
+			return err
+		}
+		if !result.Allowed {
+			return apperr.TooMany(result.RetryAfter, "too many login attempts, try again later")
+		}
+	}
+	locked, err := s.lockout.Locked(ctx, username)
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return apperr.TooMany(locked, "too many failed logins, try again later")
+	}
+	return nil
+}
+
+// failLogin records a failed login and returns the error to answer it with
+func (s *Server) failLogin(ctx context.Context, username string) error {
+	if _, err := s.lockout.Fail(ctx, username); err != nil {
+		return err
+	}
+	return apperr.Unauthorized("invalid username or password")
+}
+
+type unlockUserResponse struct {
+}
+
+// UnlockUser godoc
+// @Summary Unlock user
+// @Description Lifts the lockout of a user after failed logins and forgets the earlier lockouts
+// @Param id path string true "User ID"
+// @Produce application/json
+// @Tags user
+// @Success 200 {object} unlockUserResponse{}
+// @Router /user/{id}/unlock [post]
+// @Security ApiKeyAuth
+func (s *Server) UnlockUser(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can unlock users"))
+		return
+	}
+	user, err := s.store.Users().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.lockout.Unlock(c.Request.Context(), user.Username); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, unlockUserResponse{})
+}
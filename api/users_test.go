@@ -0,0 +1,171 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/config"
+)
+
+func TestLoginLockoutAndUnlock(t *testing.T) {
+	// the database backend keeps its counters in SQLite, so only it needs a harness with a database
+	for _, tt := range []struct {
+		backend    string
+		newHarness func(testing.TB, ...func(*config.Config)) *apitest.Harness
+	}{
+		{config.RateLimitMemory, apitest.NewMemory},
+		{config.RateLimitDatabase, apitest.New},
+	} {
+		t.Run(tt.backend, func(t *testing.T) {
+			h := tt.newHarness(t, func(cfg *config.Config) {
+				cfg.RateLimit.Backend = tt.backend
+				cfg.RateLimit.LoginPerIP = 0
+				cfg.RateLimit.LoginPerUsername = 0
+				cfg.RateLimit.LockoutFailures = 3
+			})
+			// log the admin in before the failures, logins from the address are not what is locked out
+			admin := h.As(apitest.RoleAdmin)
+			driver := h.User(apitest.RoleDriver)
+			login := func(username, password string) *httptest.ResponseRecorder {
+				return h.Anonymous().Do(http.MethodPost, "/login", api.LoginRequest{Username: username, Password: password}, nil)
+			}
+
+			for i := 0; i < 3; i++ {
+				if code := login("driver", "wrong").Code; code != http.StatusUnauthorized {
+					t.Fatalf("failed login %d status = %d, want 401", i+1, code)
+				}
+			}
+			recorder := login("driver", apitest.Password)
+			if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "60" {
+				t.Fatalf("login while locked out status = %d, Retry-After %q, want 429 and 60", recorder.Code, recorder.Header().Get("Retry-After"))
+			}
+
+			// unknown usernames are locked out the same way
+			for i := 0; i < 3; i++ {
+				login("nobody", "wrong")
+			}
+			if code := login("nobody", "wrong").Code; code != http.StatusTooManyRequests {
+				t.Errorf("unknown username status = %d, want 429 like a real one", code)
+			}
+
+			path := apitest.Path("/user/:id/unlock", driver.ID)
+			if code := h.As(apitest.RoleMaintenancePerson).Post(path, nil, nil); code != http.StatusForbidden {
+				t.Errorf("unlock by a non-admin status = %d, want 403", code)
+			}
+			if code := admin.Post(path, nil, nil); code != http.StatusOK {
+				t.Fatalf("unlock status = %d, want 200", code)
+			}
+			if code := login("driver", apitest.Password).Code; code != http.StatusOK {
+				t.Errorf("login after unlock status = %d, want 200", code)
+			}
+		})
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	h := apitest.NewMemory(t, func(cfg *config.Config) {
+		cfg.RateLimit.LoginPerIP = 2
+		cfg.RateLimit.LockoutFailures = 0
+	})
+
+	for i := 0; i < 2; i++ {
+		if code := h.Anonymous().Post("/login", api.LoginRequest{Username: "driver", Password: apitest.Password}, nil); code != http.StatusOK {
+			t.Fatalf("login %d status = %d, want 200", i+1, code)
+		}
+	}
+	if code := h.Anonymous().Post("/login", api.LoginRequest{Username: "someone", Password: apitest.Password}, nil); code != http.StatusTooManyRequests {
+		t.Fatalf("login over the limit status = %d, want 429", code)
+	}
+}
+
+func TestLoginRateLimitBehindProxy(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		trustedProxies []string
+		want           int
+	}{
+		// without trusted proxies a forged X-Forwarded-For does not get around the limit
+		{"untrusted", nil, http.StatusTooManyRequests},
+		{"trusted", []string{"192.0.2.0/24"}, http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := apitest.NewMemory(t, func(cfg *config.Config) {
+				cfg.RateLimit.LoginPerIP = 1
+				cfg.RateLimit.LoginPerUsername = 0
+				cfg.HTTP.TrustedProxies = test.trustedProxies
+			})
+
+			var code int
+			for i, clientIP := range []string{"198.51.100.1", "198.51.100.2"} {
+				req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"username":"driver","password":"secret"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-Forwarded-For", clientIP)
+				recorder := httptest.NewRecorder()
+				h.Server.Router.ServeHTTP(recorder, req)
+				if code = recorder.Code; i == 0 && code != http.StatusOK {
+					t.Fatalf("first login status = %d, want 200", code)
+				}
+			}
+			if code != test.want {
+				t.Errorf("login from another forwarded address status = %d, want %d", code, test.want)
+			}
+		})
+	}
+}
+
+func TestRequestsPerTokenLimit(t *testing.T) {
+	h := apitest.NewMemory(t, func(cfg *config.Config) {
+		cfg.RateLimit.RequestsPerToken = 2
+	})
+	driver := h.As(apitest.RoleDriver)
+
+	for i := 0; i < 2; i++ {
+		if code := driver.Get("/vehicle", nil); code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, code)
+		}
+	}
+	recorder := driver.Do(http.MethodGet, "/vehicle", nil, nil)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit status = %d, want 429", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" || recorder.Header().Get(api.RateLimitRemainingHeader) != "0" {
+		t.Errorf("headers = %v, want Retry-After and no remaining requests", recorder.Header())
+	}
+
+	// another token of the same user has its own limit
+	if code := login(h, h.User(apitest.RoleDriver)).Get("/vehicle", nil); code != http.StatusOK {
+		t.Errorf("request with a new token status = %d, want 200", code)
+	}
+}
+
+func TestTokensFollowTheUserID(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	user := h.User(apitest.RoleDriver)
+	path := apitest.Path("/user/:id", user.ID)
+
+	name := "Dana"
+	rename := api.UpdateUserRequest{Username: "dana", Role: user.Role, FirstName: &name, LastName: &name}
+	if code := admin.Put(path, rename, nil); code != http.StatusOK {
+		t.Fatalf("rename status = %d, want 200", code)
+	}
+	// the old name goes to someone else, the token still belongs to the renamed driver
+	h.AddUser("driver", apitest.RoleDriver)
+	var self struct {
+		ID uint `json:"ID"`
+	}
+	if code := driver.Get("/user", &self); code != http.StatusOK || self.ID != user.ID {
+		t.Errorf("token after a rename status = %d, user %d, want 200 and user %d", code, self.ID, user.ID)
+	}
+
+	if code := admin.Delete(path); code != http.StatusOK {
+		t.Fatalf("delete status = %d, want 200", code)
+	}
+	if code := driver.Get("/vehicle", nil); code != http.StatusUnauthorized {
+		t.Errorf("token of a deleted user status = %d, want 401", code)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+)
+
+// vehicles built from 1981 on carry a 17 character VIN without I, O and Q
+var vinPattern = regexp.MustCompile(`^[A-HJ-NPR-Z0-9]{17}$`)
+
+var registerValidatorsOnce sync.Once
+
+// registerValidators adds the rules used in the binding tags of the request types and
+// makes validation errors name fields after their JSON keys
+func registerValidators() {
+	registerValidatorsOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+		v.RegisterValidation("vin", func(fl validator.FieldLevel) bool {
+			return vinPattern.MatchString(strings.ToUpper(fl.Field().String()))
+		})
+		// maxyear allows model years up to next year, dealers sell those in autumn
+		v.RegisterValidation("maxyear", func(fl validator.FieldLevel) bool {
+			return fl.Field().Int() <= int64(time.Now().Year()+1)
+		})
+		v.RegisterValidation("notfuture", func(fl validator.FieldLevel) bool {
+			t, ok := fl.Field().Interface().(time.Time)
+			// a minute of slack for clocks of clients running ahead
+			return ok && !t.After(time.Now().Add(time.Minute))
+		})
+		v.RegisterValidation("hhmm", func(fl validator.FieldLevel) bool {
+			_, err := time.Parse(permittedHoursLayout, fl.Field().String())
+			return err == nil
+		})
+		// vehicletype allows the types the license check knows a category for
+		v.RegisterValidation("vehicletype", func(fl validator.FieldLevel) bool {
+			_, ok := licenseCategories[strings.ToLower(fl.Field().String())]
+			return ok
+		})
+		v.RegisterValidation("date", func(fl validator.FieldLevel) bool {
+			_, err := parseDate(fl.Field().String())
+			return err == nil
+		})
+	})
+}
+
+// validatable is implemented by requests with rules spanning several fields
+type validatable interface {
+	validate() []apperr.FieldError
+}
+
+// bindJSON decodes the body into req and checks its binding rules and, if req is
+// validatable, its cross-field rules. All invalid fields are reported together.
+func bindJSON(c *gin.Context, req interface{}) error {
+	return checkRequest(req, c.ShouldBindJSON(req))
+}
+
+// bindForm is bindJSON for multipart and url-encoded forms
+func bindForm(c *gin.Context, req interface{}) error {
+	return checkRequest(req, c.ShouldBind(req))
+}
+
+func checkRequest(req interface{}, bindErr error) error {
+	var fields []apperr.FieldError
+	if bindErr != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(bindErr, &validationErrs) {
+			// the body could not be decoded at all
+			return bindErr
+		}
+		fields = apperr.From(bindErr).Fields
+	}
+	if r, ok := req.(validatable); ok {
+		fields = append(fields, r.validate()...)
+	}
+	if len(fields) > 0 {
+		return apperr.Validation(fields...)
+	}
+	return nil
+}
+
+// timeOrder rejects end when it is not after start, both may be unset
+func timeOrder(startField string, start *time.Time, endField string, end *time.Time) []apperr.FieldError {
+	if start == nil || end == nil || end.After(*start) {
+		return nil
+	}
+	return []apperr.FieldError{{Field: endField, Message: endField + " must be after " + startField}}
+}
+
+// setIfPresent overwrites dst for fields sent in a partial update
+func setIfPresent[T any](dst **T, value *T) {
+	if value != nil {
+		*dst = value
+	}
+}
+
+func upperString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	upper := strings.ToUpper(*s)
+	return &upper
+}
@@ -1,50 +1,135 @@
 package api
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
 	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/store"
 	"github.com/rassulmagauin/VMS_SWE/token"
 )
 
 type createVehicleRequest struct {
-	Make            *string    `json:"make"`
-	CarModel        *string    `json:"car_model"`
-	Year            *int       `json:"year"`
-	LicensePlate    *string    `gorm:"not null" json:"license_plate"`
-	SittingCapacity *int       `json:"sitting_capacity"`
-	Type            *string    `json:"type"`
-	Color           *string    `json:"color"`
-	VIN             *string    `gorm:"not null" json:"vin"`
-	CurrentMileage  *int       `json:"current_mileage"`
-	LastMaintenance *time.Time `json:"last_maintenance"`
+	Make            *string    `json:"make" binding:"omitempty,max=50"`
+	CarModel        *string    `json:"car_model" binding:"omitempty,max=50"`
+	Year            *int       `json:"year" binding:"omitempty,gte=1900,maxyear"`
+	LicensePlate    *string    `json:"license_plate" binding:"required,min=1,max=20"`
+	SittingCapacity *int       `json:"sitting_capacity" binding:"omitempty,gte=1,lte=100"`
+	Type            *string    `json:"type" binding:"omitempty,vehicletype"`
+	Color           *string    `json:"color" binding:"omitempty,max=30"`
+	VIN             *string    `json:"vin" binding:"required,vin"`
+	CurrentMileage  *int       `json:"current_mileage" binding:"omitempty,gte=0"`
+	LastMaintenance *time.Time `json:"last_maintenance" binding:"omitempty,notfuture"`
 	NextMaintenance *time.Time `json:"next_maintenance"`
-	Status          *string    `gorm:"not null" json:"status"`
-	AssignedDriver  *uint      `json:"assigned_driver"`
-	Notes           *string    `json:"notes"`
+	Notes           *string    `json:"notes" binding:"omitempty,max=1000"`
+}
+
+func (r createVehicleRequest) validate() []apperr.FieldError {
+	return timeOrder("last_maintenance", r.LastMaintenance, "next_maintenance", r.NextMaintenance)
+}
+
+// toModel builds a vehicle in the given status, the driver is only set through AssignVehicle
+func (r createVehicleRequest) toModel(status string) models.Vehicle {
+	return models.Vehicle{
+		Make:            r.Make,
+		CarModel:        r.CarModel,
+		Year:            r.Year,
+		LicensePlate:    r.LicensePlate,
+		SittingCapacity: r.SittingCapacity,
+		Type:            r.Type,
+		Color:           r.Color,
+		VIN:             upperString(r.VIN),
+		CurrentMileage:  r.CurrentMileage,
+		LastMaintenance: r.LastMaintenance,
+		NextMaintenance: r.NextMaintenance,
+		Status:          &status,
+		Notes:           r.Notes,
+	}
+}
+
+// updateVehicleRequest changes only the fields that are present
+type updateVehicleRequest struct {
+	Make            *string    `json:"make" binding:"omitempty,max=50"`
+	CarModel        *string    `json:"car_model" binding:"omitempty,max=50"`
+	Year            *int       `json:"year" binding:"omitempty,gte=1900,maxyear"`
+	LicensePlate    *string    `json:"license_plate" binding:"omitempty,min=1,max=20"`
+	SittingCapacity *int       `json:"sitting_capacity" binding:"omitempty,gte=1,lte=100"`
+	Type            *string    `json:"type" binding:"omitempty,vehicletype"`
+	Color           *string    `json:"color" binding:"omitempty,max=30"`
+	VIN             *string    `json:"vin" binding:"omitempty,vin"`
+	CurrentMileage  *int       `json:"current_mileage" binding:"omitempty,gte=0"`
+	LastMaintenance *time.Time `json:"last_maintenance" binding:"omitempty,notfuture"`
+	NextMaintenance *time.Time `json:"next_maintenance"`
+	Status          *string    `json:"status" binding:"omitempty,oneof=Active Inactive Maintenance Pending"`
+	Notes           *string    `json:"notes" binding:"omitempty,max=1000"`
+}
+
+func (r updateVehicleRequest) apply(vehicle *models.Vehicle) {
+	setIfPresent(&vehicle.Make, r.Make)
+	setIfPresent(&vehicle.CarModel, r.CarModel)
+	setIfPresent(&vehicle.Year, r.Year)
+	setIfPresent(&vehicle.LicensePlate, r.LicensePlate)
+	setIfPresent(&vehicle.SittingCapacity, r.SittingCapacity)
+	setIfPresent(&vehicle.Type, r.Type)
+	setIfPresent(&vehicle.Color, r.Color)
+	setIfPresent(&vehicle.VIN, upperString(r.VIN))
+	setIfPresent(&vehicle.CurrentMileage, r.CurrentMileage)
+	setIfPresent(&vehicle.LastMaintenance, r.LastMaintenance)
+	setIfPresent(&vehicle.NextMaintenance, r.NextMaintenance)
+	setIfPresent(&vehicle.Status, r.Status)
+	setIfPresent(&vehicle.Notes, r.Notes)
 }
 
-type createVehicleResponse struct {
-	ID              uint       `gorm:"not null" json:"ID"`
+type vehicleResponse struct {
+	ID              uint       `json:"ID"`
 	Make            *string    `json:"make"`
 	CarModel        *string    `json:"car_model"`
 	Year            *int       `json:"year"`
-	LicensePlate    *string    `gorm:"not null" json:"license_plate"`
+	LicensePlate    *string    `json:"license_plate"`
 	SittingCapacity *int       `json:"sitting_capacity"`
 	Type            *string    `json:"type"`
 	Color           *string    `json:"color"`
-	VIN             *string    `gorm:"not null" json:"vin"`
+	VIN             *string    `json:"vin"`
 	CurrentMileage  *int       `json:"current_mileage"`
 	LastMaintenance *time.Time `json:"last_maintenance"`
 	NextMaintenance *time.Time `json:"next_maintenance"`
-	Status          *string    `gorm:"not null" json:"status"`
+	Status          *string    `json:"status"`
 	AssignedDriver  *uint      `json:"assigned_driver"`
 	Notes           *string    `json:"notes"`
 }
 
+func newVehicleResponse(vehicle models.Vehicle) vehicleResponse {
+	return vehicleResponse{
+		ID:              vehicle.ID,
+		Make:            vehicle.Make,
+		CarModel:        vehicle.CarModel,
+		Year:            vehicle.Year,
+		LicensePlate:    vehicle.LicensePlate,
+		SittingCapacity: vehicle.SittingCapacity,
+		Type:            vehicle.Type,
+		Color:           vehicle.Color,
+		VIN:             vehicle.VIN,
+		CurrentMileage:  vehicle.CurrentMileage,
+		LastMaintenance: vehicle.LastMaintenance,
+		NextMaintenance: vehicle.NextMaintenance,
+		Status:          vehicle.Status,
+		AssignedDriver:  vehicle.AssignedDriver,
+		Notes:           vehicle.Notes,
+	}
+}
+
+func newVehicleResponses(vehicles []models.Vehicle) []vehicleResponse {
+	response := make([]vehicleResponse, len(vehicles))
+	for i, vehicle := range vehicles {
+		response[i] = newVehicleResponse(vehicle)
+	}
+	return response
+}
+
 // CreateVehicle godoc
 // @Summary Create a vehicle
 // @Description Create a vehicle
@@ -52,44 +137,26 @@ type createVehicleResponse struct {
 // @Accept  json
 // @Produce  json
 // @Param vehicle body createVehicleRequest true "Vehicle"
-// @Success 200 {object} createVehicleResponse{}
+// @Success 200 {object} vehicleResponse{}
 // @Router /vehicle [post]
 // @Security ApiKeyAuth
 func (s *Server) CreateVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can create vehicles")))
+		c.Error(apperr.Forbidden("only admins can create vehicles"))
 		return
 	}
-	var vehicle models.Vehicle
-	if err := c.ShouldBindJSON(&vehicle); err != nil {
-		c.JSON(400, errorResponse(err))
+	var req createVehicleRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	temp := "Active"
-	vehicle.Status = &temp
-	if err := s.DB.Create(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	vehicle := req.toModel("Active")
+	if err := s.store.Vehicles().Create(c.Request.Context(), &vehicle); err != nil {
+		c.Error(err)
 		return
 	}
-	createVehicleResponse := createVehicleResponse{
-		ID:              vehicle.ID,
-		Make:            vehicle.Make,
-		CarModel:        vehicle.CarModel,
-		Year:            vehicle.Year,
-		LicensePlate:    vehicle.LicensePlate,
-		SittingCapacity: vehicle.SittingCapacity,
-		Type:            vehicle.Type,
-		Color:           vehicle.Color,
-		VIN:             vehicle.VIN,
-		CurrentMileage:  vehicle.CurrentMileage,
-		LastMaintenance: vehicle.LastMaintenance,
-		NextMaintenance: vehicle.NextMaintenance,
-		Status:          vehicle.Status,
-		AssignedDriver:  vehicle.AssignedDriver,
-		Notes:           vehicle.Notes,
-	}
-	c.JSON(200, createVehicleResponse)
+	c.JSON(200, newVehicleResponse(vehicle))
 }
 
 // GetVehicles godoc
@@ -97,34 +164,32 @@ func (s *Server) CreateVehicle(c *gin.Context) {
 // @Description Get all vehicles
 // @Tags vehicle
 // @Produce  json
-// @Success 200 {array} []createVehicleResponse{}
+// @Success 200 {array} vehicleResponse{}
 // @Router /vehicle [get]
 // @Security ApiKeyAuth
 func (s *Server) GetVehicles(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	var vehicles []models.Vehicle
 	if authPayload.Role == "Driver" {
-		fmt.Println("Here")
-		var user models.User
-		username := authPayload.Username
-		if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		userID := user.ID
-		if err := s.DB.Where("assigned_driver = ?", userID).Find(&vehicles).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		vehicles, err := s.store.Vehicles().ListByDriver(c.Request.Context(), user.ID)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		c.JSON(200, vehicles)
+		c.JSON(200, newVehicleResponses(vehicles))
 		return
 	}
 
-	if err := s.DB.Find(&vehicles).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	vehicles, err := s.store.Vehicles().List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, vehicles)
+	c.JSON(200, newVehicleResponses(vehicles))
 }
 
 // GetVehicle godoc
@@ -133,36 +198,28 @@ func (s *Server) GetVehicles(c *gin.Context) {
 // @Tags vehicle
 // @Produce  json
 // @Param id path int true "Vehicle ID"
-// @Success 200 {object} createVehicleResponse{}
+// @Success 200 {object} vehicleResponse{}
 // @Router /vehicle/{id} [get]
 // @Security ApiKeyAuth
 func (s *Server) GetVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
-	var vehicle models.Vehicle
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	if authPayload.Role == "Driver" {
-		var user models.User
-		username := authPayload.Username
-		if err := s.DB.Where("username = ?", username).First(&user).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		user, err := s.currentUser(c)
+		if err != nil {
+			c.Error(err)
 			return
 		}
-		if err := s.DB.First(&vehicle, c.Param("id")).Error; err != nil {
-			c.JSON(400, errorResponse(err))
+		if vehicle.AssignedDriver == nil || *vehicle.AssignedDriver != user.ID {
+			c.Error(apperr.NotFound("driver has no assigned vehicles"))
 			return
 		}
-		if vehicle.AssignedDriver == nil || vehicle.AssignedDriver != &user.ID {
-			c.JSON(404, errorResponse(errors.New("driver has no assigned vehicles")))
-		}
-
-		c.JSON(200, vehicle)
-		return
 	}
-
-	if err := s.DB.First(&vehicle, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
-		return
-	}
-	c.JSON(200, vehicle)
+	c.JSON(200, newVehicleResponse(vehicle))
 }
 
 // UpdateVehicle godoc
@@ -172,30 +229,43 @@ func (s *Server) GetVehicle(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Vehicle ID"
-// @Param vehicle body createVehicleRequest true "Vehicle"
-// @Success 200 {object} createVehicleResponse{}
+// @Param vehicle body updateVehicleRequest true "Fields to change"
+// @Success 200 {object} vehicleResponse{}
 // @Router /vehicle/{id} [put]
 // @Security ApiKeyAuth
 func (s *Server) UpdateVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can update vehicles")))
+		c.Error(apperr.Forbidden("only admins can update vehicles"))
+		return
+	}
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	var vehicle models.Vehicle
-	if err := s.DB.First(&vehicle, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	var req updateVehicleRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	if err := c.ShouldBindJSON(&vehicle); err != nil {
-		c.JSON(400, errorResponse(err))
+	previousStatus := vehicle.Status
+	req.apply(&vehicle)
+	if fields := timeOrder("last_maintenance", vehicle.LastMaintenance, "next_maintenance", vehicle.NextMaintenance); fields != nil {
+		c.Error(apperr.Validation(fields...))
 		return
 	}
-	if err := s.DB.Save(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	err = s.storeTransaction(c.Request.Context(), func(tx store.Store) error {
+		if err := tx.Vehicles().Update(c.Request.Context(), &vehicle); err != nil {
+			return err
+		}
+		return s.publishVehicleStatus(c.Request.Context(), tx, vehicle, previousStatus)
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, vehicle)
+	c.JSON(200, newVehicleResponse(vehicle))
 }
 
 // DeleteVehicle godoc
@@ -208,62 +278,41 @@ func (s *Server) UpdateVehicle(c *gin.Context) {
 // @Router /vehicle/{id} [delete]
 // @Security ApiKeyAuth
 func (s *Server) DeleteVehicle(c *gin.Context) {
-	var vehicle models.Vehicle
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can delete vehicles")))
-		return
-	}
-	if err := s.DB.First(&vehicle, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+		c.Error(apperr.Forbidden("only admins can delete vehicles"))
 		return
 	}
-	if err := s.DB.Delete(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := s.store.Vehicles().Delete(c.Request.Context(), idParam(c, "id")); err != nil {
+		c.Error(err)
 		return
 	}
 	c.JSON(200, gin.H{})
 }
 
-type registerVehicleRequest struct {
-	Make            *string    `json:"make"`
-	CarModel        *string    `json:"car_model"`
-	Year            *int       `json:"year"`
-	LicensePlate    *string    `gorm:"not null" json:"license_plate"`
-	SittingCapacity *int       `json:"sitting_capacity"`
-	Type            *string    `json:"type"`
-	Color           *string    `json:"color"`
-	VIN             *string    `gorm:"not null" json:"vin"`
-	CurrentMileage  *int       `json:"current_mileage"`
-	LastMaintenance *time.Time `json:"last_maintenance"`
-	NextMaintenance *time.Time `json:"next_maintenance"`
-	AssignedDriver  *uint      `json:"assigned_driver"`
-	Notes           *string    `json:"notes"`
-}
-
 // RegisterVehicle godoc
 // @Summary Register a vehicle
 // @Description Register a vehicle
 // @Tags vehicle
 // @Accept  json
 // @Produce  json
-// @Param vehicle body registerVehicleRequest true "Vehicle"
-// @Success 200 {object} createVehicleResponse{}
+// @Param vehicle body createVehicleRequest true "Vehicle"
+// @Success 200 {object} vehicleResponse{}
 // @Router /vehicle/register [post]
 // @Security ApiKeyAuth
 func (s *Server) RegisterVehicle(c *gin.Context) {
-	var vehicle models.Vehicle
-	if err := c.ShouldBindJSON(&vehicle); err != nil {
-		c.JSON(400, errorResponse(err))
+	var req createVehicleRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	temp := "Pending"
-	vehicle.Status = &temp
-	if err := s.DB.Create(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	// registered vehicles wait for an admin to activate them
+	vehicle := req.toModel("Pending")
+	if err := s.store.Vehicles().Create(c.Request.Context(), &vehicle); err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, vehicle)
+	c.JSON(200, newVehicleResponse(vehicle))
 }
 
 // ActivateVehicle godoc
@@ -272,33 +321,40 @@ func (s *Server) RegisterVehicle(c *gin.Context) {
 // @Tags vehicle
 // @Produce  json
 // @Param id path int true "Vehicle ID"
-// @Success 200 {array} createVehicleResponse{}
+// @Success 200 {object} vehicleResponse{}
 // @Router /vehicle/{id} [post]
 // @Security ApiKeyAuth
 func (s *Server) ActivateVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can activate vehicles")))
+		c.Error(apperr.Forbidden("only admins can activate vehicles"))
 		return
 	}
-	var vehicle models.Vehicle
-	if err := s.DB.First(&vehicle, c.Param("id")).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	vehicle, err := s.store.Vehicles().Get(c.Request.Context(), idParam(c, "id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	previousStatus := vehicle.Status
 	temp := "Active"
 	vehicle.Status = &temp
-	if err := s.DB.Save(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	err = s.storeTransaction(c.Request.Context(), func(tx store.Store) error {
+		if err := tx.Vehicles().Update(c.Request.Context(), &vehicle); err != nil {
+			return err
+		}
+		return s.publishVehicleStatus(c.Request.Context(), tx, vehicle, previousStatus)
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(200, vehicle)
+	c.JSON(200, newVehicleResponse(vehicle))
 }
 
 type assignVehicleRequest struct {
-	UserID    uint `json:"user_id"`
-	VehicleID uint `json:"vehicle_id"`
+	UserID    uint `json:"user_id" binding:"required"`
+	VehicleID uint `json:"vehicle_id" binding:"required"`
 }
 
 // AssignVehicle godoc
@@ -308,45 +364,56 @@ type assignVehicleRequest struct {
 // @Accept  json
 // @Produce  json
 // @Param vehicle body assignVehicleRequest true "Vehicle"
-// @Success 200 {array} userResponse{}
+// @Success 200 {object} userResponse{}
 // @Router /vehicle/assign [post]
 // @Security ApiKeyAuth
 func (s *Server) AssignVehicle(c *gin.Context) {
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can assign vehicles")))
+		c.Error(apperr.Forbidden("only admins can assign vehicles"))
 		return
 	}
 	var req assignVehicleRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	var user models.User
-	if err := s.DB.First(&user, req.UserID).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	ctx := c.Request.Context()
+	user, err := s.store.Users().Get(ctx, req.UserID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	var vehicle models.Vehicle
-	if err := s.DB.Where("id = ?", req.VehicleID).First(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	vehicle, err := s.store.Vehicles().Get(ctx, req.VehicleID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 	if vehicle.AssignedDriver != nil {
-		c.JSON(400, errorResponse(errors.New("vehicle already assigned to a driver")))
+		c.Error(apperr.Conflict("vehicle already assigned to a driver"))
 		return
 	}
-	vehicle.AssignedDriver = &user.ID
-	if err := s.DB.Save(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := s.checkDriverQualified(c.Request.Context(), user.ID, &vehicle, time.Now()); err != nil {
+		c.Error(err)
 		return
 	}
-	user.Vehicles = append(user.Vehicles, vehicle)
-	if err := s.DB.Save(&user).Error; err != nil {
-		c.JSON(400, errorResponse(err))
-		return
-	}
-	userResponse := newUserResonse(user)
+	err = s.storeTransaction(ctx, func(tx store.Store) error {
+		assignment, err := tx.Vehicles().Assign(ctx, &vehicle, user.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		return tx.WriteEvent(ctx, events.Event{
+			Type:      events.VehicleAssigned,
+			VehicleID: &vehicle.ID,
+			DriverID:  &user.ID,
+			Data:      gin.H{"vehicle_id": vehicle.ID, "driver_id": user.ID, "assignment_id": assignment.ID},
+		})
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	userResponse := newUserResponse(user, canSeePersonalData(authPayload, user))
 	c.JSON(200, userResponse)
 }
 
@@ -357,51 +424,71 @@ func (s *Server) AssignVehicle(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param vehicle body assignVehicleRequest true "Vehicle"
-// @Success 200 {array} userResponse{}
+// @Success 200 {object} userResponse{}
 // @Router /vehicle/unassign [post]
 // @Security ApiKeyAuth
 func (s *Server) UnassignVehicle(c *gin.Context) {
 
 	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Role != "Admin" {
-		c.JSON(400, errorResponse(errors.New("only admins can unassign vehicles")))
+		c.Error(apperr.Forbidden("only admins can unassign vehicles"))
 		return
 	}
 	var req assignVehicleRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, errorResponse(err))
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
-	var user models.User
-	if err := s.DB.First(&user, req.UserID).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	ctx := c.Request.Context()
+	user, err := s.store.Users().Get(ctx, req.UserID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	var vehicle models.Vehicle
-	if err := s.DB.Where("id = ?", req.VehicleID).First(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
+	vehicle, err := s.store.Vehicles().Get(ctx, req.VehicleID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 	if vehicle.AssignedDriver == nil {
-		c.JSON(400, errorResponse(errors.New("vehicle is not assigned to a driver")))
+		c.Error(apperr.Conflict("vehicle is not assigned to a driver"))
 		return
 	}
-	vehicle.AssignedDriver = nil
-	if err := s.DB.Save(&vehicle).Error; err != nil {
-		c.JSON(400, errorResponse(err))
-		return
-	}
-	var vehicles []models.Vehicle
-	for _, v := range user.Vehicles {
-		if v.ID != vehicle.ID {
-			vehicles = append(vehicles, v)
+	err = s.storeTransaction(ctx, func(tx store.Store) error {
+		if err := tx.Vehicles().Unassign(ctx, &vehicle, time.Now()); err != nil {
+			return err
 		}
+		return tx.WriteEvent(ctx, events.Event{
+			Type:      events.VehicleUnassigned,
+			VehicleID: &vehicle.ID,
+			DriverID:  &user.ID,
+			Data:      gin.H{"vehicle_id": vehicle.ID, "driver_id": user.ID},
+		})
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response := newUserResponse(user, canSeePersonalData(authPayload, user))
+	c.JSON(200, response)
+}
+
+// driverAssignedAt returns the driver the vehicle was assigned to at the given time, or nil if there was none
+func (s *Server) driverAssignedAt(ctx context.Context, vehicle models.Vehicle, at time.Time) (*uint, error) {
+	assignment, err := s.store.Vehicles().AssignmentAt(ctx, vehicle.ID, at)
+	if err == nil {
+		return assignment.DriverID, nil
 	}
-	user.Vehicles = vehicles
-	if err := s.DB.Save(&user).Error; err != nil {
-		c.JSON(400, errorResponse(err))
-		return
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
 	}
-	response := newUserResonse(user)
-	c.JSON(200, response)
+	// vehicles assigned before the history was kept only know their current driver
+	count, err := s.store.Vehicles().CountAssignments(ctx, vehicle.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return vehicle.AssignedDriver, nil
+	}
+	return nil, nil
 }
@@ -0,0 +1,114 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+	"github.com/rassulmagauin/VMS_SWE/events"
+)
+
+func TestCreateVehicleRequiresAdmin(t *testing.T) {
+	h := apitest.NewMemory(t)
+	body := map[string]interface{}{"license_plate": "777AAA02", "vin": "1hgcm82633a004352", "make": "Toyota", "year": 2020}
+
+	if code := h.As(apitest.RoleDriver).Post("/vehicle", body, nil); code != http.StatusForbidden {
+		t.Errorf("driver status = %d, want 403", code)
+	}
+	var created api.VehicleResponse
+	if code := h.As(apitest.RoleAdmin).Post("/vehicle", body, &created); code != http.StatusOK {
+		t.Fatalf("admin status = %d, want 200", code)
+	}
+	if created.Status == nil || *created.Status != "Active" {
+		t.Errorf("status = %v, want Active", created.Status)
+	}
+
+	stored, err := h.Store.Vehicles().Get(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("vehicle was not stored: %v", err)
+	}
+	if *stored.VIN != "1HGCM82633A004352" {
+		t.Errorf("stored VIN = %s, want it upper-cased", *stored.VIN)
+	}
+}
+
+func TestDriversOnlySeeTheirVehicles(t *testing.T) {
+	h := apitest.NewMemory(t)
+	driver, driverID := h.As(apitest.RoleDriver), h.User(apitest.RoleDriver).ID
+	other := h.AddUser("other", apitest.RoleDriver)
+	own := addVehicle(t, h, "Active", &driverID)
+	foreign := addVehicle(t, h, "Active", &other.ID)
+
+	var vehicles []api.VehicleResponse
+	if code := driver.Get("/vehicle", &vehicles); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != own.ID {
+		t.Errorf("vehicles = %+v, want only vehicle %d", vehicles, own.ID)
+	}
+
+	if code := driver.Get(apitest.Path("/vehicle/:id", own.ID), nil); code != http.StatusOK {
+		t.Errorf("own vehicle status = %d, want 200", code)
+	}
+	if code := driver.Get(apitest.Path("/vehicle/:id", foreign.ID), nil); code != http.StatusNotFound {
+		t.Errorf("foreign vehicle status = %d, want 404", code)
+	}
+}
+
+func TestActivateVehicleEmitsStatusChange(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin := h.As(apitest.RoleAdmin)
+	vehicle := addVehicle(t, h, "Pending", nil)
+	path := apitest.Path("/vehicle/:id", vehicle.ID)
+
+	var activated api.VehicleResponse
+	if code := admin.Post(path, nil, &activated); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if *activated.Status != "Active" {
+		t.Errorf("status = %s, want Active", *activated.Status)
+	}
+	if got, want := h.EventTypes(), []string{events.VehicleStatusChanged}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	// activating again changes nothing and announces nothing
+	admin.Post(path, nil, nil)
+	if got := len(h.EventTypes()); got != 1 {
+		t.Errorf("%d events after the second activation, want 1", got)
+	}
+}
+
+func TestUnassignVehicleClosesAssignment(t *testing.T) {
+	h := apitest.NewMemory(t)
+	admin := h.As(apitest.RoleAdmin)
+	driver := h.User(apitest.RoleDriver)
+	vehicles := h.Store.Vehicles()
+	vehicle := addVehicle(t, h, "Active", nil)
+	ctx := context.Background()
+	if _, err := vehicles.Assign(ctx, &vehicle, driver.ID, vehicle.CreatedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	body := api.AssignVehicleRequest{UserID: driver.ID, VehicleID: vehicle.ID}
+	if code := admin.Post("/vehicle/unassign", body, nil); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	stored, _ := vehicles.Get(ctx, vehicle.ID)
+	if stored.AssignedDriver != nil {
+		t.Errorf("assigned driver = %d, want none", *stored.AssignedDriver)
+	}
+	if _, err := vehicles.AssignmentAt(ctx, vehicle.ID, stored.UpdatedAt.Add(1)); err == nil {
+		t.Error("the assignment is still open")
+	}
+	if got, want := h.EventTypes(), []string{events.VehicleUnassigned}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	if code := admin.Post("/vehicle/unassign", body, nil); code != http.StatusConflict {
+		t.Errorf("second unassign status = %d, want 409", code)
+	}
+}
@@ -0,0 +1,490 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rassulmagauin/VMS_SWE/apperr"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/scheduler"
+	"github.com/rassulmagauin/VMS_SWE/token"
+	"github.com/rassulmagauin/VMS_SWE/webhook"
+)
+
+const (
+	webhookDeliveryPending   = "pending"
+	webhookDeliverySucceeded = "succeeded"
+	webhookDeliveryFailed    = "failed"
+
+	webhookDeliveryInterval = 10 * time.Second
+	webhookDeliveryBatch    = 100
+)
+
+// webhookCategories are the event categories external systems may subscribe to
+var webhookCategories = []string{"vehicle", "task", "fueling", "maintenance", "auction"}
+
+// webhookEventTypes are the event types of webhookCategories that handlers publish
+var webhookEventTypes = []string{
+	events.VehicleStatusChanged, events.VehicleAssigned, events.VehicleUnassigned,
+	events.TaskStatusChanged, events.TaskAssigned, events.WaypointArrived,
+	events.FuelingCreated,
+	events.MaintenanceCreated, events.MaintenanceDue,
+	events.AuctionCreated, events.AuctionClosed,
+}
+
+type webhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types"`
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	Active      *bool    `json:"active"`
+}
+
+type webhookEndpointResponse struct {
+	ID          uint      `json:"ID"`
+	URL         string    `json:"url"`
+	EventTypes  []string  `json:"event_types"`
+	Description *string   `json:"description"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"` // only returned when the endpoint is created
+	CreatedAt   time.Time `json:"CreatedAt"`
+}
+
+type webhookDeliveryResponse struct {
+	ID            uint       `json:"ID"`
+	EndpointID    *uint      `json:"endpoint_id"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	ResponseCode  *int       `json:"response_code"`
+	LastError     *string    `json:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"CreatedAt"`
+}
+
+func newWebhookEndpointResponse(endpoint models.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:          endpoint.ID,
+		URL:         endpoint.URL,
+		EventTypes:  endpoint.EventTypes,
+		Description: endpoint.Description,
+		Active:      endpoint.Active == nil || *endpoint.Active,
+		CreatedAt:   endpoint.CreatedAt,
+	}
+}
+
+func newWebhookDeliveryResponse(delivery models.WebhookDelivery) webhookDeliveryResponse {
+	return webhookDeliveryResponse{
+		ID:            delivery.ID,
+		EndpointID:    delivery.EndpointID,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		ResponseCode:  delivery.ResponseCode,
+		LastError:     delivery.LastError,
+		NextAttemptAt: delivery.NextAttemptAt,
+		DeliveredAt:   delivery.DeliveredAt,
+		CreatedAt:     delivery.CreatedAt,
+	}
+}
+
+func (r webhookEndpointRequest) validate() []apperr.FieldError {
+	var fields []apperr.FieldError
+	target, err := url.Parse(r.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		fields = append(fields, apperr.FieldError{Field: "url", Message: "url must be an absolute http or https URL"})
+	}
+	if len(r.EventTypes) == 0 {
+		fields = append(fields, apperr.FieldError{Field: "event_types", Message: "at least one event type is required"})
+	}
+	for i, eventType := range r.EventTypes {
+		if !webhookPatternAllowed(eventType) {
+			fields = append(fields, apperr.FieldError{
+				Field:   fmt.Sprintf("event_types[%d]", i),
+				Message: fmt.Sprintf("unsupported event type %q, use *, one of %s.* or one of %s", eventType, strings.Join(webhookCategories, ".*, "), strings.Join(webhookEventTypes, ", ")),
+			})
+		}
+	}
+	return fields
+}
+
+// webhookPatternAllowed reports whether an endpoint may subscribe to the pattern, which is "*",
+// "<category>.*" of one of webhookCategories or one of webhookEventTypes
+func webhookPatternAllowed(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if category, ok := strings.CutSuffix(pattern, ".*"); ok {
+		return webhookCategoryAllowed(category)
+	}
+	return slices.Contains(webhookEventTypes, pattern)
+}
+
+func webhookCategoryAllowed(category string) bool {
+	for _, allowed := range webhookCategories {
+		if category == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// queueWebhookDeliveries records a pending delivery for every active endpoint subscribed to the event.
+// It is an outbox subscriber, an event seen again does not queue a second delivery to the same endpoint.
+func (s *Server) queueWebhookDeliveries(evt events.Event) error {
+	if !webhookCategoryAllowed(evt.Category()) {
+		return nil
+	}
+	var endpoints []models.WebhookEndpoint
+	if err := s.DB.Where("active = ?", true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+	var payload []byte
+	now := time.Now()
+	for _, endpoint := range endpoints {
+		matched := false
+		for _, pattern := range endpoint.EventTypes {
+			if webhook.Matches(pattern, evt.Type) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if evt.ID != nil {
+			var count int64
+			if err := s.DB.Model(&models.WebhookDelivery{}).
+				Where("endpoint_id = ? AND event_id = ?", endpoint.ID, *evt.ID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(evt); err != nil {
+				return err
+			}
+		}
+		endpointID := endpoint.ID
+		delivery := models.WebhookDelivery{
+			EndpointID:    &endpointID,
+			EventID:       evt.ID,
+			EventType:     evt.Type,
+			Payload:       string(payload),
+			Status:        webhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := s.DB.Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverWebhooks attempts every pending delivery that is due, it is idle when none is
+func (s *Server) deliverWebhooks(ctx context.Context) error {
+	var deliveries []models.WebhookDelivery
+	if err := s.DB.WithContext(ctx).Preload("Endpoint").
+		Where("status = ? AND next_attempt_at <= ?", webhookDeliveryPending, time.Now()).
+		Order("next_attempt_at").Limit(webhookDeliveryBatch).
+		Find(&deliveries).Error; err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		return scheduler.ErrIdle
+	}
+	var failures []error
+	for i := range deliveries {
+		if err := s.attemptWebhookDelivery(ctx, &deliveries[i]); err != nil {
+			failures = append(failures, fmt.Errorf("delivery %d: %w", deliveries[i].ID, err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// attemptWebhookDelivery sends the delivery once and schedules a retry with exponential backoff if it fails
+func (s *Server) attemptWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if delivery.Endpoint == nil {
+		message := "endpoint was deleted"
+		delivery.Status = webhookDeliveryFailed
+		delivery.LastError = &message
+		delivery.NextAttemptAt = nil
+		return s.DB.WithContext(ctx).Save(delivery).Error
+	}
+	code, err := s.webhooks.Send(ctx, delivery.Endpoint.URL, delivery.Endpoint.Secret, delivery.ID, delivery.EventType, []byte(delivery.Payload))
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseCode = nil
+	if code != 0 {
+		delivery.ResponseCode = &code
+	}
+	switch {
+	case err == nil:
+		delivery.Status = webhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = nil
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= webhook.MaxAttempts:
+		message := err.Error()
+		delivery.Status = webhookDeliveryFailed
+		delivery.LastError = &message
+		delivery.NextAttemptAt = nil
+	default:
+		message := err.Error()
+		next := now.Add(webhook.Backoff(delivery.Attempts))
+		delivery.LastError = &message
+		delivery.NextAttemptAt = &next
+	}
+	return s.DB.WithContext(ctx).Omit("Endpoint").Save(delivery).Error
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook endpoint
+// @Description Admins register an endpoint for event types such as task.*, fueling.created or *. The signing secret is only returned here.
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhook body webhookEndpointRequest true "Webhook endpoint"
+// @Success 200 {object} webhookEndpointResponse{}
+// @Router /webhooks [post]
+// @Security ApiKeyAuth
+func (s *Server) CreateWebhook(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can register webhooks"))
+		return
+	}
+	var req webhookEndpointRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	endpoint := models.WebhookEndpoint{
+		URL:         req.URL,
+		Secret:      secret,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+		Active:      req.Active,
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Create(&endpoint).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := newWebhookEndpointResponse(endpoint)
+	response.Secret = secret
+	c.JSON(200, response)
+}
+
+// GetWebhooks godoc
+// @Summary Get webhook endpoints
+// @Description Get all registered webhook endpoints
+// @Tags webhook
+// @Produce json
+// @Success 200 {array} []webhookEndpointResponse{}
+// @Router /webhooks [get]
+// @Security ApiKeyAuth
+func (s *Server) GetWebhooks(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get webhooks"))
+		return
+	}
+	var endpoints []models.WebhookEndpoint
+	if err := s.DB.WithContext(c.Request.Context()).Order("id").Find(&endpoints).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]webhookEndpointResponse, len(endpoints))
+	for i, endpoint := range endpoints {
+		response[i] = newWebhookEndpointResponse(endpoint)
+	}
+	c.JSON(200, response)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook endpoint
+// @Description Get a webhook endpoint
+// @Tags webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} webhookEndpointResponse{}
+// @Router /webhooks/{id} [get]
+// @Security ApiKeyAuth
+func (s *Server) GetWebhook(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get webhooks"))
+		return
+	}
+	var endpoint models.WebhookEndpoint
+	if err := s.DB.WithContext(c.Request.Context()).First(&endpoint, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newWebhookEndpointResponse(endpoint))
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook endpoint
+// @Description Change the URL, event types or active flag of a webhook endpoint
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param webhook body webhookEndpointRequest true "Webhook endpoint"
+// @Success 200 {object} webhookEndpointResponse{}
+// @Router /webhooks/{id} [put]
+// @Security ApiKeyAuth
+func (s *Server) UpdateWebhook(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can update webhooks"))
+		return
+	}
+	var endpoint models.WebhookEndpoint
+	if err := s.DB.WithContext(c.Request.Context()).First(&endpoint, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	req := webhookEndpointRequest{URL: endpoint.URL, EventTypes: endpoint.EventTypes, Description: endpoint.Description, Active: endpoint.Active}
+	if err := bindJSON(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	endpoint.URL = req.URL
+	endpoint.EventTypes = req.EventTypes
+	endpoint.Description = req.Description
+	endpoint.Active = req.Active
+	if err := s.DB.WithContext(c.Request.Context()).Save(&endpoint).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newWebhookEndpointResponse(endpoint))
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook endpoint
+// @Description Delete a webhook endpoint, pending deliveries to it are dropped
+// @Tags webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} webhookEndpointResponse{}
+// @Router /webhooks/{id} [delete]
+// @Security ApiKeyAuth
+func (s *Server) DeleteWebhook(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can delete webhooks"))
+		return
+	}
+	var endpoint models.WebhookEndpoint
+	if err := s.DB.WithContext(c.Request.Context()).First(&endpoint, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Delete(&endpoint).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newWebhookEndpointResponse(endpoint))
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Get the delivery log of a webhook endpoint
+// @Description Returns deliveries to the endpoint, newest first
+// @Tags webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "pending, succeeded or failed"
+// @Success 200 {array} []webhookDeliveryResponse{}
+// @Router /webhooks/{id}/deliveries [get]
+// @Security ApiKeyAuth
+func (s *Server) GetWebhookDeliveries(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can get webhook deliveries"))
+		return
+	}
+	var endpoint models.WebhookEndpoint
+	if err := s.DB.WithContext(c.Request.Context()).First(&endpoint, c.Param("id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	query := s.DB.WithContext(c.Request.Context()).Where("endpoint_id = ?", endpoint.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(200).Find(&deliveries).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	response := make([]webhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		response[i] = newWebhookDeliveryResponse(delivery)
+	}
+	c.JSON(200, response)
+}
+
+// RedeliverWebhook godoc
+// @Summary Redeliver a webhook
+// @Description Sends the payload of a past delivery again as a new delivery and returns its outcome
+// @Tags webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} webhookDeliveryResponse{}
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+// @Security ApiKeyAuth
+func (s *Server) RedeliverWebhook(c *gin.Context) {
+	authPayload := c.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != "Admin" {
+		c.Error(apperr.Forbidden("only admins can redeliver webhooks"))
+		return
+	}
+	var original models.WebhookDelivery
+	if err := s.DB.WithContext(c.Request.Context()).Preload("Endpoint").
+		Where("endpoint_id = ?", c.Param("id")).
+		First(&original, c.Param("delivery_id")).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        webhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	if err := s.DB.WithContext(c.Request.Context()).Create(&delivery).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	delivery.Endpoint = original.Endpoint
+	if err := s.attemptWebhookDelivery(c.Request.Context(), &delivery); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(200, newWebhookDeliveryResponse(delivery))
+}
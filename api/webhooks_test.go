@@ -0,0 +1,35 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+)
+
+func TestWebhookEventTypes(t *testing.T) {
+	// the webhook handlers still query the database
+	h := apitest.New(t)
+	admin := h.As(apitest.RoleAdmin)
+
+	tests := []struct {
+		eventType string
+		want      int
+	}{
+		{"*", http.StatusOK},
+		{"task.*", http.StatusOK},
+		{"task.status_changed", http.StatusOK},
+		{"auction.closed", http.StatusOK},
+		{"task.bogus", http.StatusUnprocessableEntity},
+		{"task", http.StatusUnprocessableEntity},
+		{"tracking.ping", http.StatusUnprocessableEntity},
+		{"tracking.*", http.StatusUnprocessableEntity},
+		{"*.created", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		body := map[string]interface{}{"url": "https://hooks.example.com/vms", "event_types": []string{tt.eventType}}
+		if code := admin.Post("/webhooks", body, nil); code != tt.want {
+			t.Errorf("subscribe to %q: status = %d, want %d", tt.eventType, code, tt.want)
+		}
+	}
+}
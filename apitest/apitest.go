@@ -0,0 +1,305 @@
+// Package apitest runs the whole server, as built by api.NewServer, against a throwaway SQLite
+// database so end-to-end tests can call any route of the router. NewMemory runs it on the
+// in-memory store instead, for the routes whose handlers use only the store.
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/config"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/store"
+	"github.com/rassulmagauin/VMS_SWE/tracing"
+	"github.com/rassulmagauin/VMS_SWE/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	RoleAdmin             = "Admin"
+	RoleDriver            = "Driver"
+	RoleFuelingPerson     = "Fueling_person"
+	RoleMaintenancePerson = "Maintenance_person"
+
+	// Password is the password of every user the harness creates
+	Password = "secret"
+)
+
+// Roles lists every role; New seeds one user for each
+var Roles = []string{RoleAdmin, RoleDriver, RoleFuelingPerson, RoleMaintenancePerson}
+
+var (
+	passwordHash     string
+	passwordHashErr  error
+	passwordHashOnce sync.Once
+)
+
+// Harness is a server with its own database. The seeded user of a role is named after the role
+// in lower case, e.g. "fueling_person".
+type Harness struct {
+	Server *api.Server
+	// DB is nil for a harness on the in-memory store
+	DB     *gorm.DB
+	Store  store.Store
+	Config config.Config
+
+	t      testing.TB
+	memory *store.Memory
+	users  map[string]models.User
+	tokens map[string]string
+}
+
+// New creates the harness. configure, if given, adjusts the configuration before the server
+// is built; uploads always go to a temporary directory.
+func New(t testing.TB, configure ...func(*config.Config)) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "vms.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("cannot open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SQLite has a single writer, one connection keeps concurrent requests from failing with SQLITE_BUSY
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := config.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	// like main, so tests installing a tracer provider see the queries
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	return newHarness(t, dir, db, store.NewGorm(db), configure)
+}
+
+// NewMemory creates a harness on store.NewMemory() without a database. Routes whose handlers
+// still query the database fail on it.
+func NewMemory(t testing.TB, configure ...func(*config.Config)) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	memory := store.NewMemory()
+	h := newHarness(t, t.TempDir(), nil, memory, configure)
+	h.memory = memory
+	return h
+}
+
+func newHarness(t testing.TB, dir string, db *gorm.DB, st store.Store, configure []func(*config.Config)) *Harness {
+	t.Helper()
+	cfg := config.Default()
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	cfg.Storage.Dir = filepath.Join(dir, "uploads")
+	if err := os.Mkdir(cfg.Storage.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	server, err := api.NewServerWithStore(cfg, db, st)
+	if err != nil {
+		t.Fatalf("cannot create server: %v", err)
+	}
+	h := &Harness{Server: server, DB: db, Store: st, Config: cfg, t: t, users: make(map[string]models.User), tokens: make(map[string]string)}
+	for _, role := range Roles {
+		h.users[role] = h.AddUser(strings.ToLower(role), role)
+	}
+	return h
+}
+
+// AddUser stores a user with the password Password and the email <username>@example.com
+func (h *Harness) AddUser(username, role string) models.User {
+	h.t.Helper()
+	// bcrypt is slow on purpose, every harness shares one hash
+	passwordHashOnce.Do(func() {
+		passwordHash, passwordHashErr = utils.HashPassword(Password)
+	})
+	if passwordHashErr != nil {
+		h.t.Fatal(passwordHashErr)
+	}
+	hash, name, email, status := passwordHash, username, username+"@example.com", "Active"
+	user := models.User{Username: username, HashedPassword: &hash, Role: &role, FirstName: &name, LastName: &name, Email: &email, Status: &status}
+	if err := h.Store.Users().Create(context.Background(), &user); err != nil {
+		h.t.Fatalf("cannot create user %s: %v", username, err)
+	}
+	return user
+}
+
+// AddLicense stores a license of the user with the comma separated categories, e.g. "B,C",
+// valid until expires. Drivers need one before they can be given vehicles or tasks.
+func (h *Harness) AddLicense(userID uint, categories string, expires time.Time) models.DriverLicense {
+	h.t.Helper()
+	number := fmt.Sprintf("KZ%07d", userID)
+	license := models.DriverLicense{UserID: &userID, Number: &number, Categories: &categories, ExpiresAt: &expires}
+	if err := h.Store.Licenses().Save(context.Background(), &license); err != nil {
+		h.t.Fatalf("cannot create license of user %d: %v", userID, err)
+	}
+	return license
+}
+
+// User returns the seeded user of role
+func (h *Harness) User(role string) models.User {
+	h.t.Helper()
+	user, ok := h.users[role]
+	if !ok {
+		h.t.Fatalf("no user with role %s", role)
+	}
+	return user
+}
+
+// As returns a client logged in as the seeded user of role. The login goes through POST /login
+// once per role.
+func (h *Harness) As(role string) *Client {
+	h.t.Helper()
+	accessToken, ok := h.tokens[role]
+	if !ok {
+		accessToken = h.Login(h.User(role).Username, Password)
+		h.tokens[role] = accessToken
+	}
+	return &Client{h: h, token: accessToken}
+}
+
+// Anonymous returns a client that sends no token
+func (h *Harness) Anonymous() *Client {
+	return &Client{h: h}
+}
+
+// WithToken returns a client that sends accessToken, e.g. one from Login
+func (h *Harness) WithToken(accessToken string) *Client {
+	return &Client{h: h, token: accessToken}
+}
+
+// Login logs in through POST /login and returns the access token, failing the test otherwise
+func (h *Harness) Login(username, password string) string {
+	h.t.Helper()
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	body := map[string]string{"username": username, "password": password}
+	if code := h.Anonymous().Post("/login", body, &response); code != http.StatusOK {
+		h.t.Fatalf("login as %s: status %d", username, code)
+	}
+	return response.AccessToken
+}
+
+// EventTypes returns the types of the events written to the outbox so far, oldest first
+func (h *Harness) EventTypes() []string {
+	h.t.Helper()
+	var types []string
+	if h.memory != nil {
+		for _, evt := range h.memory.Events() {
+			types = append(types, evt.Type)
+		}
+		return types
+	}
+	if err := h.DB.Model(&models.OutboxEvent{}).Order("id").Pluck("type", &types).Error; err != nil {
+		h.t.Fatal(err)
+	}
+	return types
+}
+
+// Client sends requests to the harness server, authenticated unless it is anonymous
+type Client struct {
+	h     *Harness
+	token string
+}
+
+func (c *Client) Get(path string, out interface{}) int {
+	c.h.t.Helper()
+	return c.Do(http.MethodGet, path, nil, out).Code
+}
+
+func (c *Client) Post(path string, body, out interface{}) int {
+	c.h.t.Helper()
+	return c.Do(http.MethodPost, path, body, out).Code
+}
+
+func (c *Client) Put(path string, body, out interface{}) int {
+	c.h.t.Helper()
+	return c.Do(http.MethodPut, path, body, out).Code
+}
+
+func (c *Client) Delete(path string) int {
+	c.h.t.Helper()
+	return c.Do(http.MethodDelete, path, nil, nil).Code
+}
+
+// Do sends body as JSON and, for a successful response, decodes it into out when out is not nil
+func (c *Client) Do(method, path string, body, out interface{}) *httptest.ResponseRecorder {
+	c.h.t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			c.h.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	return c.send(req, out)
+}
+
+// Form sends fields as a multipart form, the way the upload routes expect them, and decodes a
+// successful response into out when out is not nil
+func (c *Client) Form(method, path string, fields map[string]string, out interface{}) *httptest.ResponseRecorder {
+	c.h.t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			c.h.t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		c.h.t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return c.send(req, out)
+}
+
+func (c *Client) send(req *http.Request, out interface{}) *httptest.ResponseRecorder {
+	c.h.t.Helper()
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	recorder := httptest.NewRecorder()
+	c.h.Server.Router.ServeHTTP(recorder, req)
+	if out != nil && recorder.Code < 300 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			c.h.t.Fatalf("cannot decode %s %s response %s: %v", req.Method, req.URL.Path, recorder.Body, err)
+		}
+	}
+	return recorder
+}
+
+// Path fills the parameters of a route path such as "/vehicle/:id" in order
+func Path(route string, params ...interface{}) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") && len(params) > 0 {
+			parts[i] = fmt.Sprint(params[0])
+			params = params[1:]
+		}
+	}
+	return strings.Join(parts, "/")
+}
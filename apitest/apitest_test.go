@@ -0,0 +1,283 @@
+package apitest_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/api"
+	"github.com/rassulmagauin/VMS_SWE/apitest"
+)
+
+// public lists the routes that answer without a token
+var public = map[string]bool{
+	"POST /login":            true,
+	"POST /user":             true,
+	"POST /password/forgot":  true,
+	"POST /password/reset":   true,
+	"GET /auction":           true,
+	"GET /auction/:id":       true,
+	"GET /docs/*any":         true,
+	"GET /metrics":           true,
+	"GET /healthz":           true,
+	"GET /readyz":            true,
+	"GET /static/*filepath":  true,
+	"HEAD /static/*filepath": true,
+}
+
+func TestRoutesRequireLogin(t *testing.T) {
+	h := apitest.New(t)
+	for _, route := range h.Server.Router.Routes() {
+		if public[route.Method+" "+route.Path] {
+			continue
+		}
+		path := strings.ReplaceAll(apitest.Path(route.Path, 1, 1), ":name", "purge")
+		if code := h.Anonymous().Do(route.Method, path, nil, nil).Code; code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a token: status %d, want 401", route.Method, route.Path, code)
+		}
+	}
+}
+
+func TestEveryRoleCanLogIn(t *testing.T) {
+	h := apitest.New(t)
+	for _, role := range apitest.Roles {
+		if code := h.As(role).Get("/notifications", nil); code != http.StatusOK {
+			t.Errorf("%s reading their notifications: status %d", role, code)
+		}
+	}
+	if code := h.As(apitest.RoleDriver).Get("/inspection/template", nil); code != http.StatusOK {
+		t.Errorf("driver listing inspection templates: status %d", code)
+	}
+	if code := h.As(apitest.RoleDriver).Post("/inspection/template", map[string]string{"name": "daily"}, nil); code != http.StatusForbidden {
+		t.Errorf("driver creating an inspection template: status %d, want 403", code)
+	}
+}
+
+func TestVehicleAssignmentAndTask(t *testing.T) {
+	h := apitest.New(t)
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	driverID := h.User(apitest.RoleDriver).ID
+
+	var vehicle struct {
+		ID uint `json:"ID"`
+	}
+	body := map[string]interface{}{"license_plate": "777AAA02", "vin": "1HGCM82633A004352", "type": "car"}
+	if code := admin.Post("/vehicle", body, &vehicle); code != http.StatusOK {
+		t.Fatalf("create vehicle: status %d", code)
+	}
+	h.AddLicense(driverID, "B", time.Now().AddDate(1, 0, 0))
+	assign := map[string]interface{}{"user_id": driverID, "vehicle_id": vehicle.ID}
+	if code := driver.Post("/vehicle/assign", assign, nil); code != http.StatusForbidden {
+		t.Errorf("driver assigning a vehicle: status %d, want 403", code)
+	}
+	if code := admin.Post("/vehicle/assign", assign, nil); code != http.StatusOK {
+		t.Fatalf("assign vehicle: status %d", code)
+	}
+	if code := driver.Get(apitest.Path("/vehicle/:id", vehicle.ID), nil); code != http.StatusOK {
+		t.Errorf("driver reading the assigned vehicle: status %d", code)
+	}
+
+	var task struct {
+		ID     uint   `json:"ID"`
+		Status string `json:"status"`
+	}
+	create := map[string]interface{}{
+		"driver_id": driverID, "vehicle_id": vehicle.ID, "status": "assigned",
+		"start_latitude": 51.12, "start_longitude": 71.43, "end_latitude": 51.09, "end_longitude": 71.41,
+	}
+	if code := admin.Post("/task", create, &task); code != http.StatusOK {
+		t.Fatalf("create task: status %d", code)
+	}
+	if code := driver.Put(apitest.Path("/task/:id", task.ID), nil, &task); code != http.StatusOK || task.Status != "completed" {
+		t.Errorf("driver completing the task: status %d, task %+v", code, task)
+	}
+	if code := admin.Get(apitest.Path("/report/:vehicle_id", vehicle.ID), nil); code != http.StatusOK {
+		t.Errorf("vehicle report: status %d", code)
+	}
+
+	if code := admin.Post("/vehicle/unassign", assign, nil); code != http.StatusOK {
+		t.Fatalf("unassign vehicle: status %d", code)
+	}
+	if code := driver.Get(apitest.Path("/vehicle/:id", vehicle.ID), nil); code != http.StatusNotFound {
+		t.Errorf("driver reading an unassigned vehicle: status %d, want 404", code)
+	}
+}
+
+func TestLicenseRules(t *testing.T) {
+	h := apitest.New(t)
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	driverID := h.User(apitest.RoleDriver).ID
+
+	if code := driver.Put(apitest.Path("/user/:id/license", driverID), nil, nil); code != http.StatusForbidden {
+		t.Errorf("driver saving their own license: status %d, want 403", code)
+	}
+
+	vehicles := map[string]uint{}
+	for i, vehicleType := range []string{"car", "truck"} {
+		var vehicle struct {
+			ID uint `json:"ID"`
+		}
+		body := map[string]interface{}{"license_plate": fmt.Sprintf("77%dAAA02", i), "vin": "1HGCM82633A004352", "type": vehicleType}
+		if code := admin.Post("/vehicle", body, &vehicle); code != http.StatusOK {
+			t.Fatalf("create %s: status %d", vehicleType, code)
+		}
+		vehicles[vehicleType] = vehicle.ID
+	}
+
+	h.AddLicense(driverID, "B", time.Now().AddDate(1, 0, 0))
+	var task struct {
+		ID uint `json:"ID"`
+	}
+	create := map[string]interface{}{
+		"driver_id": driverID, "vehicle_id": vehicles["car"], "status": "assigned",
+		"start_latitude": 51.12, "start_longitude": 71.43, "end_latitude": 51.09, "end_longitude": 71.41,
+	}
+	if code := admin.Post("/task", create, &task); code != http.StatusOK {
+		t.Fatalf("create task with a car: status %d", code)
+	}
+	// editing the task must not get around the license check
+	update := map[string]interface{}{"vehicle_id": vehicles["truck"]}
+	if code := admin.Put(apitest.Path("/task/:id", task.ID), update, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("move the task to a truck: status %d, want 422", code)
+	}
+}
+
+func TestDistanceReport(t *testing.T) {
+	h := apitest.New(t)
+	if err := h.Server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Server.Shutdown(context.Background()) })
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	driverID := h.User(apitest.RoleDriver).ID
+
+	var vehicle struct {
+		ID uint `json:"ID"`
+	}
+	body := map[string]interface{}{"license_plate": "777AAA02", "vin": "1HGCM82633A004352", "type": "car"}
+	if code := admin.Post("/vehicle", body, &vehicle); code != http.StatusOK {
+		t.Fatalf("create vehicle: status %d", code)
+	}
+	h.AddLicense(driverID, "B", time.Now().AddDate(1, 0, 0))
+	if code := admin.Post("/vehicle/assign", map[string]interface{}{"user_id": driverID, "vehicle_id": vehicle.ID}, nil); code != http.StatusOK {
+		t.Fatalf("assign vehicle: status %d", code)
+	}
+	var task struct {
+		ID uint `json:"ID"`
+	}
+	create := map[string]interface{}{
+		"driver_id": driverID, "vehicle_id": vehicle.ID, "status": "assigned",
+		"start_latitude": 51.12, "start_longitude": 71.43, "end_latitude": 51.14, "end_longitude": 71.43,
+	}
+	if code := admin.Post("/task", create, &task); code != http.StatusOK {
+		t.Fatalf("create task: status %d", code)
+	}
+
+	// the driver goes north past the destination and back to it, about 5.6 km instead of 2.2
+	start := time.Now().Add(-time.Hour)
+	ping := map[string]interface{}{"vehicle_id": vehicle.ID, "task_id": task.ID, "points": []map[string]interface{}{
+		{"lat": 51.12, "lon": 71.43, "timestamp": start},
+		{"lat": 51.155, "lon": 71.43, "timestamp": start.Add(10 * time.Minute)},
+		{"lat": 51.14, "lon": 71.43, "timestamp": start.Add(20 * time.Minute)},
+	}}
+	if code := driver.Post("/tracking/ping", ping, nil); code != http.StatusOK {
+		t.Fatalf("tracking ping: status %d", code)
+	}
+	if code := driver.Put(apitest.Path("/task/:id", task.ID), nil, nil); code != http.StatusOK {
+		t.Fatalf("complete task: status %d", code)
+	}
+
+	var report []struct {
+		TaskID         uint     `json:"task_id"`
+		ActualDistance float64  `json:"actual_distance"`
+		Difference     *float64 `json:"difference"`
+	}
+	// the usage is recorded once the completion event is dispatched
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if code := admin.Get(apitest.Path("/report/:vehicle_id/distance", vehicle.ID), &report); code != http.StatusOK {
+			t.Fatalf("distance report: status %d", code)
+		}
+		if len(report) == 1 && report[0].ActualDistance > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(report) != 1 || report[0].ActualDistance < 5.5 || report[0].ActualDistance > 5.6 {
+		t.Fatalf("report = %+v, want the task with about 5.6 km driven", report)
+	}
+	if report[0].Difference == nil || *report[0].Difference < 3 {
+		t.Errorf("difference = %v, want the detour of over 3 km", report[0].Difference)
+	}
+}
+
+func TestFormValidation(t *testing.T) {
+	h := apitest.New(t)
+	admin, driver := h.As(apitest.RoleAdmin), h.As(apitest.RoleDriver)
+	driverID := h.User(apitest.RoleDriver).ID
+	var vehicle struct {
+		ID uint `json:"ID"`
+	}
+	body := map[string]interface{}{"license_plate": "777AAA02", "vin": "1HGCM82633A004352", "type": "car"}
+	if code := admin.Post("/vehicle", body, &vehicle); code != http.StatusOK {
+		t.Fatalf("create vehicle: status %d", code)
+	}
+
+	tests := []struct {
+		name   string
+		client *apitest.Client
+		method string
+		path   string
+		fields map[string]string
+		want   []string
+	}{
+		{"auction without vehicle", admin, http.MethodPost, "/auction", map[string]string{"details": "runs well"}, []string{"vehicle_id"}},
+		{"inspection without ids", driver, http.MethodPost, "/inspection", map[string]string{"results": "[]"}, []string{"vehicle_id", "template_id"}},
+		{"document of unknown type", admin, http.MethodPost, apitest.Path("/vehicle/:id/documents", vehicle.ID),
+			map[string]string{"type": "passport", "valid_to": "2030-01-01"}, []string{"type", "number"}},
+		{"document valid before it starts", admin, http.MethodPost, apitest.Path("/vehicle/:id/documents", vehicle.ID),
+			map[string]string{"type": "insurance", "number": "P-1", "valid_from": "2030-01-01", "valid_to": "2029-01-01"}, []string{"valid_to"}},
+		{"license without expiry", admin, http.MethodPut, apitest.Path("/user/:id/license", driverID),
+			map[string]string{"number": "KZ0000001", "categories": " , ", "issued_at": "yesterday"}, []string{"expires_at", "issued_at", "categories"}},
+		{"qualification of unknown kind", admin, http.MethodPost, apitest.Path("/user/:id/qualifications", driverID),
+			map[string]string{"kind": "pilot"}, []string{"kind"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tt.client.Form(tt.method, tt.path, tt.fields, nil)
+			if recorder.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status %d %s, want 422", recorder.Code, recorder.Body)
+			}
+			var response api.ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, field := range response.Fields {
+				got = append(got, field.Field)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("invalid fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	fields := map[string]string{"number": "KZ0000001", "categories": "b, c", "issued_at": "2020-01-01", "expires_at": "2030-01-01T00:00:00Z"}
+	var license struct {
+		Categories []string `json:"categories"`
+	}
+	if recorder := admin.Form(http.MethodPut, apitest.Path("/user/:id/license", driverID), fields, &license); recorder.Code != http.StatusOK {
+		t.Fatalf("save license: status %d %s", recorder.Code, recorder.Body)
+	}
+	if !slices.Equal(license.Categories, []string{"B", "C"}) {
+		t.Errorf("license categories %v, want [B C]", license.Categories)
+	}
+}
@@ -0,0 +1,261 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Code is the machine readable kind of an error, clients should branch on it rather than on the message
+type Code string
+
+const (
+	CodeBadRequest   Code = "bad_request"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeTooLarge     Code = "payload_too_large"
+	CodeTooMany      Code = "too_many_requests"
+	CodeValidation   Code = "validation_failed"
+	CodeInternal     Code = "internal"
+	CodeBadGateway   Code = "bad_gateway"
+)
+
+var statuses = map[Code]int{
+	CodeBadRequest:   http.StatusBadRequest,
+	CodeUnauthorized: http.StatusUnauthorized,
+	CodeForbidden:    http.StatusForbidden,
+	CodeNotFound:     http.StatusNotFound,
+	CodeConflict:     http.StatusConflict,
+	CodeTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeTooMany:      http.StatusTooManyRequests,
+	CodeValidation:   http.StatusUnprocessableEntity,
+	CodeInternal:     http.StatusInternalServerError,
+	CodeBadGateway:   http.StatusBadGateway,
+}
+
+// FieldError describes why one input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error that is safe to show to clients. Err keeps the cause for logging,
+// it is never part of the response.
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	Err     error
+	// RetryAfter is sent as the Retry-After header when it is set
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status code of the error
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func newError(code Code, format string, args []interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// BadRequest is for requests that cannot be read, like malformed JSON or a non-numeric id
+func BadRequest(format string, args ...interface{}) *Error {
+	return newError(CodeBadRequest, format, args)
+}
+
+func Unauthorized(format string, args ...interface{}) *Error {
+	return newError(CodeUnauthorized, format, args)
+}
+
+// Forbidden is for authenticated users whose role or relation to the record does not allow the action
+func Forbidden(format string, args ...interface{}) *Error {
+	return newError(CodeForbidden, format, args)
+}
+
+func NotFound(format string, args ...interface{}) *Error {
+	return newError(CodeNotFound, format, args)
+}
+
+// Conflict is for actions the current state of a record does not allow, like closing a closed auction
+func Conflict(format string, args ...interface{}) *Error {
+	return newError(CodeConflict, format, args)
+}
+
+func TooLarge(format string, args ...interface{}) *Error {
+	return newError(CodeTooLarge, format, args)
+}
+
+// TooMany is for clients over a rate limit or locked out, they may try again after retryAfter
+func TooMany(retryAfter time.Duration, format string, args ...interface{}) *Error {
+	err := newError(CodeTooMany, format, args)
+	err.RetryAfter = retryAfter
+	return err
+}
+
+// Invalid rejects a single input field
+func Invalid(field, format string, args ...interface{}) *Error {
+	message := fmt.Sprintf(format, args...)
+	return Validation(FieldError{Field: field, Message: message})
+}
+
+// Validation rejects the input with one entry per invalid field
+func Validation(fields ...FieldError) *Error {
+	message := "request is invalid"
+	if len(fields) == 1 {
+		message = fields[0].Message
+	}
+	return &Error{Code: CodeValidation, Message: message, Fields: fields}
+}
+
+// Internal hides err from the client, it is only logged
+func Internal(err error) *Error {
+	return &Error{Code: CodeInternal, Message: "internal server error", Err: err}
+}
+
+// BadGateway is for services the request depends on that failed, like the mail server. err is
+// only logged.
+func BadGateway(err error, format string, args ...interface{}) *Error {
+	appErr := newError(CodeBadGateway, format, args)
+	appErr.Err = err
+	return appErr
+}
+
+// From converts any error returned to a handler into an Error. Database errors are mapped to
+// not found and conflict, errors reading the request to bad request, and anything unknown
+// becomes an internal error so driver messages never reach clients.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var numErr *strconv.NumError
+	var timeErr *time.ParseError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &Error{Code: CodeNotFound, Message: "record not found", Err: err}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Code: CodeConflict, Message: "a record with the same unique values already exists", Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Code: CodeConflict, Message: "the change conflicts with a related record", Err: err}
+	case errors.As(err, &validationErrs):
+		return fromValidationErrors(validationErrs)
+	case errors.As(err, &maxBytesErr), errors.Is(err, multipart.ErrMessageTooLarge):
+		return &Error{Code: CodeTooLarge, Message: "request body is too large", Err: err}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &Error{Code: CodeBadRequest, Message: "request body is not valid JSON", Err: err}
+	case errors.As(err, &typeErr):
+		return &Error{Code: CodeBadRequest, Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type), Err: err}
+	case errors.As(err, &numErr):
+		return &Error{Code: CodeBadRequest, Message: fmt.Sprintf("%q is not a valid number", numErr.Num), Err: err}
+	case errors.As(err, &timeErr):
+		return &Error{Code: CodeBadRequest, Message: fmt.Sprintf("%q is not a valid time", timeErr.Value), Err: err}
+	case errors.Is(err, http.ErrNotMultipart), errors.Is(err, http.ErrMissingBoundary), errors.Is(err, http.ErrMissingFile):
+		return &Error{Code: CodeBadRequest, Message: err.Error(), Err: err}
+	}
+	return Internal(err)
+}
+
+func fromValidationErrors(errs validator.ValidationErrors) *Error {
+	fields := make([]FieldError, len(errs))
+	for i, fieldErr := range errs {
+		fields[i] = FieldError{Field: fieldName(fieldErr), Message: fieldMessage(fieldErr)}
+	}
+	return Validation(fields...)
+}
+
+// fieldName returns the path of the field below the bound struct, e.g. "points[0].lat"
+func fieldName(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+func fieldMessage(fieldErr validator.FieldError) string {
+	field, param := fieldErr.Field(), fieldErr.Param()
+	switch kind := fieldErr.Kind(); fieldErr.Tag() {
+	case "required":
+		return field + " is required"
+	case "min", "max":
+		// min and max limit the length of strings and lists
+		unit := ""
+		switch kind {
+		case reflect.String:
+			unit = " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			unit = " items"
+		}
+		if fieldErr.Tag() == "min" {
+			return fmt.Sprintf("%s must have at least %s%s", field, param, unit)
+		}
+		return fmt.Sprintf("%s must have at most %s%s", field, param, unit)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.ReplaceAll(param, " ", ", "))
+	case "gte":
+		return fmt.Sprintf("%s must be at least %s", field, param)
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", field, param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	case "email":
+		return field + " must be an email address"
+	case "url", "http_url":
+		return field + " must be a URL"
+	case "gtfield", "gtefield":
+		return fmt.Sprintf("%s must be after %s", field, param)
+	case "latitude":
+		return field + " must be between -90 and 90"
+	case "longitude":
+		return field + " must be between -180 and 180"
+	case "vin":
+		return field + " must be a 17 character VIN"
+	case "maxyear":
+		return field + " must not be later than next year"
+	case "notfuture":
+		return field + " must not be in the future"
+	case "hhmm":
+		return field + " must be formatted as HH:MM"
+	case "vehicletype":
+		return field + " must be one of bus, car, motorcycle, tanker, truck, van"
+	case "date":
+		return field + " must be a date (YYYY-MM-DD)"
+	case "timezone":
+		return field + " must be an IANA time zone such as Europe/Berlin"
+	}
+	return fmt.Sprintf("%s fails the %s rule", field, fieldErr.Tag())
+}
@@ -0,0 +1,600 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rassulmagauin/VMS_SWE/logging"
+	"github.com/rassulmagauin/VMS_SWE/routing"
+	"github.com/rassulmagauin/VMS_SWE/tracing"
+	"github.com/rassulmagauin/VMS_SWE/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	StorageLocal = "local"
+
+	RateLimitMemory   = "memory"
+	RateLimitDatabase = "database"
+
+	// developmentTokenKey is only accepted outside production
+	developmentTokenKey = "12345678901234567890123456789012"
+)
+
+// Config is the whole configuration of the server. It is read from an optional YAML file and
+// then from the environment, where variables from a .env file count as set; see Load.
+type Config struct {
+	Environment string          `yaml:"environment"`
+	HTTP        HTTPConfig      `yaml:"http"`
+	Database    DatabaseConfig  `yaml:"database"`
+	Token       TokenConfig     `yaml:"token"`
+	Password    PasswordConfig  `yaml:"password"`
+	Uploads     UploadConfig    `yaml:"uploads"`
+	Storage     StorageConfig   `yaml:"storage"`
+	CORS        CORSConfig      `yaml:"cors"`
+	SMTP        SMTPConfig      `yaml:"smtp"`
+	Routing     RoutingConfig   `yaml:"routing"`
+	Log         LogConfig       `yaml:"log"`
+	Tracing     TracingConfig   `yaml:"tracing"`
+	RateLimit   RateLimitConfig `yaml:"rate_limit"`
+	// location pings older than this are purged
+	LocationRetention time.Duration `yaml:"location_retention"`
+	// documents expiring within this period raise alerts
+	DocumentExpiryWarning time.Duration `yaml:"document_expiry_warning"`
+	// drivers without a license on file may still be assigned, a grace period while admins record them
+	AllowUnlicensedDrivers bool `yaml:"allow_unlicensed_drivers"`
+}
+
+type HTTPConfig struct {
+	Port string `yaml:"port"`
+	// PublicHost is the host the swagger UI loads the API description from
+	PublicHost        string        `yaml:"public_host"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests and jobs are waited for on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose X-Forwarded-For
+	// is believed. Without any the client IP is the address of the connection.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// DSN returns the postgres connection string
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", c.Host, c.User, c.Password, c.Name, c.Port, c.SSLMode)
+}
+
+type TokenConfig struct {
+	// SymmetricKey signs the PASETO access tokens and must be exactly 32 characters
+	SymmetricKey        string        `yaml:"symmetric_key"`
+	AccessTokenDuration time.Duration `yaml:"access_token_duration"`
+}
+
+// PasswordConfig sets the strength of new passwords and the password reset emails
+type PasswordConfig struct {
+	MinLength     int  `yaml:"min_length"`
+	RequireUpper  bool `yaml:"require_upper"`
+	RequireLower  bool `yaml:"require_lower"`
+	RequireDigit  bool `yaml:"require_digit"`
+	RequireSymbol bool `yaml:"require_symbol"`
+	// ResetTokenTTL is how long a reset link can be used
+	ResetTokenTTL time.Duration `yaml:"reset_token_ttl"`
+	// ResetURL is the page reset emails link to, with the token appended as the token query
+	// parameter. Without it the emails contain the bare token.
+	ResetURL string `yaml:"reset_url"`
+}
+
+// Policy returns the rules new passwords are checked against
+func (c PasswordConfig) Policy() utils.PasswordPolicy {
+	return utils.PasswordPolicy{
+		MinLength:     c.MinLength,
+		RequireUpper:  c.RequireUpper,
+		RequireLower:  c.RequireLower,
+		RequireDigit:  c.RequireDigit,
+		RequireSymbol: c.RequireSymbol,
+	}
+}
+
+type UploadConfig struct {
+	// MaxFileSize is the largest accepted uploaded file in bytes
+	MaxFileSize int64 `yaml:"max_file_size"`
+	// MaxRequestSize is the largest accepted request body in bytes
+	MaxRequestSize int64 `yaml:"max_request_size"`
+}
+
+type StorageConfig struct {
+	// Backend selects where uploaded files are kept, only "local" is supported
+	Backend string `yaml:"backend"`
+	// Dir is the directory of the local backend, served under /static
+	Dir string `yaml:"dir"`
+}
+
+// SMTPConfig configures email notifications, without Addr notifications only go to the in-app inbox
+type SMTPConfig struct {
+	Addr     string `yaml:"addr"`
+	From     string `yaml:"from"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// RoutingConfig configures route estimates, great-circle estimates are used without EngineURL
+type RoutingConfig struct {
+	EngineURL string `yaml:"engine_url"`
+	// AverageSpeeds is a list like "Car=60,Truck=45,Bus=40"
+	AverageSpeeds string `yaml:"average_speeds"`
+}
+
+type LogConfig struct {
+	// Level is the lowest level written: debug, info, warn or error. Every query is logged at debug.
+	Level string `yaml:"level"`
+	// Format is json or text
+	Format string `yaml:"format"`
+	// queries taking longer are logged as warnings, zero turns this off
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+}
+
+// TracingConfig configures OpenTelemetry tracing. The otlp exporter reads its endpoint and headers
+// from the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	// Exporter is none, otlp or stdout
+	Exporter    string  `yaml:"exporter"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// RateLimitConfig limits logins and authenticated requests. A limit of zero requests turns it off.
+type RateLimitConfig struct {
+	// Backend is memory, limiting each instance on its own, or database, sharing the counters
+	Backend string `yaml:"backend"`
+	// LoginPerIP and LoginPerUsername are the login attempts allowed per LoginWindow
+	LoginPerIP       int64         `yaml:"login_per_ip"`
+	LoginPerUsername int64         `yaml:"login_per_username"`
+	LoginWindow      time.Duration `yaml:"login_window"`
+	// LockoutFailures failed logins within LockoutWindow lock the account for LockoutDuration,
+	// doubled for every further lockout that day up to LockoutMaxDuration, zero leaves it uncapped
+	LockoutFailures    int64         `yaml:"lockout_failures"`
+	LockoutWindow      time.Duration `yaml:"lockout_window"`
+	LockoutDuration    time.Duration `yaml:"lockout_duration"`
+	LockoutMaxDuration time.Duration `yaml:"lockout_max_duration"`
+	// RequestsPerToken are the authenticated requests allowed per RequestWindow and token
+	RequestsPerToken int64         `yaml:"requests_per_token"`
+	RequestWindow    time.Duration `yaml:"request_window"`
+}
+
+// SlogLevel returns Level, which Validate has checked
+func (c LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level))
+	return level
+}
+
+// Default returns the configuration used for everything the YAML file and the environment leave unset
+func Default() Config {
+	return Config{
+		Environment: EnvDevelopment,
+		HTTP: HTTPConfig{
+			Port:              "8080",
+			PublicHost:        "localhost:8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       2 * time.Minute,
+			WriteTimeout:      2 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Token: TokenConfig{
+			SymmetricKey:        developmentTokenKey,
+			AccessTokenDuration: 45 * time.Minute,
+		},
+		Password: PasswordConfig{
+			MinLength:     8,
+			RequireUpper:  true,
+			RequireLower:  true,
+			RequireDigit:  true,
+			ResetTokenTTL: time.Hour,
+		},
+		Uploads: UploadConfig{
+			MaxFileSize:    10 << 20,
+			MaxRequestSize: 32 << 20,
+		},
+		Storage: StorageConfig{
+			Backend: StorageLocal,
+			Dir:     "./uploads",
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Origin", "Content-Type", "Authorization"},
+			MaxAge:         12 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:              "info",
+			Format:             logging.FormatJSON,
+			SlowQueryThreshold: 200 * time.Millisecond,
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "vms",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Backend:            RateLimitMemory,
+			LoginPerIP:         20,
+			LoginPerUsername:   10,
+			LoginWindow:        time.Minute,
+			LockoutFailures:    5,
+			LockoutWindow:      15 * time.Minute,
+			LockoutDuration:    time.Minute,
+			LockoutMaxDuration: time.Hour,
+			RequestsPerToken:   600,
+			RequestWindow:      time.Minute,
+		},
+		LocationRetention:     90 * 24 * time.Hour,
+		DocumentExpiryWarning: 30 * 24 * time.Hour,
+	}
+}
+
+// Load reads the configuration. Values come, from lowest to highest precedence, from the defaults,
+// the YAML file at path, the .env file and the environment. An empty path reads config.yaml
+// if it exists; a path that was given must exist.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	required := path != ""
+	if path == "" {
+		path = "config.yaml"
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("cannot parse %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist) || required:
+		return cfg, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+
+	// variables already set in the environment win over the .env file
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, fmt.Errorf("cannot load .env: %w", err)
+	}
+	err = cfg.loadEnv()
+	cfg.applyEnvironmentDefaults()
+	return cfg, errors.Join(err, cfg.Validate())
+}
+
+// applyEnvironmentDefaults fills in settings whose default depends on the environment
+func (c *Config) applyEnvironmentDefaults() {
+	// local frontends run on all kinds of ports, production must list its origins
+	if c.Environment == EnvDevelopment && len(c.CORS.AllowedOrigins) == 0 {
+		c.CORS.AllowedOrigins = []string{"*"}
+	}
+}
+
+func (c *Config) loadEnv() error {
+	var env envReader
+	env.string("APP_ENV", &c.Environment)
+
+	env.string("PORT", &c.HTTP.Port)
+	env.string("HOST", &c.HTTP.PublicHost)
+	env.duration("HTTP_READ_HEADER_TIMEOUT", &c.HTTP.ReadHeaderTimeout)
+	env.duration("HTTP_READ_TIMEOUT", &c.HTTP.ReadTimeout)
+	env.duration("HTTP_WRITE_TIMEOUT", &c.HTTP.WriteTimeout)
+	env.duration("HTTP_IDLE_TIMEOUT", &c.HTTP.IdleTimeout)
+	env.duration("SHUTDOWN_TIMEOUT", &c.HTTP.ShutdownTimeout)
+	env.list("HTTP_TRUSTED_PROXIES", &c.HTTP.TrustedProxies)
+
+	env.string("DB_HOST", &c.Database.Host)
+	env.string("DB_PORT", &c.Database.Port)
+	env.string("DB_USER", &c.Database.User)
+	env.string("DB_PASSWORD", &c.Database.Password)
+	env.string("DB_NAME", &c.Database.Name)
+	env.string("DB_SSLMODE", &c.Database.SSLMode)
+	env.int("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	env.int("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	env.duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	env.duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+
+	env.string("TOKEN_SYMMETRIC_KEY", &c.Token.SymmetricKey)
+	env.duration("ACCESS_TOKEN_DURATION", &c.Token.AccessTokenDuration)
+
+	env.int("PASSWORD_MIN_LENGTH", &c.Password.MinLength)
+	env.bool("PASSWORD_REQUIRE_UPPER", &c.Password.RequireUpper)
+	env.bool("PASSWORD_REQUIRE_LOWER", &c.Password.RequireLower)
+	env.bool("PASSWORD_REQUIRE_DIGIT", &c.Password.RequireDigit)
+	env.bool("PASSWORD_REQUIRE_SYMBOL", &c.Password.RequireSymbol)
+	env.duration("PASSWORD_RESET_TOKEN_TTL", &c.Password.ResetTokenTTL)
+	env.string("PASSWORD_RESET_URL", &c.Password.ResetURL)
+
+	env.int64("UPLOAD_MAX_FILE_SIZE", &c.Uploads.MaxFileSize)
+	env.int64("UPLOAD_MAX_REQUEST_SIZE", &c.Uploads.MaxRequestSize)
+
+	env.string("STORAGE_BACKEND", &c.Storage.Backend)
+	env.string("STORAGE_DIR", &c.Storage.Dir)
+
+	env.list("CORS_ALLOWED_ORIGINS", &c.CORS.AllowedOrigins)
+	env.list("CORS_ALLOWED_METHODS", &c.CORS.AllowedMethods)
+	env.list("CORS_ALLOWED_HEADERS", &c.CORS.AllowedHeaders)
+	env.bool("CORS_ALLOW_CREDENTIALS", &c.CORS.AllowCredentials)
+	env.duration("CORS_MAX_AGE", &c.CORS.MaxAge)
+
+	env.string("SMTP_ADDR", &c.SMTP.Addr)
+	env.string("SMTP_FROM", &c.SMTP.From)
+	env.string("SMTP_USERNAME", &c.SMTP.Username)
+	env.string("SMTP_PASSWORD", &c.SMTP.Password)
+
+	env.string("ROUTING_ENGINE_URL", &c.Routing.EngineURL)
+	env.string("ROUTING_AVERAGE_SPEEDS", &c.Routing.AverageSpeeds)
+
+	env.string("LOG_LEVEL", &c.Log.Level)
+	env.string("LOG_FORMAT", &c.Log.Format)
+	env.duration("LOG_SLOW_QUERY_THRESHOLD", &c.Log.SlowQueryThreshold)
+
+	env.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	env.string("TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+	env.float64("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	env.string("RATE_LIMIT_BACKEND", &c.RateLimit.Backend)
+	env.int64("RATE_LIMIT_LOGIN_PER_IP", &c.RateLimit.LoginPerIP)
+	env.int64("RATE_LIMIT_LOGIN_PER_USERNAME", &c.RateLimit.LoginPerUsername)
+	env.duration("RATE_LIMIT_LOGIN_WINDOW", &c.RateLimit.LoginWindow)
+	env.int64("RATE_LIMIT_LOCKOUT_FAILURES", &c.RateLimit.LockoutFailures)
+	env.duration("RATE_LIMIT_LOCKOUT_WINDOW", &c.RateLimit.LockoutWindow)
+	env.duration("RATE_LIMIT_LOCKOUT_DURATION", &c.RateLimit.LockoutDuration)
+	env.duration("RATE_LIMIT_LOCKOUT_MAX_DURATION", &c.RateLimit.LockoutMaxDuration)
+	env.int64("RATE_LIMIT_REQUESTS_PER_TOKEN", &c.RateLimit.RequestsPerToken)
+	env.duration("RATE_LIMIT_REQUEST_WINDOW", &c.RateLimit.RequestWindow)
+
+	env.days("LOCATION_RETENTION_DAYS", &c.LocationRetention)
+	env.period("DOCUMENT_EXPIRY_WARNING", &c.DocumentExpiryWarning)
+	env.bool("ALLOW_UNLICENSED_DRIVERS", &c.AllowUnlicensedDrivers)
+	return errors.Join(env.errs...)
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Environment == EnvDevelopment || c.Environment == EnvProduction,
+		"environment must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Environment)
+	check(c.HTTP.Port != "", "http port is required")
+	for name, timeout := range map[string]time.Duration{
+		"http read_header_timeout": c.HTTP.ReadHeaderTimeout,
+		"http read_timeout":        c.HTTP.ReadTimeout,
+		"http write_timeout":       c.HTTP.WriteTimeout,
+		"http idle_timeout":        c.HTTP.IdleTimeout,
+		"http shutdown_timeout":    c.HTTP.ShutdownTimeout,
+	} {
+		check(timeout > 0, "%s must be positive", name)
+	}
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "http trusted_proxies must be IP addresses or CIDR ranges, got %q", proxy)
+	}
+
+	check(c.Database.Host != "", "database host is required")
+	check(c.Database.Name != "", "database name is required")
+	check(c.Database.User != "", "database user is required")
+	check(c.Database.MaxOpenConns > 0, "database max_open_conns must be positive")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database max_idle_conns must be between 0 and max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database conn_max_lifetime must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database conn_max_idle_time must not be negative")
+
+	check(len(c.Token.SymmetricKey) == 32, "token symmetric_key must be exactly 32 characters")
+	check(c.Environment != EnvProduction || c.Token.SymmetricKey != developmentTokenKey,
+		"token symmetric_key must be set in production")
+	check(c.Token.AccessTokenDuration > 0, "token access_token_duration must be positive")
+
+	// bcrypt ignores everything after 72 bytes
+	check(c.Password.MinLength >= 1 && c.Password.MinLength <= 72, "password min_length must be between 1 and 72")
+	check(c.Password.ResetTokenTTL > 0, "password reset_token_ttl must be positive")
+	if c.Password.ResetURL != "" {
+		u, err := url.Parse(c.Password.ResetURL)
+		check(err == nil && u.IsAbs() && u.Host != "", "password reset_url must be an absolute URL, got %q", c.Password.ResetURL)
+	}
+
+	check(c.Uploads.MaxFileSize > 0, "uploads max_file_size must be positive")
+	check(c.Uploads.MaxRequestSize >= c.Uploads.MaxFileSize, "uploads max_request_size must be at least max_file_size")
+
+	check(c.Storage.Backend == StorageLocal, "storage backend %q is not supported, use %q", c.Storage.Backend, StorageLocal)
+	check(c.Storage.Dir != "", "storage dir is required")
+
+	errs = append(errs, c.CORS.validate()...)
+
+	check(c.SMTP.Addr == "" || c.SMTP.From != "", "smtp from is required with an smtp addr")
+	if _, err := routing.ParseSpeeds(c.Routing.AverageSpeeds); err != nil {
+		errs = append(errs, fmt.Errorf("routing average_speeds: %w", err))
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log level must be debug, info, warn or error, got %q", c.Log.Level)
+	check(c.Log.Format == logging.FormatJSON || c.Log.Format == logging.FormatText,
+		"log format must be %q or %q, got %q", logging.FormatJSON, logging.FormatText, c.Log.Format)
+	check(c.Log.SlowQueryThreshold >= 0, "log slow_query_threshold must not be negative")
+
+	check(c.Tracing.Exporter == tracing.ExporterNone || c.Tracing.Exporter == tracing.ExporterOTLP || c.Tracing.Exporter == tracing.ExporterStdout,
+		"tracing exporter must be %q, %q or %q, got %q", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, c.Tracing.Exporter)
+	check(c.Tracing.ServiceName != "", "tracing service_name is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing sample_ratio must be between 0 and 1")
+
+	errs = append(errs, c.RateLimit.validate()...)
+
+	check(c.LocationRetention > 0, "location_retention must be positive")
+	check(c.DocumentExpiryWarning > 0, "document_expiry_warning must be positive")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c RateLimitConfig) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Backend == RateLimitMemory || c.Backend == RateLimitDatabase,
+		"rate_limit backend must be %q or %q, got %q", RateLimitMemory, RateLimitDatabase, c.Backend)
+	check(c.LoginPerIP >= 0, "rate_limit login_per_ip must not be negative")
+	check(c.LoginPerUsername >= 0, "rate_limit login_per_username must not be negative")
+	check(c.LoginPerIP == 0 && c.LoginPerUsername == 0 || c.LoginWindow > 0, "rate_limit login_window must be positive")
+	check(c.LockoutFailures >= 0, "rate_limit lockout_failures must not be negative")
+	if c.LockoutFailures > 0 {
+		check(c.LockoutWindow > 0, "rate_limit lockout_window must be positive")
+		check(c.LockoutDuration > 0, "rate_limit lockout_duration must be positive")
+		check(c.LockoutMaxDuration == 0 || c.LockoutMaxDuration >= c.LockoutDuration,
+			"rate_limit lockout_max_duration must be 0 for no cap or at least lockout_duration")
+	}
+	check(c.RequestsPerToken >= 0, "rate_limit requests_per_token must not be negative")
+	check(c.RequestsPerToken == 0 || c.RequestWindow > 0, "rate_limit request_window must be positive")
+	return errs
+}
+
+// envReader overrides settings with the environment variables that are set,
+// collecting an error for every value that cannot be parsed
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) parse(name string, parse func(string) error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return
+	}
+	if err := parse(value); err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+func (r *envReader) string(name string, target *string) {
+	r.parse(name, func(value string) error {
+		*target = value
+		return nil
+	})
+}
+
+func (r *envReader) int(name string, target *int) {
+	r.parse(name, func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*target = n
+		return nil
+	})
+}
+
+func (r *envReader) int64(name string, target *int64) {
+	r.parse(name, func(value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*target = n
+		return nil
+	})
+}
+
+func (r *envReader) float64(name string, target *float64) {
+	r.parse(name, func(value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*target = f
+		return nil
+	})
+}
+
+func (r *envReader) bool(name string, target *bool) {
+	r.parse(name, func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*target = b
+		return nil
+	})
+}
+
+func (r *envReader) duration(name string, target *time.Duration) {
+	r.parse(name, func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 30s or 5m", value)
+		}
+		*target = d
+		return nil
+	})
+}
+
+func (r *envReader) days(name string, target *time.Duration) {
+	r.parse(name, func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("must be a positive number of days")
+		}
+		*target = time.Duration(n) * 24 * time.Hour
+		return nil
+	})
+}
+
+func (r *envReader) period(name string, target *time.Duration) {
+	r.parse(name, func(value string) (err error) {
+		*target, err = utils.ParsePeriod(value)
+		return err
+	})
+}
+
+func (r *envReader) list(name string, target *[]string) {
+	r.parse(name, func(value string) error {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*target = items
+		return nil
+	})
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inDir runs the rest of the test in dir, where Load looks for the .env file
+func inDir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// unsetEnv clears the variables for the test and restores them afterwards, Load sets those
+// of the .env file in the process environment
+func unsetEnv(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// valid returns the defaults completed with the settings that have none
+func valid() Config {
+	cfg := Default()
+	cfg.Database.Name = "vms"
+	cfg.Database.User = "vms"
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	inDir(t, dir)
+	unsetEnv(t, "APP_ENV", "PORT", "HOST", "DB_NAME", "DB_USER", "ACCESS_TOKEN_DURATION")
+	writeFile(t, filepath.Join(dir, "vms.yaml"), `
+http:
+  port: "7000"
+  public_host: yaml.example.com
+database:
+  name: yaml_db
+  user: yaml_user
+`)
+	writeFile(t, filepath.Join(dir, ".env"), "PORT=8000\nHOST=dotenv.example.com\nDB_NAME=dotenv_db\n")
+	t.Setenv("PORT", "9000")
+
+	cfg, err := Load(filepath.Join(dir, "vms.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name, got, want string
+	}{
+		{"environment over .env and yaml", cfg.HTTP.Port, "9000"},
+		{".env over yaml", cfg.HTTP.PublicHost, "dotenv.example.com"},
+		{".env over yaml", cfg.Database.Name, "dotenv_db"},
+		{"yaml over defaults", cfg.Database.User, "yaml_user"},
+		{"defaults", cfg.Database.Host, Default().Database.Host},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if cfg.Token.AccessTokenDuration != Default().Token.AccessTokenDuration {
+		t.Errorf("access token duration = %v, want the default", cfg.Token.AccessTokenDuration)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	inDir(t, dir)
+	unsetEnv(t, "APP_ENV", "ACCESS_TOKEN_DURATION")
+	t.Setenv("DB_NAME", "vms")
+	t.Setenv("DB_USER", "vms")
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("load of a missing file that was given succeeded")
+	}
+	// without a path a missing config.yaml leaves the defaults
+	if _, err := Load(""); err != nil {
+		t.Errorf("load without config.yaml: %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "http: [port]\n")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "cannot parse") {
+		t.Errorf("load of invalid yaml = %v, want a parse error", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "")
+	t.Setenv("ACCESS_TOKEN_DURATION", "an hour")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "ACCESS_TOKEN_DURATION") {
+		t.Errorf("load with an invalid variable = %v, want it named", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"production with the development key", func(c *Config) { c.Environment = EnvProduction }, "symmetric_key must be set in production"},
+		{"short token key", func(c *Config) { c.Token.SymmetricKey = "short" }, "exactly 32 characters"},
+		{"unknown environment", func(c *Config) { c.Environment = "staging" }, "environment"},
+		{"zero timeout", func(c *Config) { c.HTTP.WriteTimeout = 0 }, "write_timeout must be positive"},
+		{"trusted proxy range", func(c *Config) { c.HTTP.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"} }, ""},
+		{"trusted proxy name", func(c *Config) { c.HTTP.TrustedProxies = []string{"proxy.local"} }, "trusted_proxies"},
+		{"no database host", func(c *Config) { c.Database.Host = "" }, "database host is required"},
+		{"more idle than open connections", func(c *Config) { c.Database.MaxIdleConns = c.Database.MaxOpenConns + 1 }, "max_idle_conns"},
+		{"request smaller than a file", func(c *Config) { c.Uploads.MaxRequestSize = c.Uploads.MaxFileSize - 1 }, "max_request_size"},
+		{"relative reset url", func(c *Config) { c.Password.ResetURL = "/reset" }, "reset_url"},
+		{"smtp without from", func(c *Config) { c.SMTP.Addr = "smtp.example.com:587" }, "smtp from"},
+		{"log level", func(c *Config) { c.Log.Level = "verbose" }, "log level"},
+		{"sample ratio", func(c *Config) { c.Tracing.SampleRatio = 2 }, "sample_ratio"},
+		{"lockout without a cap", func(c *Config) { c.RateLimit.LockoutMaxDuration = 0 }, ""},
+		{"cors any origin with credentials", func(c *Config) {
+			c.CORS.AllowedOrigins = []string{"*"}
+			c.CORS.AllowCredentials = true
+		}, "allow_credentials"},
+		{"negative retention", func(c *Config) { c.LocationRetention = -time.Hour }, "location_retention"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(&cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("validate = %v, want no error", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("validate = %v, want an error about %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := valid()
+	cfg.Database.Host = ""
+	cfg.Log.Format = "xml"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "database host") || !strings.Contains(err.Error(), "log format") {
+		t.Errorf("validate = %v, want both errors", err)
+	}
+}
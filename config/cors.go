@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type CORSConfig struct {
+	// AllowedOrigins lists the origins browsers may call the API from. An origin is
+	// "scheme://host[:port]"; "https://*.example.com" allows every subdomain of example.com
+	// but not example.com itself, and "*" allows any origin when credentials are not allowed.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	AllowedMethods []string `yaml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+	// AllowCredentials lets browsers send cookies and HTTP auth with cross-origin requests
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// originPattern is a parsed entry of AllowedOrigins
+type originPattern struct {
+	scheme string
+	// host is the domain below which any subdomain matches when wildcard is set
+	host     string
+	port     string
+	wildcard bool
+}
+
+func parseOriginPattern(origin string) (originPattern, error) {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return originPattern{}, fmt.Errorf("cors origin %q must look like https://example.com", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return originPattern{}, fmt.Errorf("cors origin %q must not have a path, query or credentials", origin)
+	}
+	pattern := originPattern{scheme: u.Scheme, host: strings.ToLower(u.Hostname()), port: u.Port()}
+	if domain, ok := strings.CutPrefix(pattern.host, "*."); ok {
+		pattern.host = domain
+		pattern.wildcard = true
+	}
+	if pattern.host == "" || strings.Contains(pattern.host, "*") {
+		return originPattern{}, fmt.Errorf("cors origin %q may only use * for the leftmost subdomain, like https://*.example.com", origin)
+	}
+	return pattern, nil
+}
+
+func (p originPattern) matches(origin *url.URL) bool {
+	if origin.Scheme != p.scheme || origin.Port() != p.port {
+		return false
+	}
+	host := strings.ToLower(origin.Hostname())
+	if p.wildcard {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// AllowsOrigin reports whether browsers may call the API from origin
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+		// patterns are validated when the configuration is loaded
+		pattern, err := parseOriginPattern(allowed)
+		if err == nil && pattern.matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) validate() []error {
+	var errs []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = append(errs, fmt.Errorf("cors origin * cannot be combined with allow_credentials, list the allowed origins instead"))
+			}
+			continue
+		}
+		if _, err := parseOriginPattern(origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(c.AllowedMethods) == 0 {
+		errs = append(errs, fmt.Errorf("cors allowed_methods must not be empty"))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cors max_age must not be negative"))
+	}
+	return errs
+}
@@ -0,0 +1,94 @@
+package config
+
+import "testing"
+
+func TestParseOriginPattern(t *testing.T) {
+	tests := []struct {
+		origin  string
+		want    originPattern
+		wantErr bool
+	}{
+		{origin: "https://example.com", want: originPattern{scheme: "https", host: "example.com"}},
+		{origin: "https://Example.com/", want: originPattern{scheme: "https", host: "example.com"}},
+		{origin: "http://localhost:3000", want: originPattern{scheme: "http", host: "localhost", port: "3000"}},
+		{origin: "https://*.example.com", want: originPattern{scheme: "https", host: "example.com", wildcard: true}},
+		{origin: "https://*.example.com:8443", want: originPattern{scheme: "https", host: "example.com", port: "8443", wildcard: true}},
+		{origin: "example.com", wantErr: true},
+		{origin: "ftp://example.com", wantErr: true},
+		{origin: "https://example.com/app", wantErr: true},
+		{origin: "https://example.com?next=1", wantErr: true},
+		{origin: "https://user@example.com", wantErr: true},
+		{origin: "https://*", wantErr: true},
+		{origin: "https://*.*.example.com", wantErr: true},
+		{origin: "https://app.*.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			got, err := parseOriginPattern(tt.origin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pattern = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowsOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://fleet.example.com"}, "https://fleet.example.com", true},
+		{"exact in another case", []string{"https://fleet.example.com"}, "https://Fleet.Example.com", true},
+		{"other host", []string{"https://fleet.example.com"}, "https://admin.example.com", false},
+		{"subdomain", []string{"https://*.example.com"}, "https://fleet.example.com", true},
+		{"deeper subdomain", []string{"https://*.example.com"}, "https://eu.fleet.example.com", true},
+		{"apex of a wildcard", []string{"https://*.example.com"}, "https://example.com", false},
+		{"suffix without a dot", []string{"https://*.example.com"}, "https://badexample.com", false},
+		{"wildcard for another domain", []string{"https://*.example.com"}, "https://fleet.example.com.evil.io", false},
+		{"scheme mismatch", []string{"https://*.example.com"}, "http://fleet.example.com", false},
+		{"port on the origin only", []string{"https://*.example.com"}, "https://fleet.example.com:8443", false},
+		{"same port", []string{"http://localhost:3000"}, "http://localhost:3000", true},
+		{"other port", []string{"http://localhost:3000"}, "http://localhost:3001", false},
+		{"default port left out", []string{"http://localhost:3000"}, "http://localhost", false},
+		{"any origin", []string{"*"}, "https://anything.io", true},
+		{"second entry", []string{"https://fleet.example.com", "http://localhost:3000"}, "http://localhost:3000", true},
+		{"nothing allowed", nil, "https://fleet.example.com", false},
+		{"not an origin", []string{"*"}, "null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cors := CORSConfig{AllowedOrigins: tt.allowed}
+			if got := cors.AllowsOrigin(tt.origin); got != tt.want {
+				t.Errorf("AllowsOrigin(%q) with %v = %v, want %v", tt.origin, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	methods := []string{"GET"}
+	tests := []struct {
+		name    string
+		cors    CORSConfig
+		wantErr bool
+	}{
+		{"any origin", CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: methods}, false},
+		{"any origin with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: methods, AllowCredentials: true}, true},
+		{"listed origins with credentials", CORSConfig{AllowedOrigins: []string{"https://*.example.com"}, AllowedMethods: methods, AllowCredentials: true}, false},
+		{"invalid origin", CORSConfig{AllowedOrigins: []string{"example.com"}, AllowedMethods: methods}, true},
+		{"no methods", CORSConfig{AllowedOrigins: []string{"https://example.com"}}, true},
+		{"negative max age", CORSConfig{AllowedMethods: methods, MaxAge: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.cors.validate(); (len(errs) > 0) != tt.wantErr {
+				t.Errorf("validate = %v, want errors %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
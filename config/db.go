@@ -2,38 +2,28 @@ package config
 
 import (
 	"fmt"
-	"log"
-	"os"
 
-	"github.com/joho/godotenv"
 	"github.com/rassulmagauin/VMS_SWE/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
-
-func Connect() {
-	err := godotenv.Load()
-	if err != nil && !os.IsNotExist(err) {
-		log.Fatal("Error loading .env file")
-	}
-
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	port := os.Getenv("DB_PORT")
-	sslmode := os.Getenv("DB_SSLMODE") // if you're using SSL
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", host, user, password, dbname, port, sslmode)
-	if host == "" {
-		host = "localhost"
+// Connect opens the database, sizes its connection pool and migrates the schema. Queries are
+// logged through log.
+func Connect(cfg DatabaseConfig, log logger.Interface) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{TranslateError: true, Logger: log})
+	if err != nil {
+		return nil, err
 	}
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	db.Exec(`
     CREATE TYPE task_status AS ENUM ('completed', 'canceled', 'delayed'); 
@@ -44,22 +34,64 @@ func Connect() {
     CREATE TYPE roles_list AS ENUM ('Admin', 'Driver', 'Fueling_person', 'Maintenance_person');
 `)
 
-	err = db.AutoMigrate(
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate creates or updates the tables of every model. Connect runs it, tests run it against
+// their own database.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Vehicle{},
 		&models.Task{},
+		&models.TaskWaypoint{},
 		&models.Appointment{},
 		&models.AuctionVehicle{},
 		&models.MaintenanceRecord{},
 		&models.FuelingRecord{},
 		&models.VehicleUsage{},
+		&models.LocationPing{},
+		&models.Geofence{},
+		&models.GeofenceEvent{},
+		&models.InspectionTemplate{},
+		&models.InspectionTemplateItem{},
+		&models.Inspection{},
+		&models.InspectionResult{},
+		&models.InspectionPhoto{},
+		&models.VehicleAssignment{},
+		&models.Incident{},
+		&models.IncidentPhoto{},
+		&models.VehicleDocument{},
+		&models.Alert{},
+		&models.DriverLicense{},
+		&models.DriverQualification{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.OutboxEvent{},
+		&models.RateLimitCounter{},
+		&models.PasswordResetToken{},
+		&models.Job{},
+		&models.JobRun{},
 		&models.RolePermission{},
 		&models.Image{},
 		&models.Part{},
 	)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("cannot migrate database: %w", err)
 	}
+	return nil
+}
 
-	DB = db
+// Close closes the connection pool of db
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -15,6 +15,79 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/alerts": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns alerts raised by background checks, unresolved ones unless resolved=true",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alert"
+                ],
+                "summary": "Get alerts",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Return resolved alerts instead",
+                        "name": "resolved",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.alertResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/alerts/{id}/resolve": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Marks an alert as resolved",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alert"
+                ],
+                "summary": "Resolve an alert",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Alert ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.alertResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/auction": {
             "get": {
                 "description": "Get all auctions",
@@ -68,6 +141,12 @@ const docTemplate = `{
                         "name": "details",
                         "in": "formData"
                     },
+                    {
+                        "type": "string",
+                        "description": "When the auction closes unless an admin closed it before (YYYY-MM-DD or RFC3339)",
+                        "name": "ends_at",
+                        "in": "formData"
+                    },
                     {
                         "type": "file",
                         "description": "Images for the auction",
@@ -146,6 +225,230 @@ const docTemplate = `{
                 }
             }
         },
+        "/auction/{id}/close": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins close an auction before it ends and record the winning user, who is notified",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auction"
+                ],
+                "summary": "Close an auction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Auction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Winner",
+                        "name": "auction",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.closeAuctionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful response with auction details",
+                        "schema": {
+                            "$ref": "#/definitions/api.AuctionVehicleResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/expiring": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns documents that expire within the given period, including already expired ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "document"
+                ],
+                "summary": "Get expiring documents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Period such as 30d, 2w or 72h, defaults to 30d",
+                        "name": "within",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.vehicleDocumentResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update the number, issuer or validity of a document, e.g. after renewal. Alerts of a renewed document are resolved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "document"
+                ],
+                "summary": "Update a vehicle document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Document",
+                        "name": "document",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateVehicleDocumentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleDocumentResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a vehicle document and its file",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "document"
+                ],
+                "summary": "Delete a vehicle document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events stream of task, vehicle, fueling and tracking events. Drivers only receive their own events. The token may be passed as access_token query parameter.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Stream fleet events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Access token for clients that cannot set headers",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/events.Event"
+                        }
+                    }
+                }
+            }
+        },
+        "/fleet/positions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the last known position of every vehicle that has reported one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracking"
+                ],
+                "summary": "Get last known positions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.fleetPositionResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/fueling": {
             "get": {
                 "security": [
@@ -367,45 +670,40 @@ const docTemplate = `{
                 }
             }
         },
-        "/login": {
-            "post": {
-                "description": "Logs user in",
+        "/geofence": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all geofences",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
-                ],
-                "summary": "Login user",
-                "parameters": [
-                    {
-                        "description": "User",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.loginRequest"
-                        }
-                    }
+                    "geofence"
                 ],
+                "summary": "Get all geofences",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.loginResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.geofenceResponse"
+                            }
                         }
                     }
                 }
-            }
-        },
-        "/maintenance": {
-            "get": {
+            },
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get all maintenance records if user",
+                "description": "Admins define circle or polygon geofences such as depots, customer sites, restricted zones and operating areas",
                 "consumes": [
                     "application/json"
                 ],
@@ -413,90 +711,109 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "maintenance"
+                    "geofence"
                 ],
-                "summary": "Get all maintenance records of particular user",
+                "summary": "Create a geofence",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "user_id",
-                        "in": "path",
-                        "required": true
+                        "description": "Geofence",
+                        "name": "geofence",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.geofenceRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.createMaintenanceRecordResponse"
-                            }
+                            "$ref": "#/definitions/api.geofenceResponse"
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/geofence/events": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create a maintenance record",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns the newest enter, exit and violation events, optionally filtered",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "maintenance"
+                    "geofence"
                 ],
-                "summary": "Create a maintenance record",
+                "summary": "Get geofence events",
                 "parameters": [
                     {
-                        "description": "Maintenance",
-                        "name": "maintenance",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createMaintenanceRecordRequest"
-                        }
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Geofence ID",
+                        "name": "geofence_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "enter, exit or violation",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 start time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 end time",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createMaintenanceRecordResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.geofenceEventResponse"
+                            }
                         }
                     }
                 }
             }
         },
-        "/maintenance/{id}": {
+        "/geofence/{id}": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get a maintenance record",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get a geofence",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "maintenance"
+                    "geofence"
                 ],
-                "summary": "Get a maintenance record",
+                "summary": "Get a geofence",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Maintenance ID",
+                        "description": "Geofence ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -506,7 +823,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createMaintenanceRecordResponse"
+                            "$ref": "#/definitions/api.geofenceResponse"
                         }
                     }
                 }
@@ -517,7 +834,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Update a maintenance record",
+                "description": "Update a geofence",
                 "consumes": [
                     "application/json"
                 ],
@@ -525,24 +842,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "maintenance"
+                    "geofence"
                 ],
-                "summary": "Update a maintenance record",
+                "summary": "Update a geofence",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Maintenance ID",
+                        "description": "Geofence ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Maintenance",
-                        "name": "maintenance",
+                        "description": "Geofence",
+                        "name": "geofence",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.createMaintenanceRecordRequest"
+                            "$ref": "#/definitions/api.geofenceRequest"
                         }
                     }
                 ],
@@ -550,7 +867,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createMaintenanceRecordResponse"
+                            "$ref": "#/definitions/api.geofenceResponse"
                         }
                     }
                 }
@@ -561,21 +878,18 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Delete a maintenance record",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Delete a geofence",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "maintenance"
+                    "geofence"
                 ],
-                "summary": "Delete a maintenance record",
+                "summary": "Delete a geofence",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Maintenance ID",
+                        "description": "Geofence ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -583,69 +897,54 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.createMaintenanceRecordResponse"
-                        }
+                        "description": "OK"
                     }
                 }
             }
         },
-        "/report/{vehicle_id}": {
+        "/healthz": {
             "get": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Get a report",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Answers as long as the process serves requests, it checks no dependencies",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "report"
-                ],
-                "summary": "Get a report",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Vehicle ID",
-                        "name": "vehicle_id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "health"
                 ],
+                "summary": "Liveness probe",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ReportForSwagger"
+                            "$ref": "#/definitions/api.healthResponse"
                         }
                     }
                 }
             }
         },
-        "/task": {
+        "/incident": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get all tasks",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Admins get all incidents, drivers get the incidents attributed to or reported by them",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "task"
+                    "incident"
+                ],
+                "summary": "Get incidents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get all tasks",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -654,7 +953,7 @@ const docTemplate = `{
                             "items": {
                                 "type": "array",
                                 "items": {
-                                    "$ref": "#/definitions/api.createTaskResponse"
+                                    "$ref": "#/definitions/api.incidentResponse"
                                 }
                             }
                         }
@@ -667,60 +966,99 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create a task",
+                "description": "Drivers and admins report accidents, damage or traffic incidents. The incident is attributed to the driver assigned to the vehicle at that time.",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "task"
+                    "incident"
                 ],
-                "summary": "Create a task",
+                "summary": "Report an incident",
                 "parameters": [
                     {
-                        "description": "Task",
-                        "name": "task",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createTaskRequest"
-                        }
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC3339 time of the incident",
+                        "name": "occurred_at",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "What happened",
+                        "name": "description",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Latitude",
+                        "name": "latitude",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Longitude",
+                        "name": "longitude",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Address or place",
+                        "name": "location",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON array of {name, phone_number, license_plate, insurance_company, insurance_policy}",
+                        "name": "third_parties",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Photos",
+                        "name": "photos",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createTaskResponse"
+                            "$ref": "#/definitions/api.incidentResponse"
                         }
                     }
                 }
             }
         },
-        "/task/{id}": {
+        "/incident/{id}": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get a task",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get an incident with its photos",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "task"
+                    "incident"
                 ],
-                "summary": "Get a task",
+                "summary": "Get an incident",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Task ID",
+                        "description": "Incident ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -730,18 +1068,20 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createTaskResponse"
+                            "$ref": "#/definitions/api.incidentResponse"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/incident/{id}/status": {
             "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Update a task",
+                "description": "Admins move an incident through Reported, UnderReview, RepairScheduled and Closed. Scheduling a repair links the given maintenance record or opens a pending one.",
                 "consumes": [
                     "application/json"
                 ],
@@ -749,24 +1089,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "task"
+                    "incident"
                 ],
-                "summary": "Update a task",
+                "summary": "Triage an incident",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Task ID",
+                        "description": "Incident ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Task",
-                        "name": "task",
+                        "description": "New status",
+                        "name": "status",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.createTaskRequest"
+                            "$ref": "#/definitions/api.updateIncidentStatusRequest"
                         }
                     }
                 ],
@@ -774,33 +1114,32 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createTaskResponse"
+                            "$ref": "#/definitions/api.incidentResponse"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/incidents/{vehicle_id}": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Delete a task",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get all incidents of a particular vehicle",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "task"
+                    "incident"
                 ],
-                "summary": "Delete a task",
+                "summary": "Get incidents of a vehicle",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Task ID",
-                        "name": "id",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
                         "in": "path",
                         "required": true
                     }
@@ -809,466 +1148,509 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createTaskResponse"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.incidentResponse"
+                                }
+                            }
                         }
                     }
                 }
             }
         },
-        "/user": {
+        "/inspection": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Gets all users from database",
+                "description": "Admins and maintenance staff get all inspections, drivers get their own",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "inspection"
                 ],
-                "summary": "Get users",
+                "summary": "Get inspections",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/api.getUserResponse"
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.inspectionResponse"
+                                }
                             }
                         }
                     }
                 }
             },
             "post": {
-                "description": "Creates and saves user to database",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Drivers submit a completed pre-trip or post-trip checklist. A failed critical item opens a pending maintenance record and puts the vehicle in Maintenance.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "inspection"
                 ],
-                "summary": "Create user",
+                "summary": "Submit a vehicle inspection",
                 "parameters": [
                     {
-                        "description": "User",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createUserRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.userResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/user/{id}": {
-            "get": {
-                "security": [
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
+                        "in": "formData",
+                        "required": true
+                    },
                     {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Gets user from database",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "user"
-                ],
-                "summary": "Get user",
-                "parameters": [
+                        "type": "integer",
+                        "description": "Template ID",
+                        "name": "template_id",
+                        "in": "formData",
+                        "required": true
+                    },
                     {
                         "type": "string",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "JSON array of {item_id, passed, notes}",
+                        "name": "results",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Additional notes",
+                        "name": "notes",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Photos",
+                        "name": "photos",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.getUserResponse"
+                            "$ref": "#/definitions/api.inspectionResponse"
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/inspection/template": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Updates user in database",
+                "description": "Get inspection templates, optionally filtered by vehicle type and kind",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "inspection"
                 ],
-                "summary": "Update user",
+                "summary": "Get inspection templates",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Vehicle type",
+                        "name": "vehicle_type",
+                        "in": "query"
                     },
                     {
-                        "description": "User",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createUserRequest"
-                        }
+                        "type": "string",
+                        "description": "pre_trip or post_trip",
+                        "name": "kind",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.userResponse"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.inspectionTemplateResponse"
+                                }
+                            }
                         }
                     }
                 }
             },
-            "delete": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Deletes user from database",
+                "description": "Admins define the checklist drivers fill in before or after a trip for a vehicle type",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "inspection"
                 ],
-                "summary": "Delete user",
+                "summary": "Create an inspection template",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Template",
+                        "name": "template",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createInspectionTemplateRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.deleteUserResponse"
+                            "$ref": "#/definitions/api.inspectionTemplateResponse"
                         }
                     }
                 }
             }
         },
-        "/vehicle": {
+        "/inspection/template/{id}": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get all vehicles",
+                "description": "Get an inspection template with its items",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "inspection"
+                ],
+                "summary": "Get an inspection template",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Template ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all vehicles",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/api.createVehicleResponse"
-                                }
-                            }
+                            "$ref": "#/definitions/api.inspectionTemplateResponse"
                         }
                     }
                 }
             },
-            "post": {
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create a vehicle",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Delete an inspection template, submitted inspections are kept",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "inspection"
                 ],
-                "summary": "Create a vehicle",
+                "summary": "Delete an inspection template",
                 "parameters": [
                     {
-                        "description": "Vehicle",
-                        "name": "vehicle",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createVehicleRequest"
-                        }
+                        "type": "integer",
+                        "description": "Template ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.createVehicleResponse"
-                        }
+                        "description": "OK"
                     }
                 }
             }
         },
-        "/vehicle/assign": {
-            "post": {
+        "/inspection/{id}": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Assign a vehicle to a driver",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get an inspection with its results and photos",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "inspection"
                 ],
-                "summary": "Assign a vehicle to a driver",
+                "summary": "Get an inspection",
                 "parameters": [
                     {
-                        "description": "Vehicle",
-                        "name": "vehicle",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.assignVehicleRequest"
-                        }
+                        "type": "integer",
+                        "description": "Inspection ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.userResponse"
-                            }
+                            "$ref": "#/definitions/api.inspectionResponse"
                         }
                     }
                 }
             }
         },
-        "/vehicle/register": {
-            "post": {
+        "/inspections/{vehicle_id}": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Register a vehicle",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get all inspections of a particular vehicle",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "inspection"
                 ],
-                "summary": "Register a vehicle",
+                "summary": "Get inspections of a vehicle",
                 "parameters": [
                     {
-                        "description": "Vehicle",
-                        "name": "vehicle",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.registerVehicleRequest"
-                        }
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createVehicleResponse"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.inspectionResponse"
+                                }
+                            }
                         }
                     }
                 }
             }
         },
-        "/vehicle/unassign": {
-            "post": {
+        "/jobs": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Unassign a vehicle from a driver",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Lists the scheduled jobs with their schedule, lock and last outcome",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
-                ],
-                "summary": "Unassign a vehicle from a driver",
-                "parameters": [
-                    {
-                        "description": "Vehicle",
-                        "name": "vehicle",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.assignVehicleRequest"
-                        }
-                    }
+                    "job"
                 ],
+                "summary": "Get background jobs",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/api.userResponse"
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.jobResponse"
+                                }
                             }
                         }
                     }
                 }
             }
         },
-        "/vehicle/{id}": {
-            "get": {
+        "/jobs/{name}/run": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get a vehicle",
+                "description": "Starts a run of the job outside its schedule and returns the run record, the job continues in the background",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "job"
                 ],
-                "summary": "Get a vehicle",
+                "summary": "Run a job now",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Vehicle ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "Job name",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/api.createVehicleResponse"
+                            "$ref": "#/definitions/api.jobRunResponse"
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/jobs/{name}/runs": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Update a vehicle",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns the latest runs of a job, newest first",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "job"
                 ],
-                "summary": "Update a vehicle",
+                "summary": "Get the run history of a job",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Vehicle ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "Job name",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Vehicle",
-                        "name": "vehicle",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.createVehicleRequest"
-                        }
+                        "type": "string",
+                        "description": "running, succeeded or failed",
+                        "name": "status",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.createVehicleResponse"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.jobRunResponse"
+                                }
+                            }
                         }
                     }
                 }
-            },
+            }
+        },
+        "/login": {
             "post": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Changes status of pending vehicles to Active",
+                "description": "Logs user in. Attempts are limited per client IP and per username, and repeated failures\nlock the username out for a while; both are answered with 429 and a Retry-After header.\nUnknown usernames and wrong passwords get the same 401.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "user"
                 ],
-                "summary": "Activate pending vehicle",
+                "summary": "Login user",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Vehicle ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                        "description": "User",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.loginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.loginResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/maintenance": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all maintenance records if user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Get all maintenance records of particular user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
@@ -1276,30 +1658,151 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/api.createVehicleResponse"
+                                "$ref": "#/definitions/api.maintenanceRecordResponse"
                             }
                         }
                     }
                 }
             },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a maintenance record",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Create a maintenance record",
+                "parameters": [
+                    {
+                        "description": "Maintenance",
+                        "name": "maintenance",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createMaintenanceRecordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.maintenanceRecordResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/maintenance/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a maintenance record",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Get a maintenance record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maintenance ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.maintenanceRecordResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a maintenance record",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Update a maintenance record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maintenance ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "maintenance",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateMaintenanceRecordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.maintenanceRecordResponse"
+                        }
+                    }
+                }
+            },
             "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Delete a vehicle",
+                "description": "Delete a maintenance record",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "vehicle"
+                    "maintenance"
                 ],
-                "summary": "Delete a vehicle",
+                "summary": "Delete a maintenance record",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Vehicle ID",
+                        "description": "Maintenance ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -1307,124 +1810,3208 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.maintenanceRecordResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the in-app inbox of the current user, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Get notifications",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only unread notifications",
+                        "name": "unread",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.notificationResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/preferences": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns which channels the current user receives each kind of notification on",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Get notification preferences",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/notify.Preference"
+                                }
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
                     }
+                ],
+                "description": "Sets the in-app and email channels per notification kind for the current user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Update notification preferences",
+                "parameters": [
+                    {
+                        "description": "Preferences",
+                        "name": "preferences",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/notify.Preference"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/notify.Preference"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/read": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Mark every unread notification of the current user as read",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Mark all notifications as read",
+                "responses": {
+                    "200": {
+                        "description": "Number of notifications marked as read",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/{id}/read": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Mark a notification of the current user as read",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Mark a notification as read",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Notification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.notificationResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/forgot": {
+            "post": {
+                "description": "Emails a single-use link to choose a new password to the user, if they exist and have an\nemail address. The response is the same either way, so usernames cannot be probed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Forgot password",
+                "parameters": [
+                    {
+                        "description": "User",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.forgotPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/api.forgotPasswordResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/reset": {
+            "post": {
+                "description": "Sets a new password with the token of a reset email. The token works once and expires;\nthe user's access tokens are revoked and a lockout after failed logins is lifted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "Reset",
+                        "name": "reset",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.resetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.resetPasswordResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/qualifications/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a driver qualification",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "license"
+                ],
+                "summary": "Delete a qualification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Qualification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.driverQualificationResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks that the database answers and the upload storage is writable",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.healthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/api.healthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/report/{vehicle_id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a report",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "report"
+                ],
+                "summary": "Get a report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/report/{vehicle_id}/distance": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Compares the estimated distance of each task of a vehicle with the distance it was tracked driving, recorded when the task is completed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "report"
+                ],
+                "summary": "Get estimated vs actual distances",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "vehicle_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.taskDistanceReport"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/task": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all tasks",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "task"
+                ],
+                "summary": "Get all tasks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.taskResponse"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a task",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "task"
+                ],
+                "summary": "Create a task",
+                "parameters": [
+                    {
+                        "description": "Task",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.taskResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a task",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "task"
+                ],
+                "summary": "Get a task",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.taskResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a task",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "task"
+                ],
+                "summary": "Update a task",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "task",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.taskResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a task",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "task"
+                ],
+                "summary": "Delete a task",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.taskResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tracking/ping": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Drivers submit a batch of GPS points for their vehicle and current task",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracking"
+                ],
+                "summary": "Submit GPS points",
+                "parameters": [
+                    {
+                        "description": "Batch of points",
+                        "name": "ping",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.trackingPingRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.trackingPingResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Gets all users from database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Get users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.userResponse"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates and saves user to database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Create user",
+                "parameters": [
+                    {
+                        "description": "User",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/password": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Changes the password of the logged in user, who must send the old one. All access tokens\nissued before are revoked; the response holds a new one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Change own password",
+                "parameters": [
+                    {
+                        "description": "Passwords",
+                        "name": "password",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.changePasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.changePasswordResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Gets user from database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Get user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Updates user in database. A new password logs the user out everywhere.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Update user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deletes user from database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Delete user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.deleteUserResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/license": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins and the driver get the driving license record",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "license"
+                ],
+                "summary": "Get the license of a driver",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.driverLicenseResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins create or replace the driving license record",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "license"
+                ],
+                "summary": "Save the license of a driver",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "License number",
+                        "name": "number",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated categories, e.g. B,C",
+                        "name": "categories",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Issue date (YYYY-MM-DD)",
+                        "name": "issued_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expiry date (YYYY-MM-DD)",
+                        "name": "expires_at",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Scanned license",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.driverLicenseResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/password/reset": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the password of the user with a random one, revokes their access tokens and emails\nthem a link to choose a new password. The user needs an email address and SMTP must be configured.\nWhen the email cannot be sent the password is left unchanged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Reset password of user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.resetUserPasswordResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/qualifications": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins and the driver get the qualifications of a driver",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "license"
+                ],
+                "summary": "Get qualifications of a driver",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.driverQualificationResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins record hazmat or passenger transport qualifications of a driver",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "license"
+                ],
+                "summary": "Add a qualification to a driver",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "hazmat or passenger_transport",
+                        "name": "kind",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Certificate number",
+                        "name": "number",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Issue date (YYYY-MM-DD)",
+                        "name": "issued_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expiry date (YYYY-MM-DD)",
+                        "name": "expires_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Scanned certificate",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.driverQualificationResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}/unlock": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lifts the lockout of a user after failed logins and forgets the earlier lockouts",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Unlock user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.unlockUserResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/vehicle": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all vehicles",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Get all vehicles",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.vehicleResponse"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a vehicle",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Create a vehicle",
+                "parameters": [
+                    {
+                        "description": "Vehicle",
+                        "name": "vehicle",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createVehicleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/vehicle/assign": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Assign a vehicle to a driver",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Assign a vehicle to a driver",
+                "parameters": [
+                    {
+                        "description": "Vehicle",
+                        "name": "vehicle",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.assignVehicleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/vehicle/register": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Register a vehicle",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Register a vehicle",
+                "parameters": [
+                    {
+                        "description": "Vehicle",
+                        "name": "vehicle",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createVehicleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/vehicle/unassign": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Unassign a vehicle from a driver",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Unassign a vehicle from a driver",
+                "parameters": [
+                    {
+                        "description": "Vehicle",
+                        "name": "vehicle",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.assignVehicleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.userResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/vehicle/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a vehicle",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Get a vehicle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update a vehicle",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Update a vehicle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "vehicle",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.updateVehicleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Changes status of pending vehicles to Active",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Activate pending vehicle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a vehicle",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "vehicle"
+                ],
+                "summary": "Delete a vehicle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/vehicle/{id}/documents": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins and the assigned driver get the documents of a vehicle",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "document"
+                ],
+                "summary": "Get documents of a vehicle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.vehicleDocumentResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins attach insurance policies, registration and technical inspection certificates to a vehicle",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "document"
+                ],
+                "summary": "Add a vehicle document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "insurance, registration or technical_inspection",
+                        "name": "type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document number",
+                        "name": "number",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Issuer",
+                        "name": "issuer",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of validity (YYYY-MM-DD)",
+                        "name": "valid_from",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of validity (YYYY-MM-DD)",
+                        "name": "valid_to",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Scanned document",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleDocumentResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/vehicle/{id}/track": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the GPS points of a vehicle between from and to (RFC3339), the last 24 hours by default.\nThe window may span at most 7 days.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tracking"
+                ],
+                "summary": "Get the path of a vehicle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Vehicle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the window",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the window",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.vehicleTrackResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all registered webhook endpoints",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Get webhook endpoints",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.webhookEndpointResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins register an endpoint for event types such as task.*, fueling.created or *. The signing secret is only returned here.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Register a webhook endpoint",
+                "parameters": [
+                    {
+                        "description": "Webhook endpoint",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookEndpointRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookEndpointResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a webhook endpoint",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Get a webhook endpoint",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookEndpointResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Change the URL, event types or active flag of a webhook endpoint",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Update a webhook endpoint",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook endpoint",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookEndpointRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookEndpointResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a webhook endpoint, pending deliveries to it are dropped",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Delete a webhook endpoint",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookEndpointResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns deliveries to the endpoint, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Get the delivery log of a webhook endpoint",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "pending, succeeded or failed",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.webhookDeliveryResponse"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries/{delivery_id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sends the payload of a past delivery again as a new delivery and returns its outcome",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Redeliver a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "delivery_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.webhookDeliveryResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.AuctionVehicleResponse": {
+            "type": "object",
+            "properties": {
+                "closed_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "images": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ImageResponse"
+                    }
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                },
+                "winner_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is one of bad_request, unauthorized, forbidden, not_found, conflict, payload_too_large,\ntoo_many_requests, validation_failed, internal or bad_gateway",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apperr.Code"
+                        }
+                    ]
+                },
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apperr.FieldError"
+                    }
+                }
+            }
+        },
+        "api.FuelingRecordResponse": {
+            "type": "object",
+            "properties": {
+                "after_fueling_image": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "number"
+                },
+                "before_fueling_image": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "fueling_person_id": {
+                    "type": "integer"
+                },
+                "gas_station": {
+                    "description": "omitempty if the field is optional",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "notes": {
+                    "description": "omitempty if the field is optional",
+                    "type": "string"
+                },
+                "total_cost": {
+                    "type": "number"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ImageResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.Report": {
+            "type": "object",
+            "properties": {
+                "fueling_records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.FuelingRecordResponse"
+                    }
+                },
+                "maintenance_records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.maintenanceRecordResponse"
+                    }
+                },
+                "vehicle": {
+                    "$ref": "#/definitions/api.vehicleResponse"
+                }
+            }
+        },
+        "api.alertResponse": {
+            "type": "object",
+            "properties": {
+                "CreatedAt": {
+                    "type": "string"
+                },
+                "ID": {
+                    "type": "integer"
+                },
+                "document_id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "severity": {
+                    "type": "string"
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.assignVehicleRequest": {
+            "type": "object",
+            "required": [
+                "user_id",
+                "vehicle_id"
+            ],
+            "properties": {
+                "user_id": {
+                    "type": "integer"
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.changePasswordRequest": {
+            "type": "object",
+            "required": [
+                "new_password",
+                "old_password"
+            ],
+            "properties": {
+                "new_password": {
+                    "type": "string",
+                    "maxLength": 72
+                },
+                "old_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.changePasswordResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "description": "AccessToken replaces the tokens revoked by the change",
+                    "type": "string"
+                }
+            }
+        },
+        "api.closeAuctionRequest": {
+            "type": "object",
+            "required": [
+                "winner_id"
+            ],
+            "properties": {
+                "winner_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.createInspectionTemplateRequest": {
+            "type": "object",
+            "required": [
+                "items",
+                "kind",
+                "name",
+                "vehicle_type"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/api.inspectionTemplateItemRequest"
+                    }
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "pre_trip",
+                        "post_trip"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 1
+                },
+                "vehicle_type": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 1
+                }
+            }
+        },
+        "api.createMaintenanceRecordRequest": {
+            "type": "object",
+            "required": [
+                "status",
+                "total_cost",
+                "vehicle_id"
+            ],
+            "properties": {
+                "maintenance_date": {
+                    "type": "string"
+                },
+                "maintenance_person_id": {
+                    "type": "integer"
+                },
+                "mileage_at_service": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "notes": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "service_type": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "Pending",
+                        "Done"
+                    ]
+                },
+                "total_cost": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.createTaskRequest": {
+            "type": "object",
+            "required": [
+                "driver_id",
+                "end_latitude",
+                "end_longitude",
+                "start_latitude",
+                "start_longitude",
+                "status"
+            ],
+            "properties": {
+                "driver_id": {
+                    "type": "integer"
+                },
+                "end_latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "end_longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "start_latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "start_longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "assigned",
+                        "in_progress",
+                        "delayed",
+                        "completed",
+                        "canceled"
+                    ]
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                },
+                "waypoints": {
+                    "type": "array",
+                    "maxItems": 25,
+                    "items": {
+                        "$ref": "#/definitions/api.taskWaypointRequest"
+                    }
+                }
+            }
+        },
+        "api.createUserRequest": {
+            "type": "object",
+            "required": [
+                "first_name",
+                "last_name",
+                "password",
+                "role",
+                "username"
+            ],
+            "properties": {
+                "address": {
+                    "type": "string",
+                    "maxLength": 200
+                },
+                "driving_license_number": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 1
+                },
+                "goverment_id": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "last_name": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 1
+                },
+                "middle_name": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 1
+                },
+                "phone_number": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "Admin",
+                        "Driver",
+                        "Fueling_person",
+                        "Maintenance_person"
+                    ]
+                },
+                "status": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 3
+                }
+            }
+        },
+        "api.createVehicleRequest": {
+            "type": "object",
+            "required": [
+                "license_plate",
+                "vin"
+            ],
+            "properties": {
+                "car_model": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "color": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "current_mileage": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "last_maintenance": {
+                    "type": "string"
+                },
+                "license_plate": {
+                    "type": "string",
+                    "maxLength": 20,
+                    "minLength": 1
+                },
+                "make": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "next_maintenance": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "sitting_capacity": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "type": {
+                    "type": "string"
+                },
+                "vin": {
+                    "type": "string"
+                },
+                "year": {
+                    "type": "integer",
+                    "minimum": 1900
+                }
+            }
+        },
+        "api.deleteUserResponse": {
+            "type": "object"
+        },
+        "api.driverLicenseResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "issued_at": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.driverQualificationResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "issued_at": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.fleetPositionResponse": {
+            "type": "object",
+            "properties": {
+                "driver_id": {
+                    "type": "integer"
+                },
+                "heading": {
+                    "type": "number"
+                },
+                "lat": {
+                    "type": "number"
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "speed": {
+                    "type": "number"
+                },
+                "task_id": {
+                    "type": "integer"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.forgotPasswordRequest": {
+            "type": "object",
+            "required": [
+                "username"
+            ],
+            "properties": {
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.forgotPasswordResponse": {
+            "type": "object"
+        },
+        "api.geofenceEventResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "driver_id": {
+                    "type": "integer"
+                },
+                "geofence_id": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "task_id": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "enter",
+                        "exit",
+                        "violation"
+                    ]
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.geofenceRequest": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "category": {
+                    "type": "string",
+                    "enum": [
+                        "depot",
+                        "customer_site",
+                        "restricted",
+                        "operating_area"
+                    ]
+                },
+                "center_latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "center_longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 1
+                },
+                "permitted_from": {
+                    "type": "string",
+                    "example": "06:00"
+                },
+                "permitted_to": {
+                    "type": "string",
+                    "example": "22:00"
+                },
+                "polygon": {
+                    "type": "array",
+                    "maxItems": 500,
+                    "items": {
+                        "$ref": "#/definitions/geo.Point"
+                    }
+                },
+                "radius_meters": {
+                    "type": "number"
+                },
+                "shape": {
+                    "type": "string",
+                    "enum": [
+                        "circle",
+                        "polygon"
+                    ]
+                },
+                "time_zone": {
+                    "description": "TimeZone is the IANA time zone of the permitted hours, UTC when unset",
+                    "type": "string",
+                    "example": "Asia/Almaty"
+                }
+            }
+        },
+        "api.geofenceResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "active": {
+                    "type": "boolean"
+                },
+                "category": {
+                    "type": "string",
+                    "enum": [
+                        "depot",
+                        "customer_site",
+                        "restricted",
+                        "operating_area"
+                    ]
+                },
+                "center_latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "center_longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 1
+                },
+                "permitted_from": {
+                    "type": "string",
+                    "example": "06:00"
+                },
+                "permitted_to": {
+                    "type": "string",
+                    "example": "22:00"
+                },
+                "polygon": {
+                    "type": "array",
+                    "maxItems": 500,
+                    "items": {
+                        "$ref": "#/definitions/geo.Point"
+                    }
+                },
+                "radius_meters": {
+                    "type": "number"
+                },
+                "shape": {
+                    "type": "string",
+                    "enum": [
+                        "circle",
+                        "polygon"
+                    ]
+                },
+                "time_zone": {
+                    "description": "TimeZone is the IANA time zone of the permitted hours, UTC when unset",
+                    "type": "string",
+                    "example": "Asia/Almaty"
+                }
+            }
+        },
+        "api.healthResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "Checks maps each readiness check to \"ok\" or the reason it failed",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.incidentResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "driver_id": {
+                    "type": "integer"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "maintenance_record_id": {
+                    "type": "integer"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "photos": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "repair_cost": {
+                    "type": "number"
+                },
+                "reported_by_id": {
+                    "type": "integer"
+                },
+                "resolution_notes": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.IncidentStatus"
+                },
+                "third_parties": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.IncidentThirdParty"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.inspectionResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "driver_id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "maintenance_record_id": {
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "passed": {
+                    "type": "boolean"
+                },
+                "photos": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.inspectionResultResponse"
+                    }
+                },
+                "template_id": {
+                    "type": "integer"
+                },
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.inspectionResultResponse": {
+            "type": "object",
+            "properties": {
+                "critical": {
+                    "type": "boolean"
+                },
+                "item_id": {
+                    "type": "integer"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "passed": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.inspectionTemplateItemRequest": {
+            "type": "object",
+            "required": [
+                "label"
+            ],
+            "properties": {
+                "critical": {
+                    "type": "boolean"
+                },
+                "label": {
+                    "type": "string",
+                    "maxLength": 200,
+                    "minLength": 1
+                }
+            }
+        },
+        "api.inspectionTemplateItemResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "critical": {
+                    "type": "boolean"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "position": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.inspectionTemplateResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.inspectionTemplateItemResponse"
+                    }
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "vehicle_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.jobResponse": {
+            "type": "object",
+            "properties": {
+                "last_run_at": {
+                    "type": "string"
+                },
+                "last_status": {
+                    "type": "string"
+                },
+                "locked_by": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "schedule": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.jobRunResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "job_name": {
+                    "type": "string"
+                },
+                "run_by": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string"
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.AuctionVehicleResponse": {
+        },
+        "api.loginRequest": {
             "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
             "properties": {
-                "details": {
+                "password": {
                     "type": "string"
                 },
-                "id": {
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.loginResponse": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/api.userResponse"
+                }
+            }
+        },
+        "api.maintenanceRecordResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
                     "type": "integer"
                 },
-                "images": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/api.ImageResponse"
-                    }
+                "maintenance_date": {
+                    "type": "string"
+                },
+                "maintenance_person_id": {
+                    "type": "integer"
+                },
+                "mileage_at_service": {
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "service_type": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_cost": {
+                    "type": "number"
                 },
                 "vehicle_id": {
                     "type": "integer"
                 }
             }
         },
-        "api.ErrorResponse": {
+        "api.notificationResponse": {
             "type": "object",
             "properties": {
-                "error": {
+                "CreatedAt": {
+                    "type": "string"
+                },
+                "ID": {
+                    "type": "integer"
+                },
+                "body": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "read_at": {
+                    "type": "string"
+                },
+                "subject": {
                     "type": "string"
                 }
             }
         },
-        "api.FuelingRecordResponse": {
+        "api.resetPasswordRequest": {
             "type": "object",
+            "required": [
+                "new_password",
+                "token"
+            ],
             "properties": {
-                "after_fueling_image": {
+                "new_password": {
+                    "type": "string",
+                    "maxLength": 72
+                },
+                "token": {
                     "type": "string"
+                }
+            }
+        },
+        "api.resetPasswordResponse": {
+            "type": "object"
+        },
+        "api.resetUserPasswordResponse": {
+            "type": "object"
+        },
+        "api.taskDistanceReport": {
+            "type": "object",
+            "properties": {
+                "actual_distance": {
+                    "type": "number"
                 },
-                "amount": {
+                "difference": {
+                    "description": "Difference is the actual distance minus the road distance, or the great-circle estimate without one",
                     "type": "number"
                 },
-                "before_fueling_image": {
-                    "type": "string"
+                "estimated_distance": {
+                    "type": "number"
                 },
-                "created_at": {
+                "road_distance": {
+                    "type": "number"
+                },
+                "status": {
                     "type": "string"
                 },
-                "fueling_person_id": {
+                "task_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.taskResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
                     "type": "integer"
                 },
-                "gas_station": {
-                    "description": "omitempty if the field is optional",
+                "driver_id": {
+                    "type": "integer"
+                },
+                "end_latitude": {
+                    "type": "number"
+                },
+                "end_longitude": {
+                    "type": "number"
+                },
+                "end_time": {
                     "type": "string"
                 },
-                "id": {
+                "estimated_distance": {
+                    "type": "number"
+                },
+                "estimated_minutes": {
                     "type": "integer"
                 },
                 "notes": {
-                    "description": "omitempty if the field is optional",
                     "type": "string"
                 },
-                "total_cost": {
+                "road_distance": {
                     "type": "number"
                 },
-                "updated_at": {
+                "start_latitude": {
+                    "type": "number"
+                },
+                "start_longitude": {
+                    "type": "number"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
                 },
                 "vehicle_id": {
                     "type": "integer"
+                },
+                "waypoints": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.taskWaypointResponse"
+                    }
                 }
             }
         },
-        "api.ImageResponse": {
+        "api.taskWaypointRequest": {
             "type": "object",
+            "required": [
+                "latitude",
+                "longitude"
+            ],
             "properties": {
-                "id": {
+                "latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "sequence": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.taskWaypointResponse": {
+            "type": "object",
+            "properties": {
+                "ID": {
                     "type": "integer"
                 },
-                "url": {
+                "arrived_at": {
                     "type": "string"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "sequence": {
+                    "type": "integer"
                 }
             }
         },
-        "api.ReportForSwagger": {
+        "api.trackPointResponse": {
             "type": "object",
             "properties": {
-                "fueling_records": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/api.myFuelingRecord"
-                    }
+                "heading": {
+                    "type": "number"
                 },
-                "maintenance_records": {
+                "lat": {
+                    "type": "number"
+                },
+                "lon": {
+                    "type": "number"
+                },
+                "speed": {
+                    "type": "number"
+                },
+                "task_id": {
+                    "type": "integer"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.trackingPingRequest": {
+            "type": "object",
+            "required": [
+                "points",
+                "vehicle_id"
+            ],
+            "properties": {
+                "points": {
                     "type": "array",
+                    "maxItems": 500,
+                    "minItems": 1,
                     "items": {
-                        "$ref": "#/definitions/api.myMaintenanceRecord"
+                        "$ref": "#/definitions/api.trackingPoint"
                     }
                 },
-                "vehicle": {
-                    "$ref": "#/definitions/api.createVehicleResponse"
+                "task_id": {
+                    "type": "integer"
+                },
+                "vehicle_id": {
+                    "type": "integer"
                 }
             }
         },
-        "api.assignVehicleRequest": {
+        "api.trackingPingResponse": {
             "type": "object",
             "properties": {
-                "user_id": {
+                "accepted": {
                     "type": "integer"
+                }
+            }
+        },
+        "api.trackingPoint": {
+            "type": "object",
+            "required": [
+                "lat",
+                "lon"
+            ],
+            "properties": {
+                "heading": {
+                    "type": "number",
+                    "minimum": 0
                 },
-                "vehicle_id": {
+                "lat": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "lon": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "speed": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.unlockUserResponse": {
+            "type": "object"
+        },
+        "api.updateIncidentStatusRequest": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "maintenance_record_id": {
                     "type": "integer"
+                },
+                "repair_cost": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "resolution_notes": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "status": {
+                    "enum": [
+                        "UnderReview",
+                        "RepairScheduled",
+                        "Closed"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.IncidentStatus"
+                        }
+                    ]
                 }
             }
         },
-        "api.createMaintenanceRecordRequest": {
+        "api.updateMaintenanceRecordRequest": {
             "type": "object",
             "properties": {
                 "maintenance_date": {
@@ -1434,124 +5021,241 @@ const docTemplate = `{
                     "type": "integer"
                 },
                 "mileage_at_service": {
-                    "type": "integer"
+                    "type": "integer",
+                    "minimum": 0
                 },
                 "notes": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 1000
                 },
                 "service_type": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 100
                 },
                 "status": {
-                    "type": "string"
+                    "type": "string",
+                    "enum": [
+                        "Pending",
+                        "Done"
+                    ]
                 },
                 "total_cost": {
-                    "type": "number"
-                },
-                "vehicle_id": {
-                    "type": "integer"
+                    "type": "number",
+                    "minimum": 0
                 }
             }
         },
-        "api.createMaintenanceRecordResponse": {
+        "api.updateTaskRequest": {
             "type": "object",
             "properties": {
-                "maintenance_date": {
-                    "type": "string"
-                },
-                "maintenance_person_id": {
+                "driver_id": {
                     "type": "integer"
                 },
-                "mileage_at_service": {
-                    "type": "integer"
+                "end_latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
                 },
-                "notes": {
-                    "type": "string"
+                "end_longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
                 },
-                "service_type": {
+                "end_time": {
                     "type": "string"
                 },
-                "status": {
+                "notes": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "start_latitude": {
+                    "type": "number",
+                    "maximum": 90,
+                    "minimum": -90
+                },
+                "start_longitude": {
+                    "type": "number",
+                    "maximum": 180,
+                    "minimum": -180
+                },
+                "start_time": {
                     "type": "string"
                 },
-                "total_cost": {
-                    "type": "number"
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "assigned",
+                        "in_progress",
+                        "delayed",
+                        "completed",
+                        "canceled"
+                    ]
                 },
                 "vehicle_id": {
                     "type": "integer"
+                },
+                "waypoints": {
+                    "type": "array",
+                    "maxItems": 25,
+                    "items": {
+                        "$ref": "#/definitions/api.taskWaypointRequest"
+                    }
                 }
             }
         },
-        "api.createTaskRequest": {
+        "api.updateUserRequest": {
             "type": "object",
+            "required": [
+                "first_name",
+                "last_name",
+                "role",
+                "username"
+            ],
             "properties": {
-                "driver_id": {
-                    "type": "integer"
+                "address": {
+                    "type": "string",
+                    "maxLength": 200
                 },
-                "end_latitude": {
-                    "type": "number"
+                "driving_license_number": {
+                    "type": "string",
+                    "maxLength": 30
                 },
-                "end_longitude": {
-                    "type": "number"
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 1
+                },
+                "goverment_id": {
+                    "type": "string",
+                    "maxLength": 30
+                },
+                "last_name": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 1
+                },
+                "middle_name": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 1
+                },
+                "phone_number": {
+                    "type": "string",
+                    "maxLength": 20
                 },
-                "end_time": {
-                    "type": "string"
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "Admin",
+                        "Driver",
+                        "Fueling_person",
+                        "Maintenance_person"
+                    ]
                 },
-                "notes": {
-                    "type": "string"
+                "status": {
+                    "type": "string",
+                    "maxLength": 30
                 },
-                "start_latitude": {
-                    "type": "number"
+                "username": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 3
+                }
+            }
+        },
+        "api.updateVehicleDocumentRequest": {
+            "type": "object",
+            "properties": {
+                "issuer": {
+                    "type": "string",
+                    "maxLength": 100
                 },
-                "start_longitude": {
-                    "type": "number"
+                "number": {
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 1
                 },
-                "start_time": {
+                "valid_from": {
                     "type": "string"
                 },
-                "status": {
+                "valid_to": {
                     "type": "string"
                 }
             }
         },
-        "api.createTaskResponse": {
+        "api.updateVehicleRequest": {
             "type": "object",
             "properties": {
-                "ID": {
-                    "type": "integer"
+                "car_model": {
+                    "type": "string",
+                    "maxLength": 50
                 },
-                "driver_id": {
-                    "type": "integer"
+                "color": {
+                    "type": "string",
+                    "maxLength": 30
                 },
-                "end_latitude": {
-                    "type": "number"
+                "current_mileage": {
+                    "type": "integer",
+                    "minimum": 0
                 },
-                "end_longitude": {
-                    "type": "number"
+                "last_maintenance": {
+                    "type": "string"
                 },
-                "end_time": {
+                "license_plate": {
+                    "type": "string",
+                    "maxLength": 20,
+                    "minLength": 1
+                },
+                "make": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "next_maintenance": {
                     "type": "string"
                 },
                 "notes": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 1000
                 },
-                "start_latitude": {
-                    "type": "number"
+                "sitting_capacity": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
                 },
-                "start_longitude": {
-                    "type": "number"
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "Active",
+                        "Inactive",
+                        "Maintenance",
+                        "Pending"
+                    ]
                 },
-                "start_time": {
+                "type": {
                     "type": "string"
                 },
-                "status": {
+                "vin": {
                     "type": "string"
+                },
+                "year": {
+                    "type": "integer",
+                    "minimum": 1900
                 }
             }
         },
-        "api.createUserRequest": {
+        "api.userResponse": {
             "type": "object",
             "properties": {
+                "ID": {
+                    "type": "integer"
+                },
                 "address": {
                     "type": "string"
                 },
@@ -1573,9 +5277,6 @@ const docTemplate = `{
                 "middle_name": {
                     "type": "string"
                 },
-                "password": {
-                    "type": "string"
-                },
                 "phone_number": {
                     "type": "string"
                 },
@@ -1590,54 +5291,39 @@ const docTemplate = `{
                 }
             }
         },
-        "api.createVehicleRequest": {
+        "api.vehicleDocumentResponse": {
             "type": "object",
             "properties": {
-                "assigned_driver": {
-                    "type": "integer"
-                },
-                "car_model": {
-                    "type": "string"
-                },
-                "color": {
-                    "type": "string"
-                },
-                "current_mileage": {
+                "ID": {
                     "type": "integer"
                 },
-                "last_maintenance": {
-                    "type": "string"
-                },
-                "license_plate": {
-                    "type": "string"
+                "expired": {
+                    "type": "boolean"
                 },
-                "make": {
+                "file": {
                     "type": "string"
                 },
-                "next_maintenance": {
+                "issuer": {
                     "type": "string"
                 },
-                "notes": {
+                "number": {
                     "type": "string"
                 },
-                "sitting_capacity": {
-                    "type": "integer"
-                },
-                "status": {
+                "type": {
                     "type": "string"
                 },
-                "type": {
+                "valid_from": {
                     "type": "string"
                 },
-                "vin": {
+                "valid_to": {
                     "type": "string"
                 },
-                "year": {
+                "vehicle_id": {
                     "type": "integer"
                 }
             }
         },
-        "api.createVehicleResponse": {
+        "api.vehicleResponse": {
             "type": "object",
             "properties": {
                 "ID": {
@@ -1687,213 +5373,253 @@ const docTemplate = `{
                 }
             }
         },
-        "api.deleteUserResponse": {
-            "type": "object"
-        },
-        "api.getUserResponse": {
+        "api.vehicleTrackResponse": {
             "type": "object",
             "properties": {
-                "ID": {
-                    "type": "integer"
+                "distance": {
+                    "description": "great-circle km along the path",
+                    "type": "number"
                 },
-                "address": {
+                "from": {
                     "type": "string"
                 },
-                "driving_license_number": {
-                    "type": "string"
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.trackPointResponse"
+                    }
                 },
-                "email": {
+                "to": {
                     "type": "string"
                 },
-                "first_name": {
+                "vehicle_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.webhookDeliveryResponse": {
+            "type": "object",
+            "properties": {
+                "CreatedAt": {
                     "type": "string"
                 },
-                "goverment_id": {
-                    "type": "string"
+                "ID": {
+                    "type": "integer"
                 },
-                "last_name": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "delivered_at": {
                     "type": "string"
                 },
-                "middle_name": {
+                "endpoint_id": {
+                    "type": "integer"
+                },
+                "event_type": {
                     "type": "string"
                 },
-                "phone_number": {
+                "last_error": {
                     "type": "string"
                 },
-                "role": {
+                "next_attempt_at": {
                     "type": "string"
                 },
-                "status": {
+                "payload": {
                     "type": "string"
                 },
-                "username": {
+                "response_code": {
+                    "type": "integer"
+                },
+                "status": {
                     "type": "string"
                 }
             }
         },
-        "api.loginRequest": {
+        "api.webhookEndpointRequest": {
             "type": "object",
             "properties": {
-                "password": {
-                    "type": "string"
+                "active": {
+                    "type": "boolean"
                 },
-                "username": {
+                "description": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
                     "type": "string"
                 }
             }
         },
-        "api.loginResponse": {
+        "api.webhookEndpointResponse": {
             "type": "object",
             "properties": {
-                "access_token": {
+                "CreatedAt": {
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/api.userResponse"
-                }
-            }
-        },
-        "api.myFuelingRecord": {
-            "type": "object",
-            "properties": {
-                "after_fueling_image": {
-                    "type": "string"
+                "ID": {
+                    "type": "integer"
                 },
-                "amount": {
-                    "type": "number"
+                "active": {
+                    "type": "boolean"
                 },
-                "before_fueling_image": {
+                "description": {
                     "type": "string"
                 },
-                "fueling_person_id": {
-                    "type": "integer"
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "gas_station": {
+                "secret": {
+                    "description": "only returned when the endpoint is created",
                     "type": "string"
                 },
-                "notes": {
+                "url": {
                     "type": "string"
-                },
-                "total_cost": {
-                    "type": "number"
-                },
-                "vehicle_id": {
-                    "type": "integer"
                 }
             }
         },
-        "api.myMaintenanceRecord": {
+        "apperr.Code": {
+            "type": "string",
+            "enum": [
+                "bad_request",
+                "unauthorized",
+                "forbidden",
+                "not_found",
+                "conflict",
+                "payload_too_large",
+                "too_many_requests",
+                "validation_failed",
+                "internal",
+                "bad_gateway"
+            ],
+            "x-enum-varnames": [
+                "CodeBadRequest",
+                "CodeUnauthorized",
+                "CodeForbidden",
+                "CodeNotFound",
+                "CodeConflict",
+                "CodeTooLarge",
+                "CodeTooMany",
+                "CodeValidation",
+                "CodeInternal",
+                "CodeBadGateway"
+            ]
+        },
+        "apperr.FieldError": {
             "type": "object",
             "properties": {
-                "maintenance_date": {
-                    "type": "string"
-                },
-                "maintenance_person_id": {
-                    "type": "integer"
-                },
-                "mileage_at_service": {
-                    "type": "integer"
-                },
-                "notes": {
+                "field": {
                     "type": "string"
                 },
-                "service_type": {
-                    "type": "string"
-                },
-                "status": {
+                "message": {
                     "type": "string"
-                },
-                "total_cost": {
-                    "description": "Parts               []Part             ` + "`" + `gorm:\"foreignKey:ID\" json:\"parts\"` + "`" + `",
-                    "type": "number"
-                },
-                "vehicle_id": {
-                    "type": "integer"
                 }
             }
         },
-        "api.registerVehicleRequest": {
+        "events.Event": {
             "type": "object",
             "properties": {
-                "assigned_driver": {
+                "data": {},
+                "driver_id": {
                     "type": "integer"
                 },
-                "car_model": {
-                    "type": "string"
-                },
-                "color": {
-                    "type": "string"
-                },
-                "current_mileage": {
+                "id": {
+                    "description": "outbox id, stable across redeliveries",
                     "type": "integer"
                 },
-                "last_maintenance": {
-                    "type": "string"
-                },
-                "license_plate": {
-                    "type": "string"
-                },
-                "make": {
-                    "type": "string"
-                },
-                "next_maintenance": {
-                    "type": "string"
-                },
-                "notes": {
+                "occurred_at": {
                     "type": "string"
                 },
-                "sitting_capacity": {
-                    "type": "integer"
-                },
                 "type": {
                     "type": "string"
                 },
-                "vin": {
-                    "type": "string"
-                },
-                "year": {
+                "vehicle_id": {
                     "type": "integer"
                 }
             }
         },
-        "api.userResponse": {
+        "geo.Point": {
             "type": "object",
             "properties": {
-                "ID": {
-                    "type": "integer"
-                },
-                "address": {
-                    "type": "string"
-                },
-                "driving_license_number": {
-                    "type": "string"
-                },
-                "email": {
-                    "type": "string"
+                "latitude": {
+                    "type": "number"
                 },
-                "first_name": {
+                "longitude": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.IncidentStatus": {
+            "type": "string",
+            "enum": [
+                "Reported",
+                "UnderReview",
+                "RepairScheduled",
+                "Closed"
+            ],
+            "x-enum-varnames": [
+                "IncidentStatusReported",
+                "IncidentStatusUnderReview",
+                "IncidentStatusRepairScheduled",
+                "IncidentStatusClosed"
+            ]
+        },
+        "models.IncidentThirdParty": {
+            "type": "object",
+            "properties": {
+                "insurance_company": {
                     "type": "string"
                 },
-                "goverment_id": {
+                "insurance_policy": {
                     "type": "string"
                 },
-                "last_name": {
+                "license_plate": {
                     "type": "string"
                 },
-                "middle_name": {
+                "name": {
                     "type": "string"
                 },
                 "phone_number": {
                     "type": "string"
+                }
+            }
+        },
+        "notify.Kind": {
+            "type": "string",
+            "enum": [
+                "task_assigned",
+                "vehicle_assigned",
+                "maintenance_due",
+                "document_expiring",
+                "auction_won"
+            ],
+            "x-enum-varnames": [
+                "TaskAssigned",
+                "VehicleAssigned",
+                "MaintenanceDue",
+                "DocumentExpiring",
+                "AuctionWon"
+            ]
+        },
+        "notify.Preference": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "boolean"
                 },
-                "role": {
-                    "type": "string"
-                },
-                "status": {
-                    "type": "string"
+                "in_app": {
+                    "type": "boolean"
                 },
-                "username": {
-                    "type": "string"
+                "kind": {
+                    "$ref": "#/definitions/notify.Kind"
                 }
             }
         }
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types use a dotted "<entity>.<action>" form so consumers can match by prefix
+const (
+	TaskStatusChanged     = "task.status_changed"
+	VehicleStatusChanged  = "vehicle.status_changed"
+	FuelingCreated        = "fueling.created"
+	MaintenanceCreated    = "maintenance.created"
+	AlertRaised           = "alert.raised"
+	IncidentReported      = "incident.reported"
+	IncidentStatusChanged = "incident.status_changed"
+	LocationPinged        = "tracking.ping"
+	WaypointArrived       = "task.waypoint_arrived"
+	GeofenceEntered       = "geofence.enter"
+	GeofenceExited        = "geofence.exit"
+	GeofenceViolation     = "geofence.violation"
+	VehicleAssigned       = "vehicle.assigned"
+	VehicleUnassigned     = "vehicle.unassigned"
+	AuctionCreated        = "auction.created"
+	AuctionClosed         = "auction.closed"
+	TaskAssigned          = "task.assigned"
+	MaintenanceDue        = "maintenance.due"
+)
+
+// subscriberBuffer is how many events a slow subscriber may lag behind before events are dropped for it
+const subscriberBuffer = 64
+
+type Event struct {
+	ID         *uint       `json:"id,omitempty"` // outbox id, stable across redeliveries
+	Type       string      `json:"type"`
+	VehicleID  *uint       `json:"vehicle_id,omitempty"`
+	DriverID   *uint       `json:"driver_id,omitempty"`
+	Data       interface{} `json:"data"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Category returns the entity part of the event type, e.g. "task" for "task.status_changed"
+func (e Event) Category() string {
+	category, _, _ := strings.Cut(e.Type, ".")
+	return category
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter func(Event) bool
+}
+
+// Hub fans published events out to in-process subscribers
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber receiving the events accepted by filter, or all events if filter is nil
+func (h *Hub) Subscribe(filter func(Event) bool) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers the event to every interested subscriber without blocking on slow ones
+func (h *Hub) Publish(evt Event) {
+	if evt.OccurredAt.IsZero() {
+		evt.OccurredAt = time.Now()
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers {
+		if sub.filter != nil && !sub.filter(evt) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription, subscribers see their channel closed. Later subscriptions are closed right away.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,54 @@
+package geo
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Haversine returns the great-circle distance between two points in kilometers
+func Haversine(a, b Point) float64 {
+	lat1 := toRadians(a.Latitude)
+	lat2 := toRadians(b.Latitude)
+	dLat := toRadians(b.Latitude - a.Latitude)
+	dLon := toRadians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// PathDistance returns the great-circle length of a path visiting the points in order
+func PathDistance(points []Point) float64 {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += Haversine(points[i-1], points[i])
+	}
+	return total
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// WithinRadius reports whether p lies within radiusMeters of center
+func WithinRadius(center, p Point, radiusMeters float64) bool {
+	return Haversine(center, p)*1000 <= radiusMeters
+}
+
+// InPolygon reports whether p lies inside the polygon using ray casting.
+// Polygons are small enough for latitude and longitude to be treated as planar coordinates.
+func InPolygon(polygon []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,84 @@
+// Package logging sets up structured logging with log/slog. Log lines written with a request
+// context carry the attributes collected for that request, such as its ID and the user.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New returns a logger writing records of at least level to w, as JSON unless format is FormatText
+func New(w io.Writer, format string, level slog.Leveler) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if format == FormatText {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+type attrsKey struct{}
+
+// requestAttrs is shared by every context derived from the request, so attributes added deep
+// in the middleware chain still show up on the access log written by an outer middleware
+type requestAttrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// NewContext returns a context that collects the attributes given to AddAttrs
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attrsKey{}, &requestAttrs{})
+}
+
+// AddAttrs attaches attrs to every later log line written with ctx. It does nothing unless ctx
+// comes from NewContext.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	scope, ok := ctx.Value(attrsKey{}).(*requestAttrs)
+	if !ok {
+		return
+	}
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	scope.attrs = append(scope.attrs, attrs...)
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	scope, ok := ctx.Value(attrsKey{}).(*requestAttrs)
+	if !ok {
+		return nil
+	}
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	return append([]slog.Attr(nil), scope.attrs...)
+}
+
+// contextHandler adds the attributes of the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(attrsFrom(ctx)...)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// records decodes the JSON lines written to buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, FormatJSON, slog.LevelInfo)
+
+	ctx := NewContext(context.Background())
+	AddAttrs(ctx, slog.String("request_id", "abc"))
+	// attributes added later, e.g. by the auth middleware, reach contexts derived earlier
+	derived, cancel := context.WithCancel(ctx)
+	defer cancel()
+	AddAttrs(ctx, slog.String("user", "driver"))
+	logger.InfoContext(derived, "hello")
+	logger.Info("no request")
+	AddAttrs(context.Background(), slog.String("ignored", "yes"))
+
+	got := records(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("got %d records, want 2", len(got))
+	}
+	if got[0]["request_id"] != "abc" || got[0]["user"] != "driver" {
+		t.Errorf("record = %v, want the request attributes", got[0])
+	}
+	if _, ok := got[1]["request_id"]; ok {
+		t.Errorf("record without a request context = %v", got[1])
+	}
+}
+
+func TestGormLogger(t *testing.T) {
+	query := func() (string, int64) { return "SELECT 1", 1 }
+	tests := []struct {
+		name    string
+		level   slog.Level
+		elapsed time.Duration
+		err     error
+		want    string
+	}{
+		{"failed query", slog.LevelInfo, 0, errors.New("boom"), "query failed"},
+		{"missing record", slog.LevelInfo, 0, gorm.ErrRecordNotFound, ""},
+		{"slow query", slog.LevelInfo, time.Second, nil, "slow query"},
+		{"query at info", slog.LevelInfo, 0, nil, ""},
+		{"query at debug", slog.LevelDebug, 0, nil, "query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := NewGormLogger(New(&buf, FormatJSON, tt.level), 100*time.Millisecond)
+			logger.Trace(context.Background(), time.Now().Add(-tt.elapsed), query, tt.err)
+			got := records(t, &buf)
+			switch {
+			case tt.want == "" && len(got) > 0:
+				t.Errorf("logged %v, want nothing", got)
+			case tt.want != "" && (len(got) != 1 || got[0]["msg"] != tt.want || got[0]["sql"] != "SELECT 1"):
+				t.Errorf("logged %v, want %q with the query", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGormLoggerLeavesOutValues(t *testing.T) {
+	var buf bytes.Buffer
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "logging.db")), &gorm.Config{
+		Logger: NewGormLogger(New(&buf, FormatJSON, slog.LevelDebug), 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE TABLE users (name TEXT, hashed_password TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := db.Exec("INSERT INTO users (name, hashed_password) VALUES (?, ?)", "dana", "$2a$10$secret").Error; err != nil {
+		t.Fatal(err)
+	}
+	// the failing query is logged as an error, with its values left out as well
+	db.Exec("INSERT INTO missing (hashed_password) VALUES (?)", "$2a$10$secret")
+
+	got := records(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("logged %v, want the two queries", got)
+	}
+	for _, record := range got {
+		if sql, _ := record["sql"].(string); strings.Contains(sql, "secret") || !strings.Contains(sql, "?") {
+			t.Errorf("logged query %q, want the placeholders without the values", sql)
+		}
+	}
+}
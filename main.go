@@ -1,18 +1,32 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	// permitted hours of geofences need the time zone database, also on hosts without one
+	_ "time/tzdata"
 
+	"github.com/gin-gonic/gin"
 	"github.com/rassulmagauin/VMS_SWE/api"
 	"github.com/rassulmagauin/VMS_SWE/config"
 	_ "github.com/rassulmagauin/VMS_SWE/docs"
+	"github.com/rassulmagauin/VMS_SWE/logging"
+	"github.com/rassulmagauin/VMS_SWE/tracing"
 )
 
-func ensureUploadsDir() {
-	path := "./uploads"
+// fatal logs err and exits, like log.Fatal for the structured logger
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func ensureUploadsDir(path string) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		os.Mkdir(path, os.ModePerm) // or os.MkdirAll for nested directories
+		os.MkdirAll(path, os.ModePerm)
 	}
 }
 
@@ -28,21 +42,71 @@ func ensureUploadsDir() {
 // @in header
 // @name Authorization
 func main() {
-	config.Connect()
-	server, err := api.NewServer(config.DB)
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file, config.yaml is read if it exists")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatal("Cannot create server: ", err)
+		fatal("cannot load configuration", err)
 	}
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // default port if not specified
+	logger := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.SlogLevel())
+	slog.SetDefault(logger)
+	if cfg.Environment == config.EnvProduction {
+		// gin's debug output is plain text and would break up the JSON logs
+		gin.SetMode(gin.ReleaseMode)
 	}
-
-	ensureUploadsDir()
-	// Run the server
-	err = server.Run(":" + port)
+	db, err := config.Connect(cfg.Database, logging.NewGormLogger(logger, cfg.Log.SlowQueryThreshold))
+	if err != nil {
+		fatal("cannot connect to database", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("cannot set up tracing", err)
+	}
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		fatal("cannot trace database queries", err)
+	}
+	server, err := api.NewServer(cfg, db)
 	if err != nil {
-		log.Fatal("Cannot start server: ", err)
+		fatal("cannot create server", err)
 	}
 
+	ensureUploadsDir(cfg.Storage.Dir)
+	if err := server.Start(); err != nil {
+		fatal("cannot start server", err)
+	}
+	httpServer := server.HTTPServer(":" + cfg.HTTP.Port)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	// drain in-flight requests and background jobs on SIGTERM/SIGINT before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	select {
+	case err := <-serveErr:
+		fatal("cannot start server", err)
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("cannot drain requests", "error", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("cannot stop background jobs", "error", err)
+	}
+	if err := config.Close(db); err != nil {
+		slog.Error("cannot close database", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("cannot flush traces", "error", err)
+	}
 }
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/rassulmagauin/VMS_SWE/geo"
 	"gorm.io/gorm"
 )
 
@@ -12,30 +13,37 @@ type VehicleStatus string
 type AppointmentStatus string
 type MaintenanceStatus string
 type RolesList string
+type IncidentStatus string
 
-// Constants for the enum values
+// Constants for the enum values. The binding tags of the task requests list the task statuses.
 const (
-	TaskStatusCompleted        TaskStatus        = "completed"
-	TaskStatusCanceled         TaskStatus        = "canceled"
-	TaskStatusDelayed          TaskStatus        = "delayed"
-	VehicleStatusActive        VehicleStatus     = "Active"
-	VehicleStatusInactive      VehicleStatus     = "Inactive"
-	VehicleStatusMaintenance   VehicleStatus     = "Maintenance"
-	AppointmentStatusPending   AppointmentStatus = "Pending"
-	AppointmentStatusConfirmed AppointmentStatus = "Confirmed"
-	AppointmentStatusCancelled AppointmentStatus = "Cancelled"
-	MaintenanceStatusPending   MaintenanceStatus = "Pending"
-	MaintenanceStatusDone      MaintenanceStatus = "Done"
-	RolesListAdmin             RolesList         = "Admin"
-	RolesListDriver            RolesList         = "Driver"
-	RolesListFuelingPerson     RolesList         = "Fueling_person"
-	RolesListMaintenancePerson RolesList         = "Maintenance_person"
+	TaskStatusAssigned            TaskStatus        = "assigned"
+	TaskStatusInProgress          TaskStatus        = "in_progress"
+	TaskStatusCompleted           TaskStatus        = "completed"
+	TaskStatusCanceled            TaskStatus        = "canceled"
+	TaskStatusDelayed             TaskStatus        = "delayed"
+	VehicleStatusActive           VehicleStatus     = "Active"
+	VehicleStatusInactive         VehicleStatus     = "Inactive"
+	VehicleStatusMaintenance      VehicleStatus     = "Maintenance"
+	AppointmentStatusPending      AppointmentStatus = "Pending"
+	AppointmentStatusConfirmed    AppointmentStatus = "Confirmed"
+	AppointmentStatusCancelled    AppointmentStatus = "Cancelled"
+	MaintenanceStatusPending      MaintenanceStatus = "Pending"
+	MaintenanceStatusDone         MaintenanceStatus = "Done"
+	RolesListAdmin                RolesList         = "Admin"
+	RolesListDriver               RolesList         = "Driver"
+	RolesListFuelingPerson        RolesList         = "Fueling_person"
+	RolesListMaintenancePerson    RolesList         = "Maintenance_person"
+	IncidentStatusReported        IncidentStatus    = "Reported"
+	IncidentStatusUnderReview     IncidentStatus    = "UnderReview"
+	IncidentStatusRepairScheduled IncidentStatus    = "RepairScheduled"
+	IncidentStatusClosed          IncidentStatus    = "Closed"
 )
 
 type User struct {
 	ID                   uint       `gorm:"not null" json:"ID"`
 	Username             string     `gorm:"not null;unique" json:"username"`
-	HashedPassword       *string    `gorm:"not null" json:"hashed_password"`
+	HashedPassword       *string    `gorm:"not null" json:"-"`
 	GovermentID          *string    `json:"goverment_id"`
 	MiddleName           *string    `json:"middle_name"`
 	Address              *string    `json:"address"`
@@ -46,9 +54,11 @@ type User struct {
 	LastName             *string    `gorm:"not null" json:"last_name"`
 	Email                *string    `json:"email"`
 	LastLogin            *time.Time `json:"last_login"`
-	Status               *string    `json:"status"`
-	Tasks                []Task     `gorm:"foreignKey:DriverID"`
-	Vehicles             []Vehicle  `gorm:"foreignKey:AssignedDriver"`
+	// PasswordChangedAt revokes the access tokens issued before it
+	PasswordChangedAt *time.Time `json:"-"`
+	Status            *string    `json:"status"`
+	Tasks             []Task     `gorm:"foreignKey:DriverID"`
+	Vehicles          []Vehicle  `gorm:"foreignKey:AssignedDriver"`
 	gorm.Model
 }
 
@@ -77,19 +87,34 @@ type Vehicle struct {
 }
 
 type Task struct {
-	ID             uint       `gorm:"not null" json:"ID"`
-	DriverID       *uint      `gorm:"not null;onDelete:CASCADE" json:"driver_id"`
-	StartLatitude  *float64   `gorm:"not null" json:"start_latitude"`
-	StartLongitude *float64   `gorm:"not null" json:"start_longitude"`
-	EndLatitude    *float64   `gorm:"not null" json:"end_latitude"`
-	EndLongitude   *float64   `gorm:"not null" json:"end_longitude"`
-	StartTime      *time.Time `json:"start_time"`
-	EndTime        *time.Time `json:"end_time"`
-	Status         *string    `gorm:"not null" json:"status"`
-	Notes          *string    `json:"notes"`
-	Driver         *User      `gorm:"foreignKey:DriverID;references:ID"`
+	ID                uint           `gorm:"not null" json:"ID"`
+	DriverID          *uint          `gorm:"not null;onDelete:CASCADE" json:"driver_id"`
+	VehicleID         *uint          `json:"vehicle_id"`
+	StartLatitude     *float64       `gorm:"not null" json:"start_latitude"`
+	StartLongitude    *float64       `gorm:"not null" json:"start_longitude"`
+	EndLatitude       *float64       `gorm:"not null" json:"end_latitude"`
+	EndLongitude      *float64       `gorm:"not null" json:"end_longitude"`
+	StartTime         *time.Time     `json:"start_time"`
+	EndTime           *time.Time     `json:"end_time"`
+	Status            *string        `gorm:"not null" json:"status"`
+	Notes             *string        `json:"notes"`
+	EstimatedDistance *float64       `json:"estimated_distance"` // great-circle km
+	RoadDistance      *float64       `json:"road_distance"`      // km from the routing engine
+	EstimatedMinutes  *int           `json:"estimated_minutes"`
+	Waypoints         []TaskWaypoint `gorm:"foreignKey:TaskID" json:"waypoints"`
+	Driver            *User          `gorm:"foreignKey:DriverID;references:ID"`
+	Vehicle           *Vehicle       `gorm:"foreignKey:VehicleID;references:ID"`
 	gorm.Model
 }
+
+type TaskWaypoint struct {
+	gorm.Model
+	TaskID    *uint      `gorm:"not null;onDelete:CASCADE" json:"task_id"`
+	Sequence  int        `gorm:"not null" json:"sequence"`
+	Latitude  *float64   `gorm:"not null" json:"latitude"`
+	Longitude *float64   `gorm:"not null" json:"longitude"`
+	ArrivedAt *time.Time `json:"arrived_at"`
+}
 type Appointment struct {
 	gorm.Model
 	AppointmentDate *time.Time         `json:"appointment_date"`
@@ -99,16 +124,19 @@ type Appointment struct {
 
 type AuctionVehicle struct {
 	gorm.Model
-	VehicleID *uint    `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
-	Images    []Image  `gorm:"foreignKey:ID" json:"images"`
-	Details   *string  `json:"details"`
-	Vehicle   *Vehicle `gorm:"foreignKey:VehicleID;references:ID"`
+	VehicleID *uint      `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
+	Images    []Image    `gorm:"foreignKey:ID" json:"images"`
+	Details   *string    `json:"details"`
+	EndsAt    *time.Time `json:"ends_at"` // the close_auctions job closes the auction once this passes
+	WinnerID  *uint      `json:"winner_id"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	Vehicle   *Vehicle   `gorm:"foreignKey:VehicleID;references:ID"`
 }
 
 type MaintenanceRecord struct {
 	gorm.Model
 	VehicleID           *uint              `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
-	MaintenancePersonID *uint              `gorm:"not null" json:"maintenance_person_id"`
+	MaintenancePersonID *uint              `json:"maintenance_person_id"` // unset while a pending record waits for a mechanic
 	MaintenanceDate     *time.Time         `json:"maintenance_date"`
 	ServiceType         *string            `json:"service_type"`
 	Status              *MaintenanceStatus `gorm:"not null" json:"status"`
@@ -134,12 +162,286 @@ type FuelingRecord struct {
 type VehicleUsage struct {
 	gorm.Model
 	VehicleID *uint      `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
+	TaskID    *uint      `json:"task_id"`
 	StartTime *time.Time `gorm:"not null" json:"start_time"`
 	EndTime   *time.Time `gorm:"not null" json:"end_time"`
 	Distance  *float64   `gorm:"not null" json:"distance"`
 	Vehicle   *Vehicle   `gorm:"foreignKey:VehicleID;references:ID"`
 }
 
+// LocationPing is append only and has no soft delete so expired rows can be purged in bulk
+type LocationPing struct {
+	ID         uint      `gorm:"primaryKey" json:"ID"`
+	VehicleID  *uint     `gorm:"not null;index:idx_location_pings_vehicle_time,priority:1" json:"vehicle_id"`
+	TaskID     *uint     `gorm:"index" json:"task_id"`
+	DriverID   *uint     `gorm:"not null" json:"driver_id"`
+	Latitude   *float64  `gorm:"not null" json:"latitude"`
+	Longitude  *float64  `gorm:"not null" json:"longitude"`
+	Speed      *float64  `json:"speed"`
+	Heading    *float64  `json:"heading"`
+	RecordedAt time.Time `gorm:"not null;index:idx_location_pings_vehicle_time,priority:2;index" json:"recorded_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Geofence struct {
+	gorm.Model
+	Name            *string     `gorm:"not null" json:"name"`
+	Category        *string     `gorm:"not null" json:"category"` // depot, customer_site, restricted or operating_area
+	Shape           *string     `gorm:"not null" json:"shape"`    // circle or polygon
+	CenterLatitude  *float64    `json:"center_latitude"`
+	CenterLongitude *float64    `json:"center_longitude"`
+	RadiusMeters    *float64    `json:"radius_meters"`
+	Polygon         []geo.Point `gorm:"serializer:json" json:"polygon"`
+	PermittedFrom   *string     `json:"permitted_from"` // "HH:MM", vehicles inside outside of these hours are flagged
+	PermittedTo     *string     `json:"permitted_to"`
+	TimeZone        *string     `json:"time_zone"` // IANA name the permitted hours are in, UTC when unset
+	Active          *bool       `gorm:"not null;default:true" json:"active"`
+}
+
+type GeofenceEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"ID"`
+	GeofenceID *uint     `gorm:"index" json:"geofence_id"`
+	VehicleID  *uint     `gorm:"not null;index" json:"vehicle_id"`
+	DriverID   *uint     `json:"driver_id"`
+	TaskID     *uint     `json:"task_id"`
+	Type       string    `gorm:"not null" json:"type"` // enter, exit or violation
+	Reason     *string   `json:"reason"`
+	Latitude   *float64  `json:"latitude"`
+	Longitude  *float64  `json:"longitude"`
+	OccurredAt time.Time `gorm:"not null;index" json:"occurred_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type InspectionTemplate struct {
+	gorm.Model
+	Name        *string                  `gorm:"not null" json:"name"`
+	VehicleType *string                  `gorm:"not null" json:"vehicle_type"`
+	Kind        *string                  `gorm:"not null" json:"kind"` // pre_trip or post_trip
+	Items       []InspectionTemplateItem `gorm:"foreignKey:TemplateID" json:"items"`
+}
+
+type InspectionTemplateItem struct {
+	gorm.Model
+	TemplateID *uint   `gorm:"not null;onDelete:CASCADE" json:"template_id"`
+	Position   int     `gorm:"not null" json:"position"`
+	Label      *string `gorm:"not null" json:"label"`
+	Critical   bool    `json:"critical"`
+}
+
+type Inspection struct {
+	gorm.Model
+	VehicleID           *uint              `gorm:"not null;onDelete:CASCADE" json:"vehicle_id"`
+	DriverID            *uint              `gorm:"not null" json:"driver_id"`
+	TemplateID          *uint              `gorm:"not null" json:"template_id"`
+	Kind                *string            `gorm:"not null" json:"kind"`
+	Passed              bool               `json:"passed"`
+	Notes               *string            `json:"notes"`
+	MaintenanceRecordID *uint              `json:"maintenance_record_id"`
+	Results             []InspectionResult `gorm:"foreignKey:InspectionID" json:"results"`
+	Photos              []InspectionPhoto  `gorm:"foreignKey:InspectionID" json:"photos"`
+}
+
+type InspectionResult struct {
+	gorm.Model
+	InspectionID *uint   `gorm:"not null;onDelete:CASCADE" json:"inspection_id"`
+	ItemID       *uint   `gorm:"not null" json:"item_id"`
+	Label        *string `json:"label"`
+	Critical     bool    `json:"critical"`
+	Passed       bool    `json:"passed"`
+	Notes        *string `json:"notes"`
+}
+
+type InspectionPhoto struct {
+	gorm.Model
+	InspectionID *uint   `gorm:"not null;onDelete:CASCADE" json:"inspection_id"`
+	Url          *string `gorm:"not null" json:"url"`
+}
+
+// VehicleAssignment keeps the history of which driver had a vehicle and when
+type VehicleAssignment struct {
+	gorm.Model
+	VehicleID    *uint      `gorm:"not null;index" json:"vehicle_id"`
+	DriverID     *uint      `gorm:"not null;index" json:"driver_id"`
+	AssignedAt   time.Time  `gorm:"not null" json:"assigned_at"`
+	UnassignedAt *time.Time `json:"unassigned_at"`
+}
+
+type IncidentThirdParty struct {
+	Name             *string `json:"name"`
+	PhoneNumber      *string `json:"phone_number"`
+	LicensePlate     *string `json:"license_plate"`
+	InsuranceCompany *string `json:"insurance_company"`
+	InsurancePolicy  *string `json:"insurance_policy"`
+}
+
+type Incident struct {
+	gorm.Model
+	VehicleID           *uint                `gorm:"not null;index" json:"vehicle_id"`
+	DriverID            *uint                `gorm:"index" json:"driver_id"` // driver assigned to the vehicle when it happened
+	ReportedByID        *uint                `gorm:"not null" json:"reported_by_id"`
+	OccurredAt          *time.Time           `gorm:"not null" json:"occurred_at"`
+	Latitude            *float64             `json:"latitude"`
+	Longitude           *float64             `json:"longitude"`
+	Location            *string              `json:"location"`
+	Description         *string              `gorm:"not null" json:"description"`
+	ThirdParties        []IncidentThirdParty `gorm:"serializer:json" json:"third_parties"`
+	Status              *IncidentStatus      `gorm:"not null" json:"status"`
+	MaintenanceRecordID *uint                `json:"maintenance_record_id"`
+	RepairCost          *float64             `json:"repair_cost"`
+	ResolutionNotes     *string              `json:"resolution_notes"`
+	Photos              []IncidentPhoto      `gorm:"foreignKey:IncidentID" json:"photos"`
+}
+
+type IncidentPhoto struct {
+	gorm.Model
+	IncidentID *uint   `gorm:"not null;onDelete:CASCADE" json:"incident_id"`
+	Url        *string `gorm:"not null" json:"url"`
+}
+
+type VehicleDocument struct {
+	gorm.Model
+	VehicleID *uint      `gorm:"not null;index;onDelete:CASCADE" json:"vehicle_id"`
+	Type      *string    `gorm:"not null" json:"type"` // insurance, registration or technical_inspection
+	Number    *string    `gorm:"not null" json:"number"`
+	Issuer    *string    `json:"issuer"`
+	ValidFrom *time.Time `json:"valid_from"`
+	ValidTo   *time.Time `gorm:"not null;index" json:"valid_to"`
+	File      *string    `json:"file"`
+}
+
+// Alert is raised by background checks for admins to act on
+type Alert struct {
+	gorm.Model
+	Kind       string     `gorm:"not null;index" json:"kind"`
+	Severity   string     `gorm:"not null" json:"severity"` // warning or critical
+	Message    string     `gorm:"not null" json:"message"`
+	VehicleID  *uint      `gorm:"index" json:"vehicle_id"`
+	DocumentID *uint      `gorm:"index" json:"document_id"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+type DriverLicense struct {
+	gorm.Model
+	UserID     *uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	Number     *string    `gorm:"not null" json:"number"`
+	Categories *string    `gorm:"not null" json:"categories"` // comma separated, e.g. "B,C"
+	IssuedAt   *time.Time `json:"issued_at"`
+	ExpiresAt  *time.Time `gorm:"not null" json:"expires_at"`
+	File       *string    `json:"file"`
+}
+
+type DriverQualification struct {
+	gorm.Model
+	UserID    *uint      `gorm:"not null;index" json:"user_id"`
+	Kind      *string    `gorm:"not null" json:"kind"` // hazmat or passenger_transport
+	Number    *string    `json:"number"`
+	IssuedAt  *time.Time `json:"issued_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	File      *string    `json:"file"`
+}
+
+type Notification struct {
+	gorm.Model
+	UserID    *uint      `gorm:"not null;index" json:"user_id"`
+	Kind      string     `gorm:"not null" json:"kind"`
+	Subject   string     `gorm:"not null" json:"subject"`
+	Body      string     `gorm:"not null" json:"body"`
+	Reference *string    `gorm:"index" json:"reference"` // deduplicates recurring reminders
+	InApp     bool       `json:"in_app"`                 // shown in the inbox
+	Email     bool       `json:"email"`                  // sent by email, EmailedAt is set once it went out
+	EmailedAt *time.Time `json:"emailed_at"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+type NotificationPreference struct {
+	gorm.Model
+	UserID *uint  `gorm:"not null;uniqueIndex:idx_notification_preference" json:"user_id"`
+	Kind   string `gorm:"not null;uniqueIndex:idx_notification_preference" json:"kind"`
+	InApp  *bool  `gorm:"default:true" json:"in_app"`
+	Email  *bool  `gorm:"default:true" json:"email"`
+}
+
+type WebhookEndpoint struct {
+	gorm.Model
+	URL         string   `gorm:"not null" json:"url"`
+	Secret      string   `gorm:"not null" json:"-"`
+	EventTypes  []string `gorm:"serializer:json" json:"event_types"` // patterns such as "task.*"
+	Description *string  `json:"description"`
+	Active      *bool    `gorm:"default:true" json:"active"`
+}
+
+type WebhookDelivery struct {
+	gorm.Model
+	EndpointID    *uint            `gorm:"not null;index" json:"endpoint_id"`
+	EventID       *uint            `gorm:"index" json:"event_id"` // outbox event the delivery was queued for
+	EventType     string           `gorm:"not null" json:"event_type"`
+	Payload       string           `gorm:"type:text;not null" json:"payload"`
+	Status        string           `gorm:"not null;index" json:"status"` // pending, succeeded or failed
+	Attempts      int              `json:"attempts"`
+	ResponseCode  *int             `json:"response_code"`
+	LastError     *string          `json:"last_error"`
+	NextAttemptAt *time.Time       `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt   *time.Time       `json:"delivered_at"`
+	Endpoint      *WebhookEndpoint `gorm:"foreignKey:EndpointID;references:ID" json:"-"`
+}
+
+// PasswordResetToken is a single-use token for setting a new password. Only the SHA-256 hash
+// of the token is stored, the token itself is only in the email.
+type PasswordResetToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	User      *User      `gorm:"foreignKey:UserID;references:ID" json:"-"`
+}
+
+// RateLimitCounter is a counter of the shared rate limit backend. ExpiresAt is in unix
+// milliseconds so it compares the same in every database.
+type RateLimitCounter struct {
+	Key       string `gorm:"primaryKey" json:"key"`
+	Count     int64  `gorm:"not null" json:"count"`
+	ExpiresAt int64  `gorm:"not null;index" json:"expires_at"`
+}
+
+type OutboxEvent struct {
+	ID            uint       `gorm:"primaryKey" json:"ID"`
+	Type          string     `gorm:"not null" json:"type"`
+	VehicleID     *uint      `json:"vehicle_id"`
+	DriverID      *uint      `json:"driver_id"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	OccurredAt    time.Time  `gorm:"not null" json:"occurred_at"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error"`
+	Delivered     string     `gorm:"type:text;not null;default:''" json:"delivered"` // subscribers that handled the event, comma separated
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	LockedUntil   *time.Time `json:"-"` // claimed by a dispatcher until then
+	DispatchedAt  *time.Time `gorm:"index" json:"dispatched_at"`
+	CreatedAt     time.Time  `json:"CreatedAt"`
+}
+
+type Job struct {
+	gorm.Model
+	Name        string     `gorm:"not null;uniqueIndex" json:"name"`
+	Schedule    string     `gorm:"not null" json:"schedule"`
+	LockedBy    *string    `json:"locked_by"` // replica currently running the job
+	LockedUntil *time.Time `json:"locked_until"`
+	NextRunAt   *time.Time `json:"next_run_at"`
+	LastRunAt   *time.Time `json:"last_run_at"`
+	LastStatus  *string    `json:"last_status"`
+}
+
+type JobRun struct {
+	gorm.Model
+	JobName    string     `gorm:"not null;index" json:"job_name"`
+	Trigger    string     `gorm:"not null" json:"trigger"` // schedule or manual
+	RunBy      string     `json:"run_by"`
+	Status     string     `gorm:"not null" json:"status"`
+	StartedAt  time.Time  `gorm:"index" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Error      *string    `json:"error"`
+}
+
 type RolePermission struct {
 	Role                     RolesList `gorm:"primaryKey" json:"role"`
 	CanAccessCarInfo         bool      `json:"can_access_car_info"`
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// smtpStandIn accepts a single SMTP session and returns the message data it received
+func smtpStandIn(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotLines()
+				if err != nil {
+					return
+				}
+				received <- strings.Join(data, "\n")
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	addr, received := smtpStandIn(t)
+	mailer := NewSMTPMailer(addr, "fleet@example.com", "", "")
+	if err := mailer.Send("driver@example.com", "New task #7\r\nBcc: someone@example.com", "Hello Aida, you have been assigned task #7."); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	message := <-received
+	headers, err := textproto.NewReader(bufio.NewReader(strings.NewReader(message + "\n"))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if got := headers.Get("To"); got != "driver@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	if got := headers.Get("Bcc"); got != "" {
+		t.Errorf("subject injected a Bcc header: %q", got)
+	}
+	if !strings.HasPrefix(headers.Get("Subject"), "New task #7") {
+		t.Errorf("Subject = %q", headers.Get("Subject"))
+	}
+	if !strings.Contains(message, "you have been assigned task #7") {
+		t.Errorf("body missing from message:\n%s", message)
+	}
+}
+
+func TestRender(t *testing.T) {
+	subject, body, err := Render(TaskAssigned, Data{"name": "Aida", "task_id": 7, "start_time": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "New task #7" || body != "Hello Aida, you have been assigned task #7." {
+		t.Errorf("got %q / %q", subject, body)
+	}
+	if _, _, err := Render(VehicleAssigned, Data{"name": "Aida"}); err == nil {
+		t.Error("expected an error for missing template data")
+	}
+}
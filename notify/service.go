@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+)
+
+type Message struct {
+	UserID uint
+	Kind   Kind
+	// Reference deduplicates reminders, a message is delivered once per user, kind and reference
+	Reference string
+	Data      Data
+}
+
+type Preference struct {
+	Kind  Kind `json:"kind"`
+	InApp bool `json:"in_app"`
+	Email bool `json:"email"`
+}
+
+// Service renders notifications, stores them for the in-app inbox and emails them according to user preferences
+type Service struct {
+	db     *gorm.DB
+	mailer Mailer
+}
+
+// NewService creates a notification service, without a mailer only the in-app channel is used
+func NewService(db *gorm.DB, mailer Mailer) *Service {
+	return &Service{db: db, mailer: mailer}
+}
+
+// Preferences returns the channel preferences of the user for every kind, both channels are on by default
+func (s *Service) Preferences(userID uint) ([]Preference, error) {
+	var stored []models.NotificationPreference
+	if err := s.db.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	byKind := make(map[Kind]models.NotificationPreference, len(stored))
+	for _, preference := range stored {
+		byKind[Kind(preference.Kind)] = preference
+	}
+	preferences := make([]Preference, len(Kinds))
+	for i, kind := range Kinds {
+		preferences[i] = Preference{Kind: kind, InApp: true, Email: true}
+		if preference, ok := byKind[kind]; ok {
+			preferences[i].InApp = preference.InApp == nil || *preference.InApp
+			preferences[i].Email = preference.Email == nil || *preference.Email
+		}
+	}
+	return preferences, nil
+}
+
+// SetPreference stores the channel preference of the user for one kind
+func (s *Service) SetPreference(userID uint, preference Preference) error {
+	if !ValidKind(preference.Kind) {
+		return fmt.Errorf("unknown notification kind %q", preference.Kind)
+	}
+	var stored models.NotificationPreference
+	err := s.db.Where("user_id = ? AND kind = ?", userID, string(preference.Kind)).First(&stored).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	stored.UserID = &userID
+	stored.Kind = string(preference.Kind)
+	stored.InApp = &preference.InApp
+	stored.Email = &preference.Email
+	return s.db.Save(&stored).Error
+}
+
+func (s *Service) preference(userID uint, kind Kind) (Preference, error) {
+	preferences, err := s.Preferences(userID)
+	if err != nil {
+		return Preference{}, err
+	}
+	for _, preference := range preferences {
+		if preference.Kind == kind {
+			return preference, nil
+		}
+	}
+	return Preference{}, fmt.Errorf("unknown notification kind %q", kind)
+}
+
+// Notify delivers the message over the channels the recipient has enabled. A message with a
+// reference is recorded once, calling Notify again only sends the email if it did not go out yet.
+func (s *Service) Notify(message Message) error {
+	var user models.User
+	if err := s.db.First(&user, message.UserID).Error; err != nil {
+		return err
+	}
+	var reference *string
+	if message.Reference != "" {
+		reference = &message.Reference
+		var existing models.Notification
+		if err := s.db.Where("user_id = ? AND kind = ? AND reference = ?", user.ID, string(message.Kind), message.Reference).
+			Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID != 0 {
+			return s.email(user, &existing)
+		}
+	}
+	preference, err := s.preference(user.ID, message.Kind)
+	if err != nil {
+		return err
+	}
+	sendEmail := preference.Email && s.mailer != nil && user.Email != nil && *user.Email != ""
+	if !preference.InApp && !sendEmail {
+		return nil
+	}
+
+	data := Data{"name": user.Username}
+	if user.FirstName != nil {
+		data["name"] = *user.FirstName
+	}
+	for key, value := range message.Data {
+		data[key] = value
+	}
+	subject, body, err := Render(message.Kind, data)
+	if err != nil {
+		return err
+	}
+	notification := models.Notification{
+		UserID:    &user.ID,
+		Kind:      string(message.Kind),
+		Subject:   subject,
+		Body:      body,
+		Reference: reference,
+		InApp:     preference.InApp,
+		Email:     sendEmail,
+	}
+	if err := s.db.Create(&notification).Error; err != nil {
+		return err
+	}
+	return s.email(user, &notification)
+}
+
+// email sends a notification that is meant to go out by email and has not been sent yet
+func (s *Service) email(user models.User, notification *models.Notification) error {
+	if !notification.Email || notification.EmailedAt != nil || s.mailer == nil || user.Email == nil || *user.Email == "" {
+		return nil
+	}
+	if err := s.mailer.Send(*user.Email, notification.Subject, notification.Body); err != nil {
+		return fmt.Errorf("cannot email notification %d: %w", notification.ID, err)
+	}
+	return s.db.Model(notification).Update("emailed_at", time.Now()).Error
+}
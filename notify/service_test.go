@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// flakyMailer fails the first failures sends and records the subjects of the others
+type flakyMailer struct {
+	failures int
+	sent     []string
+}
+
+func (m *flakyMailer) Send(to, subject, body string) error {
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("smtp unavailable")
+	}
+	m.sent = append(m.sent, subject)
+	return nil
+}
+
+func TestNotifyRetriesOnlyTheEmail(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "notify.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Notification{}, &models.NotificationPreference{}); err != nil {
+		t.Fatal(err)
+	}
+	email, hash, role, name := "driver@example.com", "hash", "Driver", "Dana"
+	user := models.User{Username: "driver", HashedPassword: &hash, Role: &role, FirstName: &name, LastName: &name, Email: &email}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	mailer := &flakyMailer{failures: 1}
+	service := NewService(db, mailer)
+	message := Message{UserID: user.ID, Kind: VehicleAssigned, Reference: "vehicle:1", Data: Data{"vehicle": "ABC 123"}}
+
+	if err := service.Notify(message); err == nil {
+		t.Fatal("notify with a failing mailer succeeded, want the error so it is retried")
+	}
+	for i := 0; i < 2; i++ {
+		if err := service.Notify(message); err != nil {
+			t.Fatalf("notify %d: %v", i+2, err)
+		}
+	}
+
+	if len(mailer.sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(mailer.sent))
+	}
+	var notifications []models.Notification
+	if err := db.Find(&notifications).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].EmailedAt == nil {
+		t.Errorf("notifications = %+v, want one that was emailed", notifications)
+	}
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Kind identifies what a notification is about; users set channel preferences per kind
+type Kind string
+
+const (
+	TaskAssigned     Kind = "task_assigned"
+	VehicleAssigned  Kind = "vehicle_assigned"
+	MaintenanceDue   Kind = "maintenance_due"
+	DocumentExpiring Kind = "document_expiring"
+	AuctionWon       Kind = "auction_won"
+)
+
+// Kinds lists every notification kind in a stable order
+var Kinds = []Kind{TaskAssigned, VehicleAssigned, MaintenanceDue, DocumentExpiring, AuctionWon}
+
+// Data holds the values a template refers to, the recipient's first name is available as "name"
+type Data map[string]interface{}
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newTemplate(kind Kind, subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New(string(kind) + ".subject").Option("missingkey=error").Parse(subject)),
+		body:    template.Must(template.New(string(kind) + ".body").Option("missingkey=error").Parse(body)),
+	}
+}
+
+var templates = map[Kind]messageTemplate{
+	TaskAssigned: newTemplate(TaskAssigned,
+		"New task #{{.task_id}}",
+		"Hello {{.name}}, you have been assigned task #{{.task_id}}{{with .start_time}} starting {{.}}{{end}}."),
+	VehicleAssigned: newTemplate(VehicleAssigned,
+		"Vehicle {{.vehicle}} assigned to you",
+		"Hello {{.name}}, vehicle {{.vehicle}} has been assigned to you."),
+	MaintenanceDue: newTemplate(MaintenanceDue,
+		"Maintenance due for vehicle {{.vehicle}}",
+		"Hello {{.name}}, maintenance of vehicle {{.vehicle}} is due on {{.date}}."),
+	DocumentExpiring: newTemplate(DocumentExpiring,
+		"Document of vehicle {{.vehicle}} needs renewal",
+		"Hello {{.name}}, {{.message}}."),
+	AuctionWon: newTemplate(AuctionWon,
+		"You won auction #{{.auction_id}}",
+		"Hello {{.name}}, you won the auction for vehicle {{.vehicle}}."),
+}
+
+// ValidKind reports whether kind is a known notification kind
+func ValidKind(kind Kind) bool {
+	_, ok := templates[kind]
+	return ok
+}
+
+// Render fills in the subject and body templates of the kind
+func Render(kind Kind, data Data) (subject, body string, err error) {
+	t, ok := templates[kind]
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification kind %q", kind)
+	}
+	var sb strings.Builder
+	if err := t.subject.Execute(&sb, data); err != nil {
+		return "", "", err
+	}
+	subject = sb.String()
+	sb.Reset()
+	if err := t.body.Execute(&sb, data); err != nil {
+		return "", "", err
+	}
+	return subject, sb.String(), nil
+}
@@ -0,0 +1,232 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxAttempts is how often an event is offered to failing subscribers before it is given up,
+	// with the backoff below that is after about five hours
+	MaxAttempts = 15
+
+	// retryDelay is the wait before the first retry, it doubles with every attempt up to maxRetryDelay
+	retryDelay    = 5 * time.Second
+	maxRetryDelay = time.Hour
+
+	// claimLease is how long a dispatcher holds the events of a batch before other instances may take them
+	claimLease = 10 * time.Minute
+
+	dispatchBatch = 100
+)
+
+// Write stores the event in the outbox as part of the caller's transaction,
+// so it is dispatched if and only if the transaction commits
+func Write(tx *gorm.DB, evt events.Event) error {
+	if evt.OccurredAt.IsZero() {
+		evt.OccurredAt = time.Now()
+	}
+	payload, err := json.Marshal(evt.Data)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s event: %w", evt.Type, err)
+	}
+	return tx.Create(&models.OutboxEvent{
+		Type:       evt.Type,
+		VehicleID:  evt.VehicleID,
+		DriverID:   evt.DriverID,
+		Payload:    string(payload),
+		OccurredAt: evt.OccurredAt,
+	}).Error
+}
+
+// Subscriber receives dispatched events. Every subscriber that handled an event is recorded and
+// only the failed ones see it again. Delivery is still at-least-once, a handler that succeeded
+// just before the server stopped may get the event a second time.
+type Subscriber struct {
+	Name   string
+	Handle func(events.Event) error
+}
+
+// Dispatcher delivers outbox events to subscribers in the order they were written, failed events
+// are retried later with a growing delay. Several instances may share one outbox, each claims its own batches.
+type Dispatcher struct {
+	db          *gorm.DB
+	subscribers []Subscriber
+	wake        chan struct{}
+	quit        chan struct{}
+	running     sync.WaitGroup
+	now         func() time.Time
+}
+
+func NewDispatcher(db *gorm.DB, subscribers ...Subscriber) *Dispatcher {
+	return &Dispatcher{db: db, subscribers: subscribers, wake: make(chan struct{}, 1), quit: make(chan struct{}), now: time.Now}
+}
+
+// Wake asks a running dispatcher to look for new events without waiting for the next tick
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start dispatches events in the background whenever the dispatcher is woken and at least once per interval
+func (d *Dispatcher) Start(interval time.Duration) {
+	d.running.Add(1)
+	go func() {
+		defer d.running.Done()
+		d.run(interval)
+	}()
+}
+
+// Stop ends dispatching after the current batch and waits for it until ctx is done.
+// Events left in the outbox are dispatched by the next start.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	close(d.quit)
+	done := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := d.Dispatch()
+			if err != nil {
+				slog.Error("cannot dispatch outbox events", slog.Any("error", err))
+			}
+			if err != nil || n < dispatchBatch {
+				break
+			}
+			select {
+			case <-d.quit:
+				return
+			default:
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// Dispatch offers one batch of due events to the subscribers that have not handled them yet
+// and returns how many were processed
+func (d *Dispatcher) Dispatch() (int, error) {
+	pending, err := d.claim()
+	if err != nil {
+		return 0, err
+	}
+	for i := range pending {
+		if err := d.deliver(&pending[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// claim takes a batch of due events that no other dispatcher holds
+func (d *Dispatcher) claim() ([]models.OutboxEvent, error) {
+	now := d.now()
+	var pending []models.OutboxEvent
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("dispatched_at IS NULL").
+			Where("(next_attempt_at IS NULL OR next_attempt_at <= ?)", now).
+			Where("(locked_until IS NULL OR locked_until <= ?)", now).
+			Order("id").Limit(dispatchBatch)
+		if tx.Dialector.Name() == "postgres" {
+			// instances polling at the same time skip each other's rows instead of waiting for them
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&pending).Error; err != nil || len(pending) == 0 {
+			return err
+		}
+		ids := make([]uint, len(pending))
+		for i, record := range pending {
+			ids[i] = record.ID
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("locked_until", now.Add(claimLease)).Error
+	})
+	return pending, err
+}
+
+func (d *Dispatcher) deliver(record *models.OutboxEvent) error {
+	id := record.ID
+	evt := events.Event{
+		ID:         &id,
+		Type:       record.Type,
+		VehicleID:  record.VehicleID,
+		DriverID:   record.DriverID,
+		Data:       json.RawMessage(record.Payload),
+		OccurredAt: record.OccurredAt,
+	}
+	var delivered []string
+	if record.Delivered != "" {
+		delivered = strings.Split(record.Delivered, ",")
+	}
+	var failures []error
+	for _, subscriber := range d.subscribers {
+		if slices.Contains(delivered, subscriber.Name) {
+			continue
+		}
+		if err := subscriber.Handle(evt); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", subscriber.Name, err))
+			continue
+		}
+		delivered = append(delivered, subscriber.Name)
+	}
+	now := d.now()
+	updates := map[string]interface{}{"delivered": strings.Join(delivered, ","), "locked_until": nil}
+	if len(failures) == 0 {
+		updates["dispatched_at"] = now
+		updates["last_error"] = nil
+		updates["next_attempt_at"] = nil
+		return d.db.Model(record).Updates(updates).Error
+	}
+	message := errors.Join(failures...).Error()
+	attempts := record.Attempts + 1
+	updates["attempts"] = attempts
+	updates["last_error"] = message
+	if attempts >= MaxAttempts {
+		slog.Error("giving up on outbox event", slog.Uint64("id", uint64(record.ID)), slog.String("type", record.Type), slog.String("error", message))
+		updates["dispatched_at"] = now
+	} else {
+		updates["next_attempt_at"] = now.Add(Backoff(retryDelay, maxRetryDelay, attempts))
+	}
+	return d.db.Model(record).Updates(updates).Error
+}
+
+// Backoff is the wait before retrying after the given number of failed attempts. It starts at
+// first and doubles with every attempt up to limit. Webhook deliveries are retried the same way.
+func Backoff(first, limit time.Duration, attempts int) time.Duration {
+	delay := first
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
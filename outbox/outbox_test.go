@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// clock is a time that tests move forward by hand
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newOutbox(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.OutboxEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(db, events.Event{Type: "vehicle.updated"}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// counting is a subscriber that counts its calls and fails while fail returns true
+func counting(name string, calls *int, fail func() bool) Subscriber {
+	return Subscriber{Name: name, Handle: func(events.Event) error {
+		*calls++
+		if fail() {
+			return errors.New("unavailable")
+		}
+		return nil
+	}}
+}
+
+func dispatch(t *testing.T, d *Dispatcher, want int) {
+	t.Helper()
+	n, err := d.Dispatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Fatalf("dispatched %d events, want %d", n, want)
+	}
+}
+
+func stored(t *testing.T, db *gorm.DB) models.OutboxEvent {
+	t.Helper()
+	var record models.OutboxEvent
+	if err := db.First(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestRetryOnlyFailedSubscribers(t *testing.T) {
+	db := newOutbox(t)
+	clock := &clock{now: time.Unix(1700000000, 0)}
+	var steadyCalls, flakyCalls int
+	d := NewDispatcher(db,
+		counting("steady", &steadyCalls, func() bool { return false }),
+		counting("flaky", &flakyCalls, func() bool { return flakyCalls == 1 }),
+	)
+	d.now = clock.Now
+
+	dispatch(t, d, 1)
+	record := stored(t, db)
+	if record.DispatchedAt != nil || record.Attempts != 1 || record.Delivered != "steady" {
+		t.Fatalf("after a failure the event is %+v, want one attempt delivered to steady", record)
+	}
+	if record.NextAttemptAt == nil || !record.NextAttemptAt.Equal(clock.now.Add(retryDelay)) {
+		t.Fatalf("next attempt at %v, want after %v", record.NextAttemptAt, retryDelay)
+	}
+
+	// the event waits for its backoff
+	dispatch(t, d, 0)
+	clock.now = clock.now.Add(retryDelay)
+	dispatch(t, d, 1)
+
+	if steadyCalls != 1 || flakyCalls != 2 {
+		t.Errorf("steady called %d times and flaky %d times, want 1 and 2", steadyCalls, flakyCalls)
+	}
+	if record := stored(t, db); record.DispatchedAt == nil || record.LastError != nil {
+		t.Errorf("event after the retry = %+v, want dispatched without error", record)
+	}
+	dispatch(t, d, 0)
+}
+
+func TestGiveUp(t *testing.T) {
+	db := newOutbox(t)
+	clock := &clock{now: time.Unix(1700000000, 0)}
+	var calls int
+	d := NewDispatcher(db, counting("broken", &calls, func() bool { return true }))
+	d.now = clock.Now
+
+	var waited time.Duration
+	for i := 1; i <= MaxAttempts; i++ {
+		dispatch(t, d, 1)
+		if i < MaxAttempts {
+			delay := stored(t, db).NextAttemptAt.Sub(clock.now)
+			if want := Backoff(retryDelay, maxRetryDelay, i); delay != want {
+				t.Fatalf("delay after attempt %d = %v, want %v", i, delay, want)
+			}
+			clock.now = clock.now.Add(delay)
+			waited += delay
+		}
+	}
+	if waited < 4*time.Hour {
+		t.Errorf("gave up after %v of retries, want hours", waited)
+	}
+	record := stored(t, db)
+	if record.DispatchedAt == nil || record.Attempts != MaxAttempts || record.LastError == nil {
+		t.Errorf("event after the last attempt = %+v, want given up with the error", record)
+	}
+	clock.now = clock.now.Add(maxRetryDelay)
+	dispatch(t, d, 0)
+	if calls != MaxAttempts {
+		t.Errorf("subscriber called %d times, want %d", calls, MaxAttempts)
+	}
+}
+
+func TestClaimedEventsAreNotDispatchedTwice(t *testing.T) {
+	db := newOutbox(t)
+	clock := &clock{now: time.Unix(1700000000, 0)}
+	var calls int
+	first := NewDispatcher(db, counting("stream", &calls, func() bool { return false }))
+	second := NewDispatcher(db, counting("stream", &calls, func() bool { return false }))
+	first.now = clock.Now
+	second.now = clock.Now
+
+	claimed, err := first.claim()
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claimed %d events, %v, want 1", len(claimed), err)
+	}
+	dispatch(t, second, 0)
+
+	// a dispatcher that stopped with the batch claimed leaves it to the others once the lease ran out
+	clock.now = clock.now.Add(claimLease)
+	dispatch(t, second, 1)
+	if calls != 1 {
+		t.Errorf("subscriber called %d times, want 1", calls)
+	}
+}
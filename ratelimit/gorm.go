@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+)
+
+// incrSQL counts atomically, restarting expired counters. It runs on PostgreSQL and SQLite.
+const incrSQL = `INSERT INTO rate_limit_counters ("key", count, expires_at) VALUES (?, 1, ?)
+ON CONFLICT ("key") DO UPDATE SET
+	count = CASE WHEN rate_limit_counters.expires_at <= ? THEN 1 ELSE rate_limit_counters.count + 1 END,
+	expires_at = CASE WHEN rate_limit_counters.expires_at <= ? THEN excluded.expires_at ELSE rate_limit_counters.expires_at END
+RETURNING "key", count, expires_at`
+
+// Gorm keeps the counters in the rate_limit_counters table, so all instances of the server
+// using the database share the limits
+type Gorm struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewGorm(db *gorm.DB) *Gorm {
+	return &Gorm{db: db, now: time.Now}
+}
+
+func (g *Gorm) Incr(ctx context.Context, key string, lifetime time.Duration) (int64, time.Duration, error) {
+	now := g.now()
+	var row models.RateLimitCounter
+	err := g.db.WithContext(ctx).Raw(incrSQL, key, now.Add(lifetime).UnixMilli(), now.UnixMilli(), now.UnixMilli()).Scan(&row).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return row.Count, time.UnixMilli(row.ExpiresAt).Sub(now), nil
+}
+
+func (g *Gorm) Get(ctx context.Context, key string) (int64, time.Duration, error) {
+	now := g.now()
+	var rows []models.RateLimitCounter
+	err := g.db.WithContext(ctx).Where(`"key" = ? AND expires_at > ?`, key, now.UnixMilli()).Limit(1).Find(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return 0, 0, err
+	}
+	return rows[0].Count, time.UnixMilli(rows[0].ExpiresAt).Sub(now), nil
+}
+
+func (g *Gorm) Delete(ctx context.Context, key string) error {
+	return g.db.WithContext(ctx).Where(`"key" = ?`, key).Delete(&models.RateLimitCounter{}).Error
+}
+
+func (g *Gorm) Purge(ctx context.Context) (int64, error) {
+	result := g.db.WithContext(ctx).Where("expires_at <= ?", g.now().UnixMilli()).Delete(&models.RateLimitCounter{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Incr drops the expired counters, so they do not pile up
+const sweepInterval = time.Minute
+
+type counter struct {
+	count   int64
+	expires time.Time
+}
+
+// Memory keeps the counters in the process, each instance of the server limits on its own
+type Memory struct {
+	mu        sync.Mutex
+	counters  map[string]counter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{counters: make(map[string]counter), now: time.Now}
+}
+
+func (m *Memory) Incr(ctx context.Context, key string, lifetime time.Duration) (int64, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = counter{expires: now.Add(lifetime)}
+	}
+	c.count++
+	m.counters[key] = c
+	return c.count, c.expires.Sub(now), nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (int64, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expires) {
+		return 0, 0, nil
+	}
+	return c.count, c.expires.Sub(now), nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counters, key)
+	return nil
+}
+
+func (m *Memory) Purge(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sweep(m.now()), nil
+}
+
+func (m *Memory) sweep(now time.Time) int64 {
+	m.lastSweep = now
+	var purged int64
+	for key, c := range m.counters {
+		if !now.Before(c.expires) {
+			delete(m.counters, key)
+			purged++
+		}
+	}
+	return purged
+}
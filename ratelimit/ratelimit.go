@@ -0,0 +1,147 @@
+// Package ratelimit counts requests in fixed windows and locks accounts out after repeated
+// failed logins. Counters live in a Backend: Memory for a single instance, Gorm to share them
+// between instances through the database.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+)
+
+// Backend stores counters that expire. Another shared store, such as Redis, plugs in by
+// implementing it.
+type Backend interface {
+	// Incr adds one to the counter, starting it with the given lifetime if it does not exist or
+	// has expired, and returns the new count and the time left until it expires
+	Incr(ctx context.Context, key string, lifetime time.Duration) (count int64, ttl time.Duration, err error)
+	// Get returns zero for a missing or expired counter
+	Get(ctx context.Context, key string) (count int64, ttl time.Duration, err error)
+	Delete(ctx context.Context, key string) error
+	// Purge removes the expired counters and returns how many there were
+	Purge(ctx context.Context) (int64, error)
+}
+
+// Limit allows Requests per Window, zero Requests disables it
+type Limit struct {
+	Requests int64
+	Window   time.Duration
+}
+
+type Result struct {
+	Allowed   bool
+	Remaining int64
+	// RetryAfter is when the window ends, set when the request is not allowed
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	backend Backend
+}
+
+func NewLimiter(backend Backend) *Limiter {
+	return &Limiter{backend: backend}
+}
+
+// Allow counts a request against key and reports whether it is within the limit
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Requests <= 0 {
+		return Result{Allowed: true}, nil
+	}
+	count, ttl, err := l.backend.Incr(ctx, "limit:"+key, limit.Window)
+	if err != nil {
+		return Result{}, err
+	}
+	if count > limit.Requests {
+		return Result{RetryAfter: ttl}, nil
+	}
+	return Result{Allowed: true, Remaining: limit.Requests - count}, nil
+}
+
+// lockoutMemory is how long earlier lockouts make the next one longer
+const lockoutMemory = 24 * time.Hour
+
+// LockoutPolicy locks an account for Duration after MaxFailures failed logins within
+// FailureWindow. Every further lockout within a day doubles the duration, up to MaxDuration
+// unless that is zero. Zero MaxFailures disables lockouts.
+type LockoutPolicy struct {
+	MaxFailures   int64
+	FailureWindow time.Duration
+	Duration      time.Duration
+	MaxDuration   time.Duration
+}
+
+// Lockout tracks failed logins by username. Usernames that do not exist are tracked like the
+// others, so a lockout does not reveal whether an account exists.
+type Lockout struct {
+	backend Backend
+	policy  LockoutPolicy
+}
+
+func NewLockout(backend Backend, policy LockoutPolicy) *Lockout {
+	return &Lockout{backend: backend, policy: policy}
+}
+
+func lockoutKeys(username string) (failures, lockouts, locked string) {
+	username = strings.ToLower(username)
+	return "login:failures:" + username, "login:lockouts:" + username, "login:locked:" + username
+}
+
+// Locked returns how long the account stays locked, zero if it is not
+func (l *Lockout) Locked(ctx context.Context, username string) (time.Duration, error) {
+	_, _, locked := lockoutKeys(username)
+	count, ttl, err := l.backend.Get(ctx, locked)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// Fail records a failed login and returns how long the account is now locked, zero if the
+// failure did not lock it
+func (l *Lockout) Fail(ctx context.Context, username string) (time.Duration, error) {
+	if l.policy.MaxFailures <= 0 {
+		return 0, nil
+	}
+	failuresKey, lockoutsKey, lockedKey := lockoutKeys(username)
+	failures, _, err := l.backend.Incr(ctx, failuresKey, l.policy.FailureWindow)
+	if err != nil || failures < l.policy.MaxFailures {
+		return 0, err
+	}
+	if err := l.backend.Delete(ctx, failuresKey); err != nil {
+		return 0, err
+	}
+	lockouts, _, err := l.backend.Incr(ctx, lockoutsKey, lockoutMemory)
+	if err != nil {
+		return 0, err
+	}
+	duration := l.policy.Duration
+	for i := int64(1); i < lockouts && (l.policy.MaxDuration <= 0 || duration < l.policy.MaxDuration) && duration <= math.MaxInt64/2; i++ {
+		duration *= 2
+	}
+	if l.policy.MaxDuration > 0 && duration > l.policy.MaxDuration {
+		duration = l.policy.MaxDuration
+	}
+	if _, _, err := l.backend.Incr(ctx, lockedKey, duration); err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
+
+// Succeed forgets the failed logins before a successful one
+func (l *Lockout) Succeed(ctx context.Context, username string) error {
+	failures, _, _ := lockoutKeys(username)
+	return l.backend.Delete(ctx, failures)
+}
+
+// Unlock lifts a lockout and forgets the earlier ones, for admins
+func (l *Lockout) Unlock(ctx context.Context, username string) error {
+	failures, lockouts, locked := lockoutKeys(username)
+	for _, key := range []string{locked, failures, lockouts} {
+		if err := l.backend.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// clock is a time that tests move forward by hand
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newGorm(t *testing.T, now func() time.Time) *Gorm {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ratelimit.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.RateLimitCounter{}); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGorm(db)
+	g.now = now
+	return g
+}
+
+// backends runs the test against every backend, each with its own clock
+func backends(t *testing.T, test func(t *testing.T, backend Backend, clock *clock)) {
+	t.Run("memory", func(t *testing.T) {
+		clock := &clock{now: time.Unix(1700000000, 0)}
+		m := NewMemory()
+		m.now = clock.Now
+		test(t, m, clock)
+	})
+	t.Run("gorm", func(t *testing.T) {
+		clock := &clock{now: time.Unix(1700000000, 0)}
+		test(t, newGorm(t, clock.Now), clock)
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	backends(t, func(t *testing.T, backend Backend, clock *clock) {
+		ctx := context.Background()
+		limiter := NewLimiter(backend)
+		limit := Limit{Requests: 2, Window: time.Minute}
+
+		for i, want := range []int64{1, 0} {
+			result, err := limiter.Allow(ctx, "client", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Allowed || result.Remaining != want {
+				t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, result, want)
+			}
+		}
+		clock.now = clock.now.Add(20 * time.Second)
+		result, err := limiter.Allow(ctx, "client", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed || result.RetryAfter != 40*time.Second {
+			t.Fatalf("request over the limit = %+v, want refused for 40s", result)
+		}
+		if result, _ := limiter.Allow(ctx, "other", limit); !result.Allowed {
+			t.Errorf("other key refused, keys must be limited separately")
+		}
+
+		clock.now = clock.now.Add(40 * time.Second)
+		if result, _ := limiter.Allow(ctx, "client", limit); !result.Allowed || result.Remaining != 1 {
+			t.Errorf("request in the next window = %+v, want allowed with 1 remaining", result)
+		}
+	})
+}
+
+func TestLockout(t *testing.T) {
+	backends(t, func(t *testing.T, backend Backend, clock *clock) {
+		ctx := context.Background()
+		lockout := NewLockout(backend, LockoutPolicy{MaxFailures: 2, FailureWindow: time.Minute, Duration: time.Minute, MaxDuration: 3 * time.Minute})
+
+		lockOut := func() time.Duration {
+			t.Helper()
+			if locked, err := lockout.Fail(ctx, "Driver"); err != nil || locked != 0 {
+				t.Fatalf("first failure locked = %v, %v, want not locked", locked, err)
+			}
+			locked, err := lockout.Fail(ctx, "driver")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return locked
+		}
+		for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+			if locked := lockOut(); locked != want {
+				t.Fatalf("lockout %d = %v, want %v", i+1, locked, want)
+			}
+			if locked, _ := lockout.Locked(ctx, "DRIVER"); locked != want {
+				t.Fatalf("locked after lockout %d = %v, want %v", i+1, locked, want)
+			}
+			clock.now = clock.now.Add(want)
+			if locked, _ := lockout.Locked(ctx, "driver"); locked != 0 {
+				t.Fatalf("still locked %v after lockout %d ran out", locked, i+1)
+			}
+		}
+
+		lockOut()
+		if err := lockout.Unlock(ctx, "driver"); err != nil {
+			t.Fatal(err)
+		}
+		if locked, _ := lockout.Locked(ctx, "driver"); locked != 0 {
+			t.Errorf("locked %v after unlock", locked)
+		}
+		if locked := lockOut(); locked != time.Minute {
+			t.Errorf("lockout after unlock = %v, want the first duration again", locked)
+		}
+	})
+}
+
+func TestPurge(t *testing.T) {
+	backends(t, func(t *testing.T, backend Backend, clock *clock) {
+		ctx := context.Background()
+		backend.Incr(ctx, "short", time.Second)
+		backend.Incr(ctx, "long", time.Hour)
+		clock.now = clock.now.Add(time.Minute)
+
+		purged, err := backend.Purge(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged != 1 {
+			t.Errorf("purged %d counters, want 1", purged)
+		}
+		if count, _, _ := backend.Get(ctx, "long"); count != 1 {
+			t.Errorf("long counter = %d after purge, want 1", count)
+		}
+	})
+}
+
+func TestLockoutWithoutCap(t *testing.T) {
+	backends(t, func(t *testing.T, backend Backend, clock *clock) {
+		ctx := context.Background()
+		lockout := NewLockout(backend, LockoutPolicy{MaxFailures: 1, FailureWindow: time.Minute, Duration: time.Minute})
+
+		for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute} {
+			locked, err := lockout.Fail(ctx, "driver")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if locked != want {
+				t.Fatalf("lockout %d = %v, want %v", i+1, locked, want)
+			}
+			clock.now = clock.now.Add(want)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/geo"
+)
+
+type Engine interface {
+	// RoadDistance returns the driving distance in kilometers along the given points
+	RoadDistance(ctx context.Context, points []geo.Point) (float64, error)
+}
+
+// OSRMEngine talks to an OSRM compatible routing service over HTTP
+type OSRMEngine struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOSRMEngine(baseURL string) Engine {
+	return &OSRMEngine{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type osrmResponse struct {
+	Code   string `json:"code"`
+	Routes []struct {
+		Distance float64 `json:"distance"`
+	} `json:"routes"`
+}
+
+// RoadDistance asks the routing service for the driving route through the points
+func (e *OSRMEngine) RoadDistance(ctx context.Context, points []geo.Point) (float64, error) {
+	if len(points) < 2 {
+		return 0, errors.New("at least two points are required")
+	}
+	coordinates := make([]string, len(points))
+	for i, p := range points {
+		coordinates[i] = fmt.Sprintf("%f,%f", p.Longitude, p.Latitude)
+	}
+	url := fmt.Sprintf("%s/route/v1/driving/%s?overview=false", e.baseURL, strings.Join(coordinates, ";"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("routing engine request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("routing engine returned status %d", resp.StatusCode)
+	}
+
+	var body osrmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("cannot decode routing engine response: %w", err)
+	}
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return 0, fmt.Errorf("routing engine found no route: %s", body.Code)
+	}
+	// OSRM reports meters
+	return body.Routes[0].Distance / 1000, nil
+}
@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rassulmagauin/VMS_SWE/geo"
+)
+
+var route = []geo.Point{
+	{Latitude: 51.1694, Longitude: 71.4491},
+	{Latitude: 51.1282, Longitude: 71.4306},
+	{Latitude: 51.0905, Longitude: 71.4180},
+}
+
+// osrmStandIn answers route requests with the given status and body and records the requested paths
+func osrmStandIn(t *testing.T, status int, body string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestOSRMEngineRoadDistance(t *testing.T) {
+	server, requested := osrmStandIn(t, http.StatusOK, `{"code":"Ok","routes":[{"distance":9250.5},{"distance":12000}]}`)
+
+	distance, err := NewOSRMEngine(server.URL+"/").RoadDistance(context.Background(), route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distance != 9.2505 {
+		t.Errorf("distance = %v km, want the first route in km, 9.2505", distance)
+	}
+	want := "/route/v1/driving/71.449100,51.169400;71.430600,51.128200;71.418000,51.090500?overview=false"
+	if len(*requested) != 1 || (*requested)[0] != want {
+		t.Errorf("requested %v, want %s", *requested, want)
+	}
+}
+
+func TestOSRMEngineErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"server error", http.StatusInternalServerError, `{}`, "status 500"},
+		{"no route", http.StatusOK, `{"code":"NoRoute","routes":[]}`, "no route: NoRoute"},
+		{"invalid json", http.StatusOK, `<html>`, "cannot decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := osrmStandIn(t, tt.status, tt.body)
+			_, err := NewOSRMEngine(server.URL).RoadDistance(context.Background(), route)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSRMEngineNeedsTwoPoints(t *testing.T) {
+	server, requested := osrmStandIn(t, http.StatusOK, `{"code":"Ok","routes":[{"distance":1}]}`)
+	if _, err := NewOSRMEngine(server.URL).RoadDistance(context.Background(), route[:1]); err == nil {
+		t.Error("route through one point succeeded")
+	}
+	if len(*requested) != 0 {
+		t.Errorf("requested %v, want no request", *requested)
+	}
+}
+
+func TestOSRMEngineUnreachable(t *testing.T) {
+	server, _ := osrmStandIn(t, http.StatusOK, "")
+	server.Close()
+	if _, err := NewOSRMEngine(server.URL).RoadDistance(context.Background(), route); err == nil || !strings.Contains(err.Error(), "request failed") {
+		t.Errorf("error = %v, want the request to fail", err)
+	}
+}
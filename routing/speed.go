@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAverageSpeed is used for vehicle types without a configured speed, in km/h
+const DefaultAverageSpeed = 50.0
+
+// Speeds maps a vehicle type to its average speed in km/h
+type Speeds map[string]float64
+
+// ParseSpeeds parses a list like "Car=60,Truck=45,Bus=40"
+func ParseSpeeds(s string) (Speeds, error) {
+	speeds := Speeds{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		vehicleType, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid speed entry %q, expected type=kmh", entry)
+		}
+		speed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || speed <= 0 {
+			return nil, fmt.Errorf("invalid speed for %q", vehicleType)
+		}
+		speeds[strings.ToLower(strings.TrimSpace(vehicleType))] = speed
+	}
+	return speeds, nil
+}
+
+// For returns the average speed of the vehicle type, falling back to DefaultAverageSpeed
+func (s Speeds) For(vehicleType *string) float64 {
+	if vehicleType != nil {
+		if speed, ok := s[strings.ToLower(*vehicleType)]; ok {
+			return speed
+		}
+	}
+	return DefaultAverageSpeed
+}
+
+// EstimateDuration returns the time needed to cover distanceKm at speedKmh
+func EstimateDuration(distanceKm, speedKmh float64) time.Duration {
+	if speedKmh <= 0 {
+		return 0
+	}
+	return time.Duration(distanceKm / speedKmh * float64(time.Hour))
+}
@@ -0,0 +1,278 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	// clockSkew tolerates replicas whose clocks run slightly behind the one that set next_run_at
+	clockSkew = 2 * time.Second
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrLocked     = errors.New("job is already running")
+	// ErrIdle is returned by a job that found nothing to do. Such scheduled runs are left out of
+	// the history, so jobs that poll often only record the runs that did something.
+	ErrIdle = errors.New("nothing to do")
+)
+
+// Func is the work of a job, ctx expires when the job's lock does
+type Func func(ctx context.Context) error
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	timeout  time.Duration
+	run      Func
+}
+
+// Scheduler runs cron style jobs. Each job has a row in the jobs table that a replica must lock before
+// running it, so with several replicas every scheduled run happens on only one of them.
+type Scheduler struct {
+	db      *gorm.DB
+	owner   string
+	cron    *cron.Cron
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running sync.WaitGroup
+	// ctx is the parent of every run, cancelled when Stop gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(db *gorm.DB) *Scheduler {
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		ctx:    ctx,
+		cancel: cancel,
+		db:     db,
+		owner:  fmt.Sprintf("%s/%d/%s", host, os.Getpid(), uuid.NewString()[:8]),
+		cron:   cron.New(),
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Register adds a job. spec is a standard five field cron expression or a descriptor such as
+// "@daily" or "@every 10s"; timeout bounds a run and is how long the job stays locked.
+func (s *Scheduler) Register(name, spec string, timeout time.Duration, run Func) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	j := &job{name: name, spec: spec, schedule: schedule, timeout: timeout, run: run}
+	s.jobs[name] = j
+	s.cron.Schedule(schedule, cron.FuncJob(func() { s.runScheduled(j) }))
+	return nil
+}
+
+// Start records the registered jobs in the database and begins running them on schedule
+func (s *Scheduler) Start() error {
+	now := time.Now()
+	for _, j := range s.jobs {
+		var row models.Job
+		err := s.db.Where("name = ?", j.name).First(&row).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		row.Name = j.name
+		row.Schedule = j.spec
+		if row.NextRunAt == nil || row.NextRunAt.After(j.schedule.Next(now)) {
+			next := j.schedule.Next(now)
+			row.NextRunAt = &next
+		}
+		if err := s.db.Save(&row).Error; err != nil {
+			return err
+		}
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop stops scheduling new runs and waits for running ones until ctx is done,
+// then cancels the context of the runs still going
+func (s *Scheduler) Stop(ctx context.Context) error {
+	<-s.cron.Stop().Done()
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// Jobs returns the stored state of the registered jobs
+func (s *Scheduler) Jobs() ([]models.Job, error) {
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	var jobs []models.Job
+	err := s.db.Where("name IN ?", names).Order("name").Find(&jobs).Error
+	return jobs, err
+}
+
+// Trigger starts a run of the job right away, independent of its schedule.
+// It returns the run record while the job continues in the background.
+func (s *Scheduler) Trigger(name string) (*models.JobRun, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	acquired, err := s.acquire(j, time.Now(), false)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrLocked
+	}
+	run := newRun(j, TriggerManual, s.owner)
+	if err := s.db.Create(run).Error; err != nil {
+		s.release(j, StatusFailed)
+		return nil, err
+	}
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.execute(j, run)
+	}()
+	return run, nil
+}
+
+func (s *Scheduler) runScheduled(j *job) {
+	acquired, err := s.acquire(j, time.Now(), true)
+	if err != nil {
+		slog.Error("cannot lock job", slog.String("job", j.name), slog.Any("error", err))
+		return
+	}
+	if !acquired {
+		return
+	}
+	s.running.Add(1)
+	defer s.running.Done()
+	// the job row shows the run while it is going, its history entry is written once it is done
+	s.execute(j, newRun(j, TriggerSchedule, s.owner))
+}
+
+// acquire locks the job row for this replica. Scheduled runs also claim the current slot by moving
+// next_run_at forward, so a replica firing a moment later finds nothing to do.
+func (s *Scheduler) acquire(j *job, now time.Time, scheduled bool) (bool, error) {
+	query := s.db.Model(&models.Job{}).
+		Where("name = ? AND (locked_until IS NULL OR locked_until < ?)", j.name, now)
+	updates := map[string]interface{}{
+		"locked_by":    s.owner,
+		"locked_until": now.Add(j.timeout),
+	}
+	if scheduled {
+		query = query.Where("next_run_at IS NULL OR next_run_at <= ?", now.Add(clockSkew))
+		updates["next_run_at"] = j.schedule.Next(now)
+	}
+	result := query.Updates(updates)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (s *Scheduler) release(j *job, status string) {
+	err := s.db.Model(&models.Job{}).
+		Where("name = ? AND locked_by = ?", j.name, s.owner).
+		Updates(map[string]interface{}{
+			"locked_by":    nil,
+			"locked_until": nil,
+			"last_run_at":  time.Now(),
+			"last_status":  status,
+		}).Error
+	if err != nil {
+		slog.Error("cannot release job", slog.String("job", j.name), slog.Any("error", err))
+	}
+}
+
+func newRun(j *job, trigger, owner string) *models.JobRun {
+	return &models.JobRun{
+		JobName:   j.name,
+		Trigger:   trigger,
+		RunBy:     owner,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}
+}
+
+// execute runs the job and records the outcome. Manual runs were stored when they started,
+// scheduled ones are stored now unless the job was idle.
+func (s *Scheduler) execute(j *job, run *models.JobRun) {
+	ctx, cancel := context.WithTimeout(s.ctx, j.timeout)
+	defer cancel()
+	err := safeRun(ctx, j.run)
+	idle := errors.Is(err, ErrIdle)
+	if idle {
+		err = nil
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = StatusSucceeded
+	if err != nil {
+		message := err.Error()
+		run.Status = StatusFailed
+		run.Error = &message
+		slog.Error("job failed", slog.String("job", j.name), slog.Any("error", err))
+	}
+	var recorded error
+	switch {
+	case run.ID != 0:
+		recorded = s.db.Save(run).Error
+	case !idle:
+		recorded = s.db.Create(run).Error
+	}
+	if recorded != nil {
+		slog.Error("cannot record job run", slog.String("job", j.name), slog.Any("error", recorded))
+	}
+	s.release(j, run.Status)
+}
+
+// safeRun turns a panicking job into a failed run instead of a crashed server
+func safeRun(ctx context.Context, run Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// PurgeRuns deletes the run history recorded before the given time
+func (s *Scheduler) PurgeRuns(before time.Time) (int64, error) {
+	result := s.db.Unscoped().Where("started_at < ?", before).Delete(&models.JobRun{})
+	return result.RowsAffected, result.Error
+}
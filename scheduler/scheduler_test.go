@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scheduler.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Job{}, &models.JobRun{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// start runs a scheduler with the given jobs, all scheduled hourly so they only run when a test asks
+func start(t *testing.T, db *gorm.DB, jobs map[string]Func) *Scheduler {
+	t.Helper()
+	s := New(db)
+	for name, run := range jobs {
+		if err := s.Register(name, "@hourly", time.Minute, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop(context.Background()) })
+	return s
+}
+
+// due makes the scheduled slot of the job come around now
+func due(t *testing.T, db *gorm.DB, name string) {
+	t.Helper()
+	if err := db.Model(&models.Job{}).Where("name = ?", name).Update("next_run_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runs(t *testing.T, db *gorm.DB, name string) []models.JobRun {
+	t.Helper()
+	var runs []models.JobRun
+	if err := db.Where("job_name = ?", name).Order("id").Find(&runs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return runs
+}
+
+func TestScheduledRunHappensOnOneReplica(t *testing.T) {
+	db := newDB(t)
+	var calls int
+	count := func(ctx context.Context) error {
+		calls++
+		return nil
+	}
+	first := start(t, db, map[string]Func{"count": count})
+	second := start(t, db, map[string]Func{"count": count})
+
+	due(t, db, "count")
+	first.runScheduled(first.jobs["count"])
+	second.runScheduled(second.jobs["count"])
+	if calls != 1 {
+		t.Errorf("job ran %d times for one slot, want 1", calls)
+	}
+	if runs := runs(t, db, "count"); len(runs) != 1 || runs[0].RunBy != first.owner {
+		t.Errorf("runs = %+v, want one by the first replica", runs)
+	}
+}
+
+func TestTriggerWhileRunning(t *testing.T) {
+	db := newDB(t)
+	release := make(chan struct{})
+	block := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+	first := start(t, db, map[string]Func{"block": block})
+	second := start(t, db, map[string]Func{"block": block})
+
+	if _, err := first.Trigger("block"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Trigger("block"); !errors.Is(err, ErrLocked) {
+		t.Errorf("trigger on another replica while running = %v, want ErrLocked", err)
+	}
+	due(t, db, "block")
+	second.runScheduled(second.jobs["block"])
+	close(release)
+	if err := first.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs := runs(t, db, "block"); len(runs) != 1 || runs[0].Trigger != TriggerManual || runs[0].Status != StatusSucceeded {
+		t.Errorf("runs = %+v, want only the manual run, succeeded", runs)
+	}
+	if _, err := second.Trigger("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("trigger of unknown job = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	db := newDB(t)
+	s := start(t, db, map[string]Func{
+		"succeed": func(ctx context.Context) error { return nil },
+		"fail":    func(ctx context.Context) error { return errors.New("database is down") },
+		"panic":   func(ctx context.Context) error { panic("nil map") },
+		"idle":    func(ctx context.Context) error { return ErrIdle },
+	})
+	for _, name := range []string{"succeed", "fail", "panic", "idle"} {
+		due(t, db, name)
+		s.runScheduled(s.jobs[name])
+	}
+
+	for name, want := range map[string]string{"succeed": "", "fail": "database is down", "panic": "panic: nil map"} {
+		runs := runs(t, db, name)
+		if len(runs) != 1 {
+			t.Errorf("%s has %d runs, want 1", name, len(runs))
+			continue
+		}
+		run := runs[0]
+		if run.FinishedAt == nil || run.Trigger != TriggerSchedule {
+			t.Errorf("%s run = %+v, want a finished scheduled run", name, run)
+		}
+		switch {
+		case want == "" && (run.Status != StatusSucceeded || run.Error != nil):
+			t.Errorf("%s run = %s %v, want succeeded", name, run.Status, run.Error)
+		case want != "" && (run.Status != StatusFailed || run.Error == nil || *run.Error != want):
+			t.Errorf("%s run = %s %v, want failed with %q", name, run.Status, run.Error, want)
+		}
+	}
+	if runs := runs(t, db, "idle"); len(runs) != 0 {
+		t.Errorf("idle scheduled run recorded %+v, want no history", runs)
+	}
+
+	// the job row still shows that the idle job ran, and a manual run of it is recorded
+	var job models.Job
+	if err := db.Where("name = ?", "idle").First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.LastRunAt == nil || job.LastStatus == nil || *job.LastStatus != StatusSucceeded || job.LockedBy != nil {
+		t.Errorf("idle job = %+v, want released with a last run", job)
+	}
+	if _, err := s.Trigger("idle"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if runs := runs(t, db, "idle"); len(runs) != 1 || runs[0].Status != StatusSucceeded {
+		t.Errorf("manual idle runs = %+v, want one succeeded", runs)
+	}
+
+	purged, err := s.PurgeRuns(time.Now().Add(time.Minute))
+	if err != nil || purged != 4 {
+		t.Errorf("purged %d runs, %v, want 4", purged, err)
+	}
+}
@@ -0,0 +1,433 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"github.com/rassulmagauin/VMS_SWE/outbox"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGorm returns the store backed by db. Passing a transaction binds the store to it.
+func NewGorm(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Vehicles() VehicleStore             { return gormVehicles{s.db} }
+func (s *gormStore) Users() UserStore                   { return gormUsers{s.db} }
+func (s *gormStore) Tasks() TaskStore                   { return gormTasks{s.db} }
+func (s *gormStore) Fuelings() FuelingStore             { return gormFuelings{s.db} }
+func (s *gormStore) Maintenance() MaintenanceStore      { return gormMaintenance{s.db} }
+func (s *gormStore) Auctions() AuctionStore             { return gormAuctions{s.db} }
+func (s *gormStore) PasswordResets() PasswordResetStore { return gormPasswordResets{s.db} }
+func (s *gormStore) Licenses() LicenseStore             { return gormLicenses{s.db} }
+
+func (s *gormStore) WriteEvent(ctx context.Context, evt events.Event) error {
+	return outbox.Write(s.db.WithContext(ctx), evt)
+}
+
+func (s *gormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewGorm(tx))
+	})
+}
+
+// deleted reports ErrNotFound when a delete matched no row
+func deleted(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormVehicles struct {
+	db *gorm.DB
+}
+
+func (s gormVehicles) Create(ctx context.Context, vehicle *models.Vehicle) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(vehicle).Error
+}
+
+func (s gormVehicles) Get(ctx context.Context, id uint) (models.Vehicle, error) {
+	var vehicle models.Vehicle
+	err := s.db.WithContext(ctx).First(&vehicle, id).Error
+	return vehicle, err
+}
+
+func (s gormVehicles) List(ctx context.Context) ([]models.Vehicle, error) {
+	var vehicles []models.Vehicle
+	err := s.db.WithContext(ctx).Order("id").Find(&vehicles).Error
+	return vehicles, err
+}
+
+func (s gormVehicles) ListByDriver(ctx context.Context, driverID uint) ([]models.Vehicle, error) {
+	var vehicles []models.Vehicle
+	err := s.db.WithContext(ctx).Where("assigned_driver = ?", driverID).Order("id").Find(&vehicles).Error
+	return vehicles, err
+}
+
+func (s gormVehicles) Update(ctx context.Context, vehicle *models.Vehicle) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(vehicle).Error
+}
+
+func (s gormVehicles) Delete(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.Vehicle{}, id))
+}
+
+func (s gormVehicles) Assign(ctx context.Context, vehicle *models.Vehicle, driverID uint, at time.Time) (models.VehicleAssignment, error) {
+	vehicle.AssignedDriver = &driverID
+	if err := s.Update(ctx, vehicle); err != nil {
+		return models.VehicleAssignment{}, err
+	}
+	assignment := models.VehicleAssignment{VehicleID: &vehicle.ID, DriverID: &driverID, AssignedAt: at}
+	err := s.db.WithContext(ctx).Create(&assignment).Error
+	return assignment, err
+}
+
+func (s gormVehicles) Unassign(ctx context.Context, vehicle *models.Vehicle, at time.Time) error {
+	vehicle.AssignedDriver = nil
+	if err := s.Update(ctx, vehicle); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&models.VehicleAssignment{}).
+		Where("vehicle_id = ? AND unassigned_at IS NULL", vehicle.ID).
+		Update("unassigned_at", at).Error
+}
+
+func (s gormVehicles) AssignmentAt(ctx context.Context, vehicleID uint, at time.Time) (models.VehicleAssignment, error) {
+	var assignment models.VehicleAssignment
+	err := s.db.WithContext(ctx).
+		Where("vehicle_id = ? AND assigned_at <= ? AND (unassigned_at IS NULL OR unassigned_at > ?)", vehicleID, at, at).
+		Order("assigned_at DESC").First(&assignment).Error
+	return assignment, err
+}
+
+func (s gormVehicles) CountAssignments(ctx context.Context, vehicleID uint) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.VehicleAssignment{}).Where("vehicle_id = ?", vehicleID).Count(&count).Error
+	return count, err
+}
+
+func (s gormVehicles) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status *string
+		Count  int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.Vehicle{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64)
+	for _, row := range rows {
+		var status string
+		if row.Status != nil {
+			status = *row.Status
+		}
+		counts[status] += row.Count
+	}
+	return counts, nil
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (s gormUsers) Create(ctx context.Context, user *models.User) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(user).Error
+}
+
+func (s gormUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).First(&user, id).Error
+	return user, err
+}
+
+func (s gormUsers) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	return user, err
+}
+
+func (s gormUsers) List(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := s.db.WithContext(ctx).Order("id").Find(&users).Error
+	return users, err
+}
+
+func (s gormUsers) Update(ctx context.Context, user *models.User) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(user).Error
+}
+
+func (s gormUsers) Delete(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.User{}, id))
+}
+
+type gormTasks struct {
+	db *gorm.DB
+}
+
+func (s gormTasks) withWaypoints(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Preload("Waypoints", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence")
+	})
+}
+
+func (s gormTasks) Create(ctx context.Context, task *models.Task) error {
+	return s.db.WithContext(ctx).Omit("Driver", "Vehicle").Create(task).Error
+}
+
+func (s gormTasks) Get(ctx context.Context, id uint) (models.Task, error) {
+	var task models.Task
+	err := s.withWaypoints(ctx).First(&task, id).Error
+	return task, err
+}
+
+func (s gormTasks) List(ctx context.Context) ([]models.Task, error) {
+	var tasks []models.Task
+	err := s.withWaypoints(ctx).Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+func (s gormTasks) ListByDriver(ctx context.Context, driverID uint) ([]models.Task, error) {
+	var tasks []models.Task
+	err := s.withWaypoints(ctx).Where("driver_id = ?", driverID).Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+func (s gormTasks) Update(ctx context.Context, task *models.Task) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(task).Error
+}
+
+func (s gormTasks) ReplaceWaypoints(ctx context.Context, taskID uint, waypoints []models.TaskWaypoint) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Where("task_id = ?", taskID).Delete(&models.TaskWaypoint{}).Error; err != nil {
+		return err
+	}
+	if len(waypoints) == 0 {
+		return nil
+	}
+	for i := range waypoints {
+		waypoints[i].TaskID = &taskID
+	}
+	return db.Create(&waypoints).Error
+}
+
+func (s gormTasks) Delete(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.Task{}, id))
+}
+
+func (s gormTasks) CountOpen(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Task{}).
+		Where("status IS NULL OR LOWER(status) NOT IN ?", closedTaskStatuses).Count(&count).Error
+	return count, err
+}
+
+type gormFuelings struct {
+	db *gorm.DB
+}
+
+func (s gormFuelings) Create(ctx context.Context, fueling *models.FuelingRecord) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(fueling).Error
+}
+
+func (s gormFuelings) Get(ctx context.Context, id uint) (models.FuelingRecord, error) {
+	var fueling models.FuelingRecord
+	err := s.db.WithContext(ctx).First(&fueling, id).Error
+	return fueling, err
+}
+
+func (s gormFuelings) List(ctx context.Context) ([]models.FuelingRecord, error) {
+	var fuelings []models.FuelingRecord
+	err := s.db.WithContext(ctx).Order("id").Find(&fuelings).Error
+	return fuelings, err
+}
+
+func (s gormFuelings) ListByVehicle(ctx context.Context, vehicleID uint) ([]models.FuelingRecord, error) {
+	var fuelings []models.FuelingRecord
+	err := s.db.WithContext(ctx).Where("vehicle_id = ?", vehicleID).Order("id").Find(&fuelings).Error
+	return fuelings, err
+}
+
+func (s gormFuelings) ListByPerson(ctx context.Context, userID uint) ([]models.FuelingRecord, error) {
+	var fuelings []models.FuelingRecord
+	err := s.db.WithContext(ctx).Where("fueling_person_id = ?", userID).Order("id").Find(&fuelings).Error
+	return fuelings, err
+}
+
+func (s gormFuelings) Delete(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.FuelingRecord{}, id))
+}
+
+type gormMaintenance struct {
+	db *gorm.DB
+}
+
+func (s gormMaintenance) Create(ctx context.Context, record *models.MaintenanceRecord) error {
+	return s.db.WithContext(ctx).Omit("Vehicle").Create(record).Error
+}
+
+func (s gormMaintenance) Get(ctx context.Context, id uint) (models.MaintenanceRecord, error) {
+	var record models.MaintenanceRecord
+	err := s.db.WithContext(ctx).First(&record, id).Error
+	return record, err
+}
+
+func (s gormMaintenance) List(ctx context.Context) ([]models.MaintenanceRecord, error) {
+	var records []models.MaintenanceRecord
+	err := s.db.WithContext(ctx).Order("id").Find(&records).Error
+	return records, err
+}
+
+func (s gormMaintenance) ListByVehicle(ctx context.Context, vehicleID uint) ([]models.MaintenanceRecord, error) {
+	var records []models.MaintenanceRecord
+	err := s.db.WithContext(ctx).Where("vehicle_id = ?", vehicleID).Order("id").Find(&records).Error
+	return records, err
+}
+
+func (s gormMaintenance) ListByPerson(ctx context.Context, userID uint) ([]models.MaintenanceRecord, error) {
+	var records []models.MaintenanceRecord
+	err := s.db.WithContext(ctx).Where("maintenance_person_id = ?", userID).Order("id").Find(&records).Error
+	return records, err
+}
+
+func (s gormMaintenance) Update(ctx context.Context, record *models.MaintenanceRecord) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(record).Error
+}
+
+func (s gormMaintenance) Delete(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.MaintenanceRecord{}, id))
+}
+
+func (s gormMaintenance) CountPending(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.MaintenanceRecord{}).Where("status = ?", models.MaintenanceStatusPending).Count(&count).Error
+	return count, err
+}
+
+type gormAuctions struct {
+	db *gorm.DB
+}
+
+func (s gormAuctions) Create(ctx context.Context, auction *models.AuctionVehicle) error {
+	return s.db.WithContext(ctx).Omit("Vehicle").Create(auction).Error
+}
+
+func (s gormAuctions) Get(ctx context.Context, id uint) (models.AuctionVehicle, error) {
+	var auction models.AuctionVehicle
+	err := s.db.WithContext(ctx).Preload("Images").First(&auction, id).Error
+	return auction, err
+}
+
+func (s gormAuctions) List(ctx context.Context) ([]models.AuctionVehicle, error) {
+	var auctions []models.AuctionVehicle
+	err := s.db.WithContext(ctx).Preload("Images").Order("id").Find(&auctions).Error
+	return auctions, err
+}
+
+func (s gormAuctions) Delete(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.AuctionVehicle{}, id))
+}
+
+func (s gormAuctions) ListEnded(ctx context.Context, at time.Time) ([]models.AuctionVehicle, error) {
+	var auctions []models.AuctionVehicle
+	err := s.db.WithContext(ctx).Where("closed_at IS NULL AND ends_at <= ?", at).Order("id").Find(&auctions).Error
+	return auctions, err
+}
+
+func (s gormAuctions) Close(ctx context.Context, auction *models.AuctionVehicle, winnerID *uint, at time.Time) (bool, error) {
+	// the condition keeps two admins closing the auction at once from both succeeding
+	result := s.db.WithContext(ctx).Model(auction).Where("closed_at IS NULL").
+		Updates(map[string]interface{}{"winner_id": winnerID, "closed_at": at})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	auction.WinnerID = winnerID
+	auction.ClosedAt = &at
+	return true, nil
+}
+
+type gormPasswordResets struct {
+	db *gorm.DB
+}
+
+func (s gormPasswordResets) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(token).Error
+}
+
+func (s gormPasswordResets) Use(ctx context.Context, tokenHash string, at time.Time) (models.PasswordResetToken, error) {
+	// the conditions keep two requests from both using the token
+	result := s.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, at).
+		Update("used_at", at)
+	if result.Error != nil {
+		return models.PasswordResetToken{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.PasswordResetToken{}, ErrNotFound
+	}
+	var token models.PasswordResetToken
+	err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	return token, err
+}
+
+func (s gormPasswordResets) DeleteForUser(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Where("user_id = ? AND used_at IS NULL", userID).Delete(&models.PasswordResetToken{}).Error
+}
+
+func (s gormPasswordResets) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Unscoped().Where("expires_at < ?", before).Delete(&models.PasswordResetToken{})
+	return result.RowsAffected, result.Error
+}
+
+type gormLicenses struct {
+	db *gorm.DB
+}
+
+func (s gormLicenses) Get(ctx context.Context, userID uint) (models.DriverLicense, error) {
+	var license models.DriverLicense
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&license).Error
+	return license, err
+}
+
+func (s gormLicenses) Save(ctx context.Context, license *models.DriverLicense) error {
+	return s.db.WithContext(ctx).Save(license).Error
+}
+
+func (s gormLicenses) CreateQualification(ctx context.Context, qualification *models.DriverQualification) error {
+	return s.db.WithContext(ctx).Create(qualification).Error
+}
+
+func (s gormLicenses) GetQualification(ctx context.Context, id uint) (models.DriverQualification, error) {
+	var qualification models.DriverQualification
+	err := s.db.WithContext(ctx).First(&qualification, id).Error
+	return qualification, err
+}
+
+func (s gormLicenses) ListQualifications(ctx context.Context, userID uint) ([]models.DriverQualification, error) {
+	var qualifications []models.DriverQualification
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("kind").Find(&qualifications).Error
+	return qualifications, err
+}
+
+func (s gormLicenses) DeleteQualification(ctx context.Context, id uint) error {
+	return deleted(s.db.WithContext(ctx).Delete(&models.DriverQualification{}, id))
+}
+
+func (s gormLicenses) HasQualification(ctx context.Context, userID uint, kind string, until time.Time) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.DriverQualification{}).
+		Where("user_id = ? AND kind = ? AND (expires_at IS NULL OR expires_at >= ?)", userID, kind, until).
+		Count(&count).Error
+	return count > 0, err
+}
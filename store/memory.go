@@ -0,0 +1,679 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+)
+
+// Memory is an in-memory Store for tests. Transactions are not isolated and are not rolled
+// back when they fail, and deleted records are removed rather than soft deleted.
+type Memory struct {
+	mu             sync.Mutex
+	vehicles       table[models.Vehicle]
+	assignments    table[models.VehicleAssignment]
+	users          table[models.User]
+	tasks          table[models.Task]
+	waypoints      table[models.TaskWaypoint]
+	fuelings       table[models.FuelingRecord]
+	maintenance    table[models.MaintenanceRecord]
+	auctions       table[models.AuctionVehicle]
+	resets         table[models.PasswordResetToken]
+	licenses       table[models.DriverLicense]
+	qualifications table[models.DriverQualification]
+	events         []events.Event
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		vehicles:       newTable(func(v *models.Vehicle) (*uint, *gorm.Model) { return &v.ID, &v.Model }),
+		assignments:    newTable(func(a *models.VehicleAssignment) (*uint, *gorm.Model) { return &a.ID, &a.Model }),
+		users:          newTable(func(u *models.User) (*uint, *gorm.Model) { return &u.ID, &u.Model }),
+		tasks:          newTable(func(t *models.Task) (*uint, *gorm.Model) { return &t.ID, &t.Model }),
+		waypoints:      newTable(func(w *models.TaskWaypoint) (*uint, *gorm.Model) { return &w.ID, &w.Model }),
+		fuelings:       newTable(func(f *models.FuelingRecord) (*uint, *gorm.Model) { return &f.ID, &f.Model }),
+		maintenance:    newTable(func(r *models.MaintenanceRecord) (*uint, *gorm.Model) { return &r.ID, &r.Model }),
+		auctions:       newTable(func(a *models.AuctionVehicle) (*uint, *gorm.Model) { return &a.ID, &a.Model }),
+		resets:         newTable(func(r *models.PasswordResetToken) (*uint, *gorm.Model) { return &r.ID, &r.Model }),
+		licenses:       newTable(func(l *models.DriverLicense) (*uint, *gorm.Model) { return &l.ID, &l.Model }),
+		qualifications: newTable(func(q *models.DriverQualification) (*uint, *gorm.Model) { return &q.ID, &q.Model }),
+	}
+}
+
+// Events returns the events written so far
+func (m *Memory) Events() []events.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]events.Event(nil), m.events...)
+}
+
+func (m *Memory) Vehicles() VehicleStore             { return memoryVehicles{m} }
+func (m *Memory) Users() UserStore                   { return memoryUsers{m} }
+func (m *Memory) Tasks() TaskStore                   { return memoryTasks{m} }
+func (m *Memory) Fuelings() FuelingStore             { return memoryFuelings{m} }
+func (m *Memory) Maintenance() MaintenanceStore      { return memoryMaintenance{m} }
+func (m *Memory) Auctions() AuctionStore             { return memoryAuctions{m} }
+func (m *Memory) PasswordResets() PasswordResetStore { return memoryPasswordResets{m} }
+func (m *Memory) Licenses() LicenseStore             { return memoryLicenses{m} }
+
+func (m *Memory) WriteEvent(ctx context.Context, evt events.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if evt.OccurredAt.IsZero() {
+		evt.OccurredAt = time.Now()
+	}
+	m.events = append(m.events, evt)
+	return nil
+}
+
+func (m *Memory) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return fn(m)
+}
+
+// table keeps the rows of one model by ID and fills in the columns GORM would
+type table[T any] struct {
+	rows   map[uint]T
+	lastID uint
+	keys   func(*T) (*uint, *gorm.Model)
+}
+
+func newTable[T any](keys func(*T) (*uint, *gorm.Model)) table[T] {
+	return table[T]{rows: make(map[uint]T), keys: keys}
+}
+
+func (t *table[T]) insert(row *T) {
+	id, model := t.keys(row)
+	if *id == 0 {
+		t.lastID++
+		*id = t.lastID
+	} else if *id > t.lastID {
+		t.lastID = *id
+	}
+	now := time.Now()
+	model.ID = *id
+	model.CreatedAt, model.UpdatedAt = now, now
+	t.rows[*id] = *row
+}
+
+func (t *table[T]) update(row *T) error {
+	id, model := t.keys(row)
+	if _, ok := t.rows[*id]; !ok {
+		return ErrNotFound
+	}
+	model.UpdatedAt = time.Now()
+	t.rows[*id] = *row
+	return nil
+}
+
+func (t *table[T]) get(id uint) (T, error) {
+	row, ok := t.rows[id]
+	if !ok {
+		return row, ErrNotFound
+	}
+	return row, nil
+}
+
+func (t *table[T]) delete(id uint) error {
+	if _, ok := t.rows[id]; !ok {
+		return ErrNotFound
+	}
+	delete(t.rows, id)
+	return nil
+}
+
+// list returns the rows matching keep ordered by ID, keep may be nil
+func (t *table[T]) list(keep func(T) bool) []T {
+	ids := make([]uint, 0, len(t.rows))
+	for id, row := range t.rows {
+		if keep == nil || keep(row) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	rows := make([]T, len(ids))
+	for i, id := range ids {
+		rows[i] = t.rows[id]
+	}
+	return rows
+}
+
+func equal(id *uint, value uint) bool {
+	return id != nil && *id == value
+}
+
+type memoryVehicles struct {
+	m *Memory
+}
+
+func (s memoryVehicles) Create(ctx context.Context, vehicle *models.Vehicle) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.vehicles.insert(vehicle)
+	return nil
+}
+
+func (s memoryVehicles) Get(ctx context.Context, id uint) (models.Vehicle, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.vehicles.get(id)
+}
+
+func (s memoryVehicles) List(ctx context.Context) ([]models.Vehicle, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.vehicles.list(nil), nil
+}
+
+func (s memoryVehicles) ListByDriver(ctx context.Context, driverID uint) ([]models.Vehicle, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.vehicles.list(func(v models.Vehicle) bool { return equal(v.AssignedDriver, driverID) }), nil
+}
+
+func (s memoryVehicles) Update(ctx context.Context, vehicle *models.Vehicle) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.vehicles.update(vehicle)
+}
+
+func (s memoryVehicles) Delete(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.vehicles.delete(id)
+}
+
+func (s memoryVehicles) Assign(ctx context.Context, vehicle *models.Vehicle, driverID uint, at time.Time) (models.VehicleAssignment, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	vehicle.AssignedDriver = &driverID
+	if err := s.m.vehicles.update(vehicle); err != nil {
+		return models.VehicleAssignment{}, err
+	}
+	assignment := models.VehicleAssignment{VehicleID: &vehicle.ID, DriverID: &driverID, AssignedAt: at}
+	s.m.assignments.insert(&assignment)
+	return assignment, nil
+}
+
+func (s memoryVehicles) Unassign(ctx context.Context, vehicle *models.Vehicle, at time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	vehicle.AssignedDriver = nil
+	if err := s.m.vehicles.update(vehicle); err != nil {
+		return err
+	}
+	open := s.m.assignments.list(func(a models.VehicleAssignment) bool {
+		return equal(a.VehicleID, vehicle.ID) && a.UnassignedAt == nil
+	})
+	for _, assignment := range open {
+		assignment.UnassignedAt = &at
+		if err := s.m.assignments.update(&assignment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s memoryVehicles) AssignmentAt(ctx context.Context, vehicleID uint, at time.Time) (models.VehicleAssignment, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	var latest *models.VehicleAssignment
+	for _, assignment := range s.m.assignments.list(nil) {
+		if !equal(assignment.VehicleID, vehicleID) || assignment.AssignedAt.After(at) ||
+			(assignment.UnassignedAt != nil && !assignment.UnassignedAt.After(at)) {
+			continue
+		}
+		if latest == nil || assignment.AssignedAt.After(latest.AssignedAt) {
+			assignment := assignment
+			latest = &assignment
+		}
+	}
+	if latest == nil {
+		return models.VehicleAssignment{}, ErrNotFound
+	}
+	return *latest, nil
+}
+
+func (s memoryVehicles) CountAssignments(ctx context.Context, vehicleID uint) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return int64(len(s.m.assignments.list(func(a models.VehicleAssignment) bool { return equal(a.VehicleID, vehicleID) }))), nil
+}
+
+func (s memoryVehicles) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	counts := make(map[string]int64)
+	for _, vehicle := range s.m.vehicles.list(nil) {
+		var status string
+		if vehicle.Status != nil {
+			status = *vehicle.Status
+		}
+		counts[status]++
+	}
+	return counts, nil
+}
+
+type memoryUsers struct {
+	m *Memory
+}
+
+func (s memoryUsers) taken(user *models.User) bool {
+	for _, other := range s.m.users.rows {
+		if other.Username == user.Username && other.ID != user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s memoryUsers) Create(ctx context.Context, user *models.User) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	if s.taken(user) {
+		return ErrDuplicate
+	}
+	s.m.users.insert(user)
+	return nil
+}
+
+func (s memoryUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.users.get(id)
+}
+
+func (s memoryUsers) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	users := s.m.users.list(func(u models.User) bool { return u.Username == username })
+	if len(users) == 0 {
+		return models.User{}, ErrNotFound
+	}
+	return users[0], nil
+}
+
+func (s memoryUsers) List(ctx context.Context) ([]models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.users.list(nil), nil
+}
+
+func (s memoryUsers) Update(ctx context.Context, user *models.User) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	if s.taken(user) {
+		return ErrDuplicate
+	}
+	return s.m.users.update(user)
+}
+
+func (s memoryUsers) Delete(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.users.delete(id)
+}
+
+type memoryTasks struct {
+	m *Memory
+}
+
+// withWaypoints attaches the route of the task, the caller holds the lock
+func (s memoryTasks) withWaypoints(task models.Task) models.Task {
+	task.Waypoints = s.m.waypoints.list(func(w models.TaskWaypoint) bool { return equal(w.TaskID, task.ID) })
+	sort.SliceStable(task.Waypoints, func(i, j int) bool {
+		return task.Waypoints[i].Sequence < task.Waypoints[j].Sequence
+	})
+	return task
+}
+
+func (s memoryTasks) insertWaypoints(taskID uint, waypoints []models.TaskWaypoint) {
+	for i := range waypoints {
+		waypoints[i].TaskID = &taskID
+		s.m.waypoints.insert(&waypoints[i])
+	}
+}
+
+func (s memoryTasks) Create(ctx context.Context, task *models.Task) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	waypoints := task.Waypoints
+	task.Waypoints = nil
+	s.m.tasks.insert(task)
+	s.insertWaypoints(task.ID, waypoints)
+	task.Waypoints = waypoints
+	return nil
+}
+
+func (s memoryTasks) Get(ctx context.Context, id uint) (models.Task, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	task, err := s.m.tasks.get(id)
+	if err != nil {
+		return task, err
+	}
+	return s.withWaypoints(task), nil
+}
+
+func (s memoryTasks) List(ctx context.Context) ([]models.Task, error) {
+	return s.list(nil), nil
+}
+
+func (s memoryTasks) ListByDriver(ctx context.Context, driverID uint) ([]models.Task, error) {
+	return s.list(func(t models.Task) bool { return equal(t.DriverID, driverID) }), nil
+}
+
+func (s memoryTasks) list(keep func(models.Task) bool) []models.Task {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	tasks := s.m.tasks.list(keep)
+	for i := range tasks {
+		tasks[i] = s.withWaypoints(tasks[i])
+	}
+	return tasks
+}
+
+func (s memoryTasks) Update(ctx context.Context, task *models.Task) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	row := *task
+	row.Waypoints = nil
+	if err := s.m.tasks.update(&row); err != nil {
+		return err
+	}
+	task.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+func (s memoryTasks) ReplaceWaypoints(ctx context.Context, taskID uint, waypoints []models.TaskWaypoint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, waypoint := range s.m.waypoints.list(func(w models.TaskWaypoint) bool { return equal(w.TaskID, taskID) }) {
+		delete(s.m.waypoints.rows, waypoint.ID)
+	}
+	s.insertWaypoints(taskID, waypoints)
+	return nil
+}
+
+func (s memoryTasks) Delete(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.tasks.delete(id)
+}
+
+func (s memoryTasks) CountOpen(ctx context.Context) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	open := s.m.tasks.list(func(t models.Task) bool {
+		return t.Status == nil || !slices.Contains(closedTaskStatuses, strings.ToLower(*t.Status))
+	})
+	return int64(len(open)), nil
+}
+
+type memoryFuelings struct {
+	m *Memory
+}
+
+func (s memoryFuelings) Create(ctx context.Context, fueling *models.FuelingRecord) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.fuelings.insert(fueling)
+	return nil
+}
+
+func (s memoryFuelings) Get(ctx context.Context, id uint) (models.FuelingRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.fuelings.get(id)
+}
+
+func (s memoryFuelings) List(ctx context.Context) ([]models.FuelingRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.fuelings.list(nil), nil
+}
+
+func (s memoryFuelings) ListByVehicle(ctx context.Context, vehicleID uint) ([]models.FuelingRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.fuelings.list(func(f models.FuelingRecord) bool { return equal(f.VehicleID, vehicleID) }), nil
+}
+
+func (s memoryFuelings) ListByPerson(ctx context.Context, userID uint) ([]models.FuelingRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.fuelings.list(func(f models.FuelingRecord) bool { return equal(f.FuelingPersonID, userID) }), nil
+}
+
+func (s memoryFuelings) Delete(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.fuelings.delete(id)
+}
+
+type memoryMaintenance struct {
+	m *Memory
+}
+
+func (s memoryMaintenance) Create(ctx context.Context, record *models.MaintenanceRecord) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.maintenance.insert(record)
+	return nil
+}
+
+func (s memoryMaintenance) Get(ctx context.Context, id uint) (models.MaintenanceRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.maintenance.get(id)
+}
+
+func (s memoryMaintenance) List(ctx context.Context) ([]models.MaintenanceRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.maintenance.list(nil), nil
+}
+
+func (s memoryMaintenance) ListByVehicle(ctx context.Context, vehicleID uint) ([]models.MaintenanceRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.maintenance.list(func(r models.MaintenanceRecord) bool { return equal(r.VehicleID, vehicleID) }), nil
+}
+
+func (s memoryMaintenance) ListByPerson(ctx context.Context, userID uint) ([]models.MaintenanceRecord, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.maintenance.list(func(r models.MaintenanceRecord) bool { return equal(r.MaintenancePersonID, userID) }), nil
+}
+
+func (s memoryMaintenance) Update(ctx context.Context, record *models.MaintenanceRecord) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.maintenance.update(record)
+}
+
+func (s memoryMaintenance) Delete(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.maintenance.delete(id)
+}
+
+func (s memoryMaintenance) CountPending(ctx context.Context) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	pending := s.m.maintenance.list(func(r models.MaintenanceRecord) bool {
+		return r.Status != nil && *r.Status == models.MaintenanceStatusPending
+	})
+	return int64(len(pending)), nil
+}
+
+type memoryAuctions struct {
+	m *Memory
+}
+
+func (s memoryAuctions) Create(ctx context.Context, auction *models.AuctionVehicle) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.auctions.insert(auction)
+	return nil
+}
+
+func (s memoryAuctions) Get(ctx context.Context, id uint) (models.AuctionVehicle, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.auctions.get(id)
+}
+
+func (s memoryAuctions) List(ctx context.Context) ([]models.AuctionVehicle, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.auctions.list(nil), nil
+}
+
+func (s memoryAuctions) Delete(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.auctions.delete(id)
+}
+
+func (s memoryAuctions) ListEnded(ctx context.Context, at time.Time) ([]models.AuctionVehicle, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.auctions.list(func(a models.AuctionVehicle) bool {
+		return a.ClosedAt == nil && a.EndsAt != nil && !a.EndsAt.After(at)
+	}), nil
+}
+
+func (s memoryAuctions) Close(ctx context.Context, auction *models.AuctionVehicle, winnerID *uint, at time.Time) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	row, err := s.m.auctions.get(auction.ID)
+	if err != nil || row.ClosedAt != nil {
+		return false, err
+	}
+	row.WinnerID = winnerID
+	row.ClosedAt = &at
+	if err := s.m.auctions.update(&row); err != nil {
+		return false, err
+	}
+	auction.WinnerID = winnerID
+	auction.ClosedAt = &at
+	return true, nil
+}
+
+type memoryPasswordResets struct {
+	m *Memory
+}
+
+func (s memoryPasswordResets) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.resets.insert(token)
+	return nil
+}
+
+func (s memoryPasswordResets) Use(ctx context.Context, tokenHash string, at time.Time) (models.PasswordResetToken, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	tokens := s.m.resets.list(func(r models.PasswordResetToken) bool {
+		return r.TokenHash == tokenHash && r.UsedAt == nil && r.ExpiresAt.After(at)
+	})
+	if len(tokens) == 0 {
+		return models.PasswordResetToken{}, ErrNotFound
+	}
+	token := tokens[0]
+	token.UsedAt = &at
+	return token, s.m.resets.update(&token)
+}
+
+func (s memoryPasswordResets) DeleteForUser(ctx context.Context, userID uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	for _, token := range s.m.resets.list(func(r models.PasswordResetToken) bool { return r.UserID == userID && r.UsedAt == nil }) {
+		s.m.resets.delete(token.ID)
+	}
+	return nil
+}
+
+func (s memoryPasswordResets) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	expired := s.m.resets.list(func(r models.PasswordResetToken) bool { return r.ExpiresAt.Before(before) })
+	for _, token := range expired {
+		s.m.resets.delete(token.ID)
+	}
+	return int64(len(expired)), nil
+}
+
+type memoryLicenses struct {
+	m *Memory
+}
+
+func (s memoryLicenses) Get(ctx context.Context, userID uint) (models.DriverLicense, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	licenses := s.m.licenses.list(func(l models.DriverLicense) bool { return equal(l.UserID, userID) })
+	if len(licenses) == 0 {
+		return models.DriverLicense{}, ErrNotFound
+	}
+	return licenses[0], nil
+}
+
+func (s memoryLicenses) Save(ctx context.Context, license *models.DriverLicense) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	if license.ID != 0 {
+		return s.m.licenses.update(license)
+	}
+	for _, other := range s.m.licenses.list(nil) {
+		if license.UserID != nil && equal(other.UserID, *license.UserID) {
+			return ErrDuplicate
+		}
+	}
+	s.m.licenses.insert(license)
+	return nil
+}
+
+func (s memoryLicenses) CreateQualification(ctx context.Context, qualification *models.DriverQualification) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.qualifications.insert(qualification)
+	return nil
+}
+
+func (s memoryLicenses) GetQualification(ctx context.Context, id uint) (models.DriverQualification, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.qualifications.get(id)
+}
+
+func (s memoryLicenses) ListQualifications(ctx context.Context, userID uint) ([]models.DriverQualification, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	qualifications := s.m.qualifications.list(func(q models.DriverQualification) bool { return equal(q.UserID, userID) })
+	sort.SliceStable(qualifications, func(i, j int) bool {
+		return *qualifications[i].Kind < *qualifications[j].Kind
+	})
+	return qualifications, nil
+}
+
+func (s memoryLicenses) DeleteQualification(ctx context.Context, id uint) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.qualifications.delete(id)
+}
+
+func (s memoryLicenses) HasQualification(ctx context.Context, userID uint, kind string, until time.Time) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	valid := s.m.qualifications.list(func(q models.DriverQualification) bool {
+		return equal(q.UserID, userID) && q.Kind != nil && *q.Kind == kind && (q.ExpiresAt == nil || !q.ExpiresAt.Before(until))
+	})
+	return len(valid) > 0, nil
+}
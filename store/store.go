@@ -0,0 +1,153 @@
+// Package store holds the queries of the API behind interfaces, so handlers do not depend on
+// GORM and can be tested against the in-memory implementation
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rassulmagauin/VMS_SWE/events"
+	"github.com/rassulmagauin/VMS_SWE/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned for missing records. It is gorm.ErrRecordNotFound so both are reported alike.
+	ErrNotFound = gorm.ErrRecordNotFound
+	// ErrDuplicate is returned when a unique value such as a username is taken
+	ErrDuplicate = gorm.ErrDuplicatedKey
+)
+
+// closedTaskStatuses are the lower-cased statuses of tasks that are no longer open
+var closedTaskStatuses = []string{
+	strings.ToLower(string(models.TaskStatusCompleted)),
+	strings.ToLower(string(models.TaskStatusCanceled)),
+	"cancelled",
+}
+
+// Store gives access to all repositories
+type Store interface {
+	Vehicles() VehicleStore
+	Users() UserStore
+	Tasks() TaskStore
+	Fuelings() FuelingStore
+	Maintenance() MaintenanceStore
+	Auctions() AuctionStore
+	PasswordResets() PasswordResetStore
+	Licenses() LicenseStore
+
+	// WriteEvent adds the event to the outbox. Within a transaction it is only dispatched if the transaction commits.
+	WriteEvent(ctx context.Context, evt events.Event) error
+	// Transaction runs fn with a store whose changes are committed together if fn returns nil
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
+
+type VehicleStore interface {
+	Create(ctx context.Context, vehicle *models.Vehicle) error
+	Get(ctx context.Context, id uint) (models.Vehicle, error)
+	List(ctx context.Context) ([]models.Vehicle, error)
+	// ListByDriver returns the vehicles assigned to the driver, oldest first
+	ListByDriver(ctx context.Context, driverID uint) ([]models.Vehicle, error)
+	Update(ctx context.Context, vehicle *models.Vehicle) error
+	Delete(ctx context.Context, id uint) error
+
+	// Assign hands the vehicle to the driver and opens an entry in its assignment history
+	Assign(ctx context.Context, vehicle *models.Vehicle, driverID uint, at time.Time) (models.VehicleAssignment, error)
+	// Unassign takes the vehicle from its driver and closes the open assignment
+	Unassign(ctx context.Context, vehicle *models.Vehicle, at time.Time) error
+	// AssignmentAt returns the assignment of the vehicle that was open at the given time
+	AssignmentAt(ctx context.Context, vehicleID uint, at time.Time) (models.VehicleAssignment, error)
+	CountAssignments(ctx context.Context, vehicleID uint) (int64, error)
+	// CountByStatus returns the number of vehicles per status, vehicles without one count under ""
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+}
+
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, id uint) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// TaskStore returns tasks with their waypoints ordered by sequence
+type TaskStore interface {
+	// Create stores the task together with its waypoints
+	Create(ctx context.Context, task *models.Task) error
+	Get(ctx context.Context, id uint) (models.Task, error)
+	List(ctx context.Context) ([]models.Task, error)
+	ListByDriver(ctx context.Context, driverID uint) ([]models.Task, error)
+	// Update saves the task itself, the route is changed with ReplaceWaypoints
+	Update(ctx context.Context, task *models.Task) error
+	ReplaceWaypoints(ctx context.Context, taskID uint, waypoints []models.TaskWaypoint) error
+	Delete(ctx context.Context, id uint) error
+	// CountOpen returns the number of tasks that are neither completed nor canceled
+	CountOpen(ctx context.Context) (int64, error)
+}
+
+type FuelingStore interface {
+	Create(ctx context.Context, fueling *models.FuelingRecord) error
+	Get(ctx context.Context, id uint) (models.FuelingRecord, error)
+	List(ctx context.Context) ([]models.FuelingRecord, error)
+	ListByVehicle(ctx context.Context, vehicleID uint) ([]models.FuelingRecord, error)
+	// ListByPerson returns the records of the fueling person
+	ListByPerson(ctx context.Context, userID uint) ([]models.FuelingRecord, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type MaintenanceStore interface {
+	Create(ctx context.Context, record *models.MaintenanceRecord) error
+	Get(ctx context.Context, id uint) (models.MaintenanceRecord, error)
+	List(ctx context.Context) ([]models.MaintenanceRecord, error)
+	ListByVehicle(ctx context.Context, vehicleID uint) ([]models.MaintenanceRecord, error)
+	// ListByPerson returns the records of the maintenance person
+	ListByPerson(ctx context.Context, userID uint) ([]models.MaintenanceRecord, error)
+	Update(ctx context.Context, record *models.MaintenanceRecord) error
+	Delete(ctx context.Context, id uint) error
+	CountPending(ctx context.Context) (int64, error)
+}
+
+// AuctionStore returns auctions with their images
+type AuctionStore interface {
+	// Create stores the auction together with its images
+	Create(ctx context.Context, auction *models.AuctionVehicle) error
+	Get(ctx context.Context, id uint) (models.AuctionVehicle, error)
+	List(ctx context.Context) ([]models.AuctionVehicle, error)
+	Delete(ctx context.Context, id uint) error
+	// ListEnded returns the open auctions whose end time is not after the given time
+	ListEnded(ctx context.Context, at time.Time) ([]models.AuctionVehicle, error)
+	// Close sets the winner and closing time of the auction if it is still open.
+	// It reports false if the auction was closed already.
+	Close(ctx context.Context, auction *models.AuctionVehicle, winnerID *uint, at time.Time) (bool, error)
+}
+
+// PasswordResetStore keeps the password reset tokens by the hash of the token
+type PasswordResetStore interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	// Use marks the token with the hash used if it is unused and unexpired at the given time,
+	// so it works only once. It returns ErrNotFound for any other token.
+	Use(ctx context.Context, tokenHash string, at time.Time) (models.PasswordResetToken, error)
+	// DeleteForUser drops the unused tokens of the user, after the password changed
+	DeleteForUser(ctx context.Context, userID uint) error
+	// DeleteExpired drops the tokens expired before the given time and returns how many there were
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LicenseStore keeps the driving license of each user and the qualifications held on top of it
+type LicenseStore interface {
+	// Get returns the license of the user
+	Get(ctx context.Context, userID uint) (models.DriverLicense, error)
+	// Save creates the license, or replaces it when it has an ID
+	Save(ctx context.Context, license *models.DriverLicense) error
+
+	CreateQualification(ctx context.Context, qualification *models.DriverQualification) error
+	GetQualification(ctx context.Context, id uint) (models.DriverQualification, error)
+	// ListQualifications returns the qualifications of the user ordered by kind
+	ListQualifications(ctx context.Context, userID uint) ([]models.DriverQualification, error)
+	DeleteQualification(ctx context.Context, id uint) error
+	// HasQualification reports whether the user holds a qualification of the kind that is valid
+	// until the given time, qualifications without an expiry date never expire
+	HasQualification(ctx context.Context, userID uint, kind string, until time.Time) (bool, error)
+}
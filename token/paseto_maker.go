@@ -26,8 +26,8 @@ func NewPasetoMaker(symmetricKey string) (Maker, error) {
 }
 
 // CreateToken creates a new token for a specific user
-func (p *PasetoMaker) CreateToken(username string, role string, duration time.Duration) (string, error) {
-	payload, err := NewPayload(username, role, duration)
+func (p *PasetoMaker) CreateToken(userID uint, username string, role string, duration time.Duration) (string, error) {
+	payload, err := NewPayload(userID, username, role, duration)
 	if err != nil {
 		return "", err
 	}
@@ -9,19 +9,21 @@ import (
 
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
+	UserID    uint      `json:"user_id"`
 	Username  string    `json:"username"`
 	Role      string    `json:"role"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-func NewPayload(username string, role string, duration time.Duration) (*Payload, error) {
+func NewPayload(userID uint, username string, role string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
 	payload := &Payload{
 		ID:        tokenID,
+		UserID:    userID,
 		Username:  username,
 		Role:      role,
 		IssuedAt:  time.Now(),
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type thing struct {
+	ID   uint
+	Name string
+}
+
+func TestRequestAndQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&thing{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/things/:id", func(c *gin.Context) {
+		var found thing
+		if err := db.WithContext(c.Request.Context()).First(&found, c.Param("id")).Error; err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/things/7", nil)
+	req.Header.Set("traceparent", parent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the query and the request", len(spans))
+	}
+	query, request := spans[0], spans[1]
+	if request.Name() != "GET /things/:id" || request.SpanKind() != trace.SpanKindServer {
+		t.Errorf("request span = %s (%s)", request.Name(), request.SpanKind())
+	}
+	if got := request.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the one of the traceparent header", got)
+	}
+	if query.Name() != "SELECT things" || query.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("query span %s is not a child of the request span", query.Name())
+	}
+	attrs := make(map[string]string)
+	for _, attr := range query.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["db.system"] != "sqlite" || attrs["db.sql.table"] != "things" || attrs["db.statement"] == "" {
+		t.Errorf("query attributes = %v", attrs)
+	}
+	// a missing record is not an error
+	if query.Status().Code.String() == "Error" {
+		t.Errorf("query status = %v", query.Status())
+	}
+}
+
+func TestSetupStdout(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterStdout, ServiceName: "vms-test", SampleRatio: 1, Writer: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "work")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Name":"work"`) || !strings.Contains(buf.String(), "vms-test") {
+		t.Errorf("exported %s, want the span with the service name", buf.String())
+	}
+
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Error("an unknown exporter was accepted")
+	}
+}
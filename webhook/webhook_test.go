@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// computed independently: HMAC-SHA256 of `1700000000.{"type":"task.created"}` under "whsec_test"
+	want := "b14203adcb404d4b52c0646691229b66260532ee315b2e423b43441754f0587d"
+	if got := Sign("whsec_test", 1700000000, []byte(`{"type":"task.created"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if got := Sign("other secret", 1700000000, []byte(`{"type":"task.created"}`)); got == want {
+		t.Error("Sign does not depend on the secret")
+	}
+}
+
+func TestSendSignsThePayload(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	payload := []byte(`{"type":"task.created"}`)
+	if _, err := NewSender().Send(context.Background(), server.URL, "whsec_test", 42, "task.created", payload); err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(payload) {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	if header.Get(EventHeader) != "task.created" || header.Get(DeliveryHeader) != "42" {
+		t.Errorf("event header %q and delivery header %q, want task.created and 42", header.Get(EventHeader), header.Get(DeliveryHeader))
+	}
+	var timestamp int64
+	var signature string
+	if _, err := fmt.Sscanf(header.Get(SignatureHeader), "t=%d,v1=%s", &timestamp, &signature); err != nil {
+		t.Fatalf("signature header %q: %v", header.Get(SignatureHeader), err)
+	}
+	if time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Errorf("signature time %d is not the time of sending", timestamp)
+	}
+	if want := Sign("whsec_test", timestamp, payload); signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+}
+
+func TestSendFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	status, err := NewSender().Send(context.Background(), server.URL, "whsec_test", 1, "task.created", []byte(`{}`))
+	if status != http.StatusServiceUnavailable || err == nil {
+		t.Errorf("Send = %d, %v, want 503 and an error", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		// capped from then on
+		{11, 6 * time.Hour},
+		{MaxAttempts * 10, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		pattern, eventType string
+		want               bool
+	}{
+		{"*", "task.created", true},
+		{"task.created", "task.created", true},
+		{"task.*", "task.status_changed", true},
+		{"task.*", "tasks.created", false},
+		{"vehicle.created", "task.created", false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.pattern, tt.eventType); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.pattern, tt.eventType, got, tt.want)
+		}
+	}
+}